@@ -0,0 +1,625 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"github.com/KushnerykPavel/raft-test-project/internal/processor"
+	"github.com/KushnerykPavel/raft-test-project/internal/raftlog"
+	"github.com/KushnerykPavel/raft-test-project/internal/repo"
+	"github.com/KushnerykPavel/raft-test-project/internal/storage"
+	"github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/raft"
+	"github.com/spf13/viper"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// configRaft configuration for raft node
+type configRaft struct {
+	NodeId       string
+	Port         int
+	VolumeDir    string
+	LogStore     string
+	LogCacheSize int
+	Join         []string
+
+	SnapshotRetain     int
+	SnapshotThreshold  uint64
+	SnapshotInterval   time.Duration
+	TrailingLogs       uint64
+	HeartbeatTimeout   time.Duration
+	ElectionTimeout    time.Duration
+	CommitTimeout      time.Duration
+	LeaderLeaseTimeout time.Duration
+	MaxAppendEntries   int
+	LogLevel           string
+}
+
+// configTransport configuration for raft TCP transport
+type configTransport struct {
+	Host    string
+	MaxPool int
+	Timeout time.Duration
+}
+
+// configServer configuration for HTTP server
+type configServer struct {
+	Port                       int
+	AdvertiseAddress           string
+	AdminToken                 string
+	DefaultMerchant            string
+	ReadTimeout                time.Duration
+	WriteTimeout               time.Duration
+	IdempotencyRetention       time.Duration
+	AuthorizationTTL           time.Duration
+	AuthorizationSweepInterval time.Duration
+	SchedulerInterval          time.Duration
+	DunningRetrySchedule       []time.Duration
+	DunningMaxFailures         int
+	Processor                  string
+	ProcessorTimeout           time.Duration
+	WebhookInterval            time.Duration
+	WebhookTimeout             time.Duration
+	WebhookBackoff             time.Duration
+	WebhookMaxAttempts         int
+	ChangelogRetention         time.Duration
+	ApplyBatchSize             int
+	ApplyBatchLinger           time.Duration
+	ReadyMaxLastContact        time.Duration
+	ReadyMaxApplyLag           uint64
+}
+
+// configStorage configuration for FSM storage
+type configStorage struct {
+	Engine         string
+	GCInterval     time.Duration
+	GCDiscardRatio float64
+}
+
+// config configuration
+type config struct {
+	Server    configServer
+	Raft      configRaft
+	Transport configTransport
+	Storage   configStorage
+}
+
+// Keys of the configuration file. Every key is overridden by the environment
+// variable with dots replaced by underscores, e.g. SERVER_PORT for server.port.
+const (
+	serverPort                 = "server.port"
+	serverAdvertiseAddress     = "server.advertise_address"
+	serverAdminToken           = "server.admin_token"
+	serverDefaultMerchant      = "server.default_merchant"
+	serverReadTimeout          = "server.read_timeout"
+	serverWriteTimeout         = "server.write_timeout"
+	serverIdempotencyRetention = "server.idempotency_retention"
+	serverAuthorizationTTL     = "server.authorization_ttl"
+	serverAuthorizationSweep   = "server.authorization_sweep_interval"
+	serverSchedulerInterval    = "server.scheduler_interval"
+	serverDunningRetrySchedule = "server.dunning_retry_schedule"
+	serverDunningMaxFailures   = "server.dunning_max_failures"
+	serverProcessor            = "server.processor"
+	serverProcessorTimeout     = "server.processor_timeout"
+	serverWebhookInterval      = "server.webhook_interval"
+	serverWebhookTimeout       = "server.webhook_timeout"
+	serverWebhookBackoff       = "server.webhook_backoff"
+	serverWebhookMaxAttempts   = "server.webhook_max_attempts"
+	serverChangelogRetention   = "server.changelog_retention"
+	serverApplyBatchSize       = "server.apply_batch_size"
+	serverApplyBatchLinger     = "server.apply_batch_linger"
+	serverReadyMaxLastContact  = "server.ready_max_last_contact"
+	serverReadyMaxApplyLag     = "server.ready_max_apply_lag"
+
+	raftNodeId             = "raft.node_id"
+	raftPort               = "raft.port"
+	raftVolDir             = "raft.vol_dir"
+	raftLog                = "raft.log_store"
+	raftCache              = "raft.log_cache_size"
+	raftJoin               = "raft.join"
+	raftSnapshotRetain     = "raft.snapshot_retain"
+	raftSnapshotThreshold  = "raft.snapshot_threshold"
+	raftSnapshotInterval   = "raft.snapshot_interval"
+	raftTrailingLogs       = "raft.trailing_logs"
+	raftHeartbeatTimeout   = "raft.heartbeat_timeout"
+	raftElectionTimeout    = "raft.election_timeout"
+	raftCommitTimeout      = "raft.commit_timeout"
+	raftLeaderLeaseTimeout = "raft.leader_lease_timeout"
+	raftMaxAppendEntries   = "raft.max_append_entries"
+	raftLogLevel           = "raft.log_level"
+
+	transportHost    = "transport.host"
+	transportMaxPool = "transport.max_pool"
+	transportTimeout = "transport.timeout"
+
+	storageEngine         = "storage.engine"
+	storageGCInterval     = "storage.gc_interval"
+	storageGCDiscardRatio = "storage.gc_discard_ratio"
+)
+
+var confKeys = []string{
+	serverPort,
+	serverAdvertiseAddress,
+	serverAdminToken,
+	serverDefaultMerchant,
+	serverReadTimeout,
+	serverWriteTimeout,
+	serverIdempotencyRetention,
+	serverAuthorizationTTL,
+	serverAuthorizationSweep,
+	serverSchedulerInterval,
+	serverDunningRetrySchedule,
+	serverDunningMaxFailures,
+	serverProcessor,
+	serverProcessorTimeout,
+	serverWebhookInterval,
+	serverWebhookTimeout,
+	serverWebhookBackoff,
+	serverWebhookMaxAttempts,
+	serverChangelogRetention,
+	serverApplyBatchSize,
+	serverApplyBatchLinger,
+	serverReadyMaxLastContact,
+	serverReadyMaxApplyLag,
+
+	raftNodeId,
+	raftPort,
+	raftVolDir,
+	raftLog,
+	raftCache,
+	raftJoin,
+	raftSnapshotRetain,
+	raftSnapshotThreshold,
+	raftSnapshotInterval,
+	raftTrailingLogs,
+	raftHeartbeatTimeout,
+	raftElectionTimeout,
+	raftCommitTimeout,
+	raftLeaderLeaseTimeout,
+	raftMaxAppendEntries,
+	raftLogLevel,
+
+	transportHost,
+	transportMaxPool,
+	transportTimeout,
+
+	storageEngine,
+	storageGCInterval,
+	storageGCDiscardRatio,
+}
+
+const (
+	// The maxPool controls how many connections we will pool.
+	defaultMaxPool = 3
+
+	// The timeout is used to apply I/O deadlines. For InstallSnapshot, we multiply
+	// the timeout by (SnapshotSize / TimeoutScale).
+	// https://github.com/hashicorp/raft/blob/v1.1.2/net_transport.go#L177-L181
+	defaultTCPTimeout = 10 * time.Second
+
+	// defaultTransportHost is the address raft binds to
+	// and advertises to the other nodes.
+	defaultTransportHost = "127.0.0.1"
+
+	// The `retain` parameter controls how many
+	// snapshots are retained. Must be at least 1.
+	defaultSnapshotRetain = 2
+
+	// defaultSnapshotThreshold is how many log entries
+	// since the last snapshot trigger a new one.
+	defaultSnapshotThreshold = 1024
+
+	// raftLogCacheSize is the default maximum number of logs to cache in-memory.
+	// This is used to reduce disk I/O for the recently committed entries.
+	raftLogCacheSize = 512
+
+	// defaultHTTPTimeout is how long the server reads a request
+	// and writes a response.
+	defaultHTTPTimeout = 3 * time.Second
+
+	// defaultIdempotencyRetention is how long responses
+	// of requests with Idempotency-Key header are kept.
+	defaultIdempotencyRetention = 24 * time.Hour
+
+	// defaultAuthorizationTTL is how long authorization holds funds
+	// before the leader expires it.
+	defaultAuthorizationTTL = 7 * 24 * time.Hour
+
+	// defaultAuthorizationSweepInterval is how often the leader
+	// looks for expired authorizations.
+	defaultAuthorizationSweepInterval = time.Minute
+
+	// defaultSchedulerInterval is how often the leader
+	// looks for due subscription charges.
+	defaultSchedulerInterval = 10 * time.Second
+
+	// defaultDunningRetrySchedule is delays before retries
+	// of a failed subscription charge.
+	defaultDunningRetrySchedule = "24h,72h,168h"
+
+	// defaultDunningMaxFailures is how many failed charges in a row
+	// suspend the subscription.
+	defaultDunningMaxFailures = 4
+
+	// defaultProcessorTimeout is how long the leader waits for the processor.
+	// It is below the HTTP write timeout, so a timed out payment is still answered.
+	defaultProcessorTimeout = 2 * time.Second
+
+	// defaultWebhookInterval is how often the leader
+	// looks for events to deliver.
+	defaultWebhookInterval = time.Second
+
+	// defaultWebhookTimeout is how long the leader
+	// waits for the merchant endpoint.
+	defaultWebhookTimeout = 5 * time.Second
+
+	// defaultWebhookBackoff is delay after the first failed delivery,
+	// it doubles with every next failure.
+	defaultWebhookBackoff = 10 * time.Second
+
+	// defaultWebhookMaxAttempts is how many times an event
+	// is delivered before it is dropped.
+	defaultWebhookMaxAttempts = 10
+
+	// defaultChangelogRetention is how long applied commands
+	// can be replayed by the events stream.
+	defaultChangelogRetention = 7 * 24 * time.Hour
+
+	// defaultApplyBatchSize is how many concurrent commands
+	// are coalesced into one raft log entry at most.
+	defaultApplyBatchSize = 64
+
+	// defaultApplyBatchLinger is how long a batch waits
+	// for more commands, zero applies what is queued right away.
+	defaultApplyBatchLinger = time.Duration(0)
+
+	// defaultReadyMaxLastContact is how long a follower may not hear
+	// from the leader and still be ready.
+	defaultReadyMaxLastContact = 5 * time.Second
+
+	// defaultReadyMaxApplyLag is how many committed entries
+	// the FSM may not have applied and still be ready.
+	defaultReadyMaxApplyLag = 1000
+
+	// defaultStorageGCInterval is how often every node
+	// collects garbage of its storage.
+	defaultStorageGCInterval = 5 * time.Minute
+
+	// defaultStorageGCDiscardRatio is share of stale data
+	// which makes a badger value log file rewritten.
+	defaultStorageGCDiscardRatio = 0.5
+)
+
+// loadConfig reads the configuration file, when there is one, with the
+// environment on top of it. Unknown keys and invalid values fail the start.
+func loadConfig(path string) (config, *viper.Viper, error) {
+	var v = viper.New()
+	v.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
+	v.AutomaticEnv()
+	if err := v.BindEnv(confKeys...); err != nil {
+		return config{}, nil, err
+	}
+	setDefaults(v)
+
+	if path != "" {
+		v.SetConfigFile(path)
+		if err := v.ReadInConfig(); err != nil {
+			return config{}, nil, fmt.Errorf("error reading config file %s: %w", path, err)
+		}
+	}
+
+	conf, err := parseConfig(v)
+	return conf, v, err
+}
+
+// parseConfig builds and validates the configuration from the options read by viper
+func parseConfig(v *viper.Viper) (config, error) {
+	if err := checkKeys(v); err != nil {
+		return config{}, err
+	}
+
+	dunningRetrySchedule, err := repo.ParseRetrySchedule(v.GetString(serverDunningRetrySchedule))
+	if err != nil {
+		return config{}, fmt.Errorf("%s: %w", serverDunningRetrySchedule, err)
+	}
+
+	conf := config{
+		Server: configServer{
+			Port:                       v.GetInt(serverPort),
+			AdvertiseAddress:           v.GetString(serverAdvertiseAddress),
+			AdminToken:                 v.GetString(serverAdminToken),
+			DefaultMerchant:            v.GetString(serverDefaultMerchant),
+			ReadTimeout:                v.GetDuration(serverReadTimeout),
+			WriteTimeout:               v.GetDuration(serverWriteTimeout),
+			IdempotencyRetention:       v.GetDuration(serverIdempotencyRetention),
+			AuthorizationTTL:           v.GetDuration(serverAuthorizationTTL),
+			AuthorizationSweepInterval: v.GetDuration(serverAuthorizationSweep),
+			SchedulerInterval:          v.GetDuration(serverSchedulerInterval),
+			DunningRetrySchedule:       dunningRetrySchedule,
+			DunningMaxFailures:         v.GetInt(serverDunningMaxFailures),
+			Processor:                  v.GetString(serverProcessor),
+			ProcessorTimeout:           v.GetDuration(serverProcessorTimeout),
+			WebhookInterval:            v.GetDuration(serverWebhookInterval),
+			WebhookTimeout:             v.GetDuration(serverWebhookTimeout),
+			WebhookBackoff:             v.GetDuration(serverWebhookBackoff),
+			WebhookMaxAttempts:         v.GetInt(serverWebhookMaxAttempts),
+			ChangelogRetention:         v.GetDuration(serverChangelogRetention),
+			ApplyBatchSize:             v.GetInt(serverApplyBatchSize),
+			ApplyBatchLinger:           v.GetDuration(serverApplyBatchLinger),
+			ReadyMaxLastContact:        v.GetDuration(serverReadyMaxLastContact),
+			ReadyMaxApplyLag:           v.GetUint64(serverReadyMaxApplyLag),
+		},
+		Raft: configRaft{
+			NodeId:             v.GetString(raftNodeId),
+			Port:               v.GetInt(raftPort),
+			VolumeDir:          v.GetString(raftVolDir),
+			LogStore:           v.GetString(raftLog),
+			LogCacheSize:       v.GetInt(raftCache),
+			Join:               splitList(v.GetString(raftJoin)),
+			SnapshotRetain:     v.GetInt(raftSnapshotRetain),
+			SnapshotThreshold:  v.GetUint64(raftSnapshotThreshold),
+			SnapshotInterval:   v.GetDuration(raftSnapshotInterval),
+			TrailingLogs:       v.GetUint64(raftTrailingLogs),
+			HeartbeatTimeout:   v.GetDuration(raftHeartbeatTimeout),
+			ElectionTimeout:    v.GetDuration(raftElectionTimeout),
+			CommitTimeout:      v.GetDuration(raftCommitTimeout),
+			LeaderLeaseTimeout: v.GetDuration(raftLeaderLeaseTimeout),
+			MaxAppendEntries:   v.GetInt(raftMaxAppendEntries),
+			LogLevel:           v.GetString(raftLogLevel),
+		},
+		Transport: configTransport{
+			Host:    v.GetString(transportHost),
+			MaxPool: v.GetInt(transportMaxPool),
+			Timeout: v.GetDuration(transportTimeout),
+		},
+		Storage: configStorage{
+			Engine:         v.GetString(storageEngine),
+			GCInterval:     v.GetDuration(storageGCInterval),
+			GCDiscardRatio: v.GetFloat64(storageGCDiscardRatio),
+		},
+	}
+	if conf.Server.AdvertiseAddress == "" {
+		conf.Server.AdvertiseAddress = fmt.Sprintf("http://%s", net.JoinHostPort(conf.Transport.Host, strconv.Itoa(conf.Server.Port)))
+	}
+
+	return conf, conf.validate()
+}
+
+func setDefaults(v *viper.Viper) {
+	raftDefaults := raft.DefaultConfig()
+
+	v.SetDefault(serverReadTimeout, defaultHTTPTimeout)
+	v.SetDefault(serverWriteTimeout, defaultHTTPTimeout)
+	v.SetDefault(serverIdempotencyRetention, defaultIdempotencyRetention)
+	v.SetDefault(serverAuthorizationTTL, defaultAuthorizationTTL)
+	v.SetDefault(serverAuthorizationSweep, defaultAuthorizationSweepInterval)
+	v.SetDefault(serverSchedulerInterval, defaultSchedulerInterval)
+	v.SetDefault(serverDunningRetrySchedule, defaultDunningRetrySchedule)
+	v.SetDefault(serverDunningMaxFailures, defaultDunningMaxFailures)
+	v.SetDefault(serverProcessor, processor.SimulatorName)
+	v.SetDefault(serverProcessorTimeout, defaultProcessorTimeout)
+	v.SetDefault(serverWebhookInterval, defaultWebhookInterval)
+	v.SetDefault(serverWebhookTimeout, defaultWebhookTimeout)
+	v.SetDefault(serverWebhookBackoff, defaultWebhookBackoff)
+	v.SetDefault(serverWebhookMaxAttempts, defaultWebhookMaxAttempts)
+	v.SetDefault(serverChangelogRetention, defaultChangelogRetention)
+	v.SetDefault(serverApplyBatchSize, defaultApplyBatchSize)
+	v.SetDefault(serverApplyBatchLinger, defaultApplyBatchLinger)
+	v.SetDefault(serverReadyMaxLastContact, defaultReadyMaxLastContact)
+	v.SetDefault(serverReadyMaxApplyLag, defaultReadyMaxApplyLag)
+
+	v.SetDefault(raftLog, raftlog.BoltEngine)
+	v.SetDefault(raftCache, raftLogCacheSize)
+	v.SetDefault(raftSnapshotRetain, defaultSnapshotRetain)
+	v.SetDefault(raftSnapshotThreshold, defaultSnapshotThreshold)
+	v.SetDefault(raftSnapshotInterval, raftDefaults.SnapshotInterval)
+	v.SetDefault(raftTrailingLogs, raftDefaults.TrailingLogs)
+	v.SetDefault(raftHeartbeatTimeout, raftDefaults.HeartbeatTimeout)
+	v.SetDefault(raftElectionTimeout, raftDefaults.ElectionTimeout)
+	v.SetDefault(raftCommitTimeout, raftDefaults.CommitTimeout)
+	v.SetDefault(raftLeaderLeaseTimeout, raftDefaults.LeaderLeaseTimeout)
+	v.SetDefault(raftMaxAppendEntries, raftDefaults.MaxAppendEntries)
+	v.SetDefault(raftLogLevel, raftDefaults.LogLevel)
+
+	v.SetDefault(transportHost, defaultTransportHost)
+	v.SetDefault(transportMaxPool, defaultMaxPool)
+	v.SetDefault(transportTimeout, defaultTCPTimeout)
+
+	v.SetDefault(storageEngine, storage.BadgerEngine)
+	v.SetDefault(storageGCInterval, defaultStorageGCInterval)
+	v.SetDefault(storageGCDiscardRatio, defaultStorageGCDiscardRatio)
+}
+
+// checkKeys rejects keys of the configuration file which are not options,
+// a typo would otherwise silently leave the default in place
+func checkKeys(v *viper.Viper) error {
+	known := make(map[string]bool, len(confKeys))
+	for _, key := range confKeys {
+		known[key] = true
+	}
+
+	var unknown []string
+	for _, key := range v.AllKeys() {
+		if !known[key] {
+			unknown = append(unknown, key)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return fmt.Errorf("unknown config options: %s", strings.Join(unknown, ", "))
+	}
+	return nil
+}
+
+// validate reports every invalid option at once
+func (c *config) validate() error {
+	var errs []error
+	check := func(ok bool, key, format string, args ...any) {
+		if !ok {
+			errs = append(errs, fmt.Errorf("%s: "+format, append([]any{key}, args...)...))
+		}
+	}
+
+	check(c.Server.Port > 0 && c.Server.Port < 65536, serverPort, "must be a port, got %d", c.Server.Port)
+	check(repo.ValidateHTTPAddress(c.Server.AdvertiseAddress) == nil, serverAdvertiseAddress,
+		"must be http or https url without a path, got %q", c.Server.AdvertiseAddress)
+	check(c.Server.AdminToken != "", serverAdminToken, "is required")
+	check(c.Server.ReadTimeout > 0, serverReadTimeout, "must be positive")
+	check(c.Server.WriteTimeout > 0, serverWriteTimeout, "must be positive")
+	check(c.Server.ProcessorTimeout < c.Server.WriteTimeout, serverProcessorTimeout,
+		"must be below %s, so a timed out payment is still answered", serverWriteTimeout)
+	for key, interval := range map[string]time.Duration{
+		serverIdempotencyRetention: c.Server.IdempotencyRetention,
+		serverAuthorizationTTL:     c.Server.AuthorizationTTL,
+		serverAuthorizationSweep:   c.Server.AuthorizationSweepInterval,
+		serverSchedulerInterval:    c.Server.SchedulerInterval,
+		serverProcessorTimeout:     c.Server.ProcessorTimeout,
+		serverWebhookInterval:      c.Server.WebhookInterval,
+		serverWebhookTimeout:       c.Server.WebhookTimeout,
+		serverWebhookBackoff:       c.Server.WebhookBackoff,
+		serverReadyMaxLastContact:  c.Server.ReadyMaxLastContact,
+		storageGCInterval:          c.Storage.GCInterval,
+		transportTimeout:           c.Transport.Timeout,
+	} {
+		check(interval > 0, key, "must be positive")
+	}
+	check(c.Server.DunningMaxFailures > 0, serverDunningMaxFailures, "must be positive")
+	check(c.Server.WebhookMaxAttempts > 0, serverWebhookMaxAttempts, "must be positive")
+	check(c.Server.ChangelogRetention >= 0, serverChangelogRetention, "must not be negative")
+	check(c.Server.ApplyBatchSize > 0, serverApplyBatchSize, "must be positive")
+	check(c.Server.ApplyBatchLinger >= 0, serverApplyBatchLinger, "must not be negative")
+
+	check(c.Raft.NodeId != "", raftNodeId, "is required")
+	check(c.Raft.Port > 0 && c.Raft.Port < 65536, raftPort, "must be a port, got %d", c.Raft.Port)
+	check(c.Raft.VolumeDir != "", raftVolDir, "is required")
+	check(oneOf(c.Raft.LogStore, raftlog.BoltEngine, raftlog.BadgerEngine, raftlog.MemoryEngine), raftLog,
+		"unknown log store %q", c.Raft.LogStore)
+	check(c.Raft.LogCacheSize >= 0, raftCache, "must not be negative")
+	for _, address := range c.Raft.Join {
+		check(repo.ValidateHTTPAddress(address) == nil, raftJoin, "must be http or https urls of nodes, got %q", address)
+	}
+	check(c.Raft.SnapshotRetain > 0, raftSnapshotRetain, "must be at least 1")
+	check(hclog.LevelFromString(c.Raft.LogLevel) != hclog.NoLevel, raftLogLevel, "unknown level %q", c.Raft.LogLevel)
+	if err := raft.ValidateConfig(c.Raft.raftConfig()); err != nil {
+		errs = append(errs, fmt.Errorf("raft: %w", err))
+	}
+
+	check(c.Transport.Host != "", transportHost, "is required")
+	check(c.Transport.MaxPool > 0, transportMaxPool, "must be positive")
+
+	check(oneOf(c.Storage.Engine, storage.BadgerEngine, storage.BoltEngine, storage.MemoryEngine), storageEngine,
+		"unknown engine %q", c.Storage.Engine)
+	check(c.Storage.GCDiscardRatio > 0 && c.Storage.GCDiscardRatio < 1, storageGCDiscardRatio, "must be between 0 and 1")
+
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid configuration: %w", errors.Join(errs...))
+	}
+	return nil
+}
+
+// settings options of the configuration by their keys,
+// durations are written as in the config file
+func (c *config) settings() map[string]interface{} {
+	retrySchedule := make([]string, 0, len(c.Server.DunningRetrySchedule))
+	for _, delay := range c.Server.DunningRetrySchedule {
+		retrySchedule = append(retrySchedule, delay.String())
+	}
+
+	return map[string]interface{}{
+		serverPort:                 c.Server.Port,
+		serverAdvertiseAddress:     c.Server.AdvertiseAddress,
+		serverAdminToken:           redacted(c.Server.AdminToken),
+		serverDefaultMerchant:      c.Server.DefaultMerchant,
+		serverReadTimeout:          c.Server.ReadTimeout.String(),
+		serverWriteTimeout:         c.Server.WriteTimeout.String(),
+		serverIdempotencyRetention: c.Server.IdempotencyRetention.String(),
+		serverAuthorizationTTL:     c.Server.AuthorizationTTL.String(),
+		serverAuthorizationSweep:   c.Server.AuthorizationSweepInterval.String(),
+		serverSchedulerInterval:    c.Server.SchedulerInterval.String(),
+		serverDunningRetrySchedule: strings.Join(retrySchedule, ","),
+		serverDunningMaxFailures:   c.Server.DunningMaxFailures,
+		serverProcessor:            c.Server.Processor,
+		serverProcessorTimeout:     c.Server.ProcessorTimeout.String(),
+		serverWebhookInterval:      c.Server.WebhookInterval.String(),
+		serverWebhookTimeout:       c.Server.WebhookTimeout.String(),
+		serverWebhookBackoff:       c.Server.WebhookBackoff.String(),
+		serverWebhookMaxAttempts:   c.Server.WebhookMaxAttempts,
+		serverChangelogRetention:   c.Server.ChangelogRetention.String(),
+		serverApplyBatchSize:       c.Server.ApplyBatchSize,
+		serverApplyBatchLinger:     c.Server.ApplyBatchLinger.String(),
+		serverReadyMaxLastContact:  c.Server.ReadyMaxLastContact.String(),
+		serverReadyMaxApplyLag:     c.Server.ReadyMaxApplyLag,
+
+		raftNodeId:             c.Raft.NodeId,
+		raftPort:               c.Raft.Port,
+		raftVolDir:             c.Raft.VolumeDir,
+		raftLog:                c.Raft.LogStore,
+		raftCache:              c.Raft.LogCacheSize,
+		raftJoin:               strings.Join(c.Raft.Join, ","),
+		raftSnapshotRetain:     c.Raft.SnapshotRetain,
+		raftSnapshotThreshold:  c.Raft.SnapshotThreshold,
+		raftSnapshotInterval:   c.Raft.SnapshotInterval.String(),
+		raftTrailingLogs:       c.Raft.TrailingLogs,
+		raftHeartbeatTimeout:   c.Raft.HeartbeatTimeout.String(),
+		raftElectionTimeout:    c.Raft.ElectionTimeout.String(),
+		raftCommitTimeout:      c.Raft.CommitTimeout.String(),
+		raftLeaderLeaseTimeout: c.Raft.LeaderLeaseTimeout.String(),
+		raftMaxAppendEntries:   c.Raft.MaxAppendEntries,
+		raftLogLevel:           c.Raft.LogLevel,
+
+		transportHost:    c.Transport.Host,
+		transportMaxPool: c.Transport.MaxPool,
+		transportTimeout: c.Transport.Timeout.String(),
+
+		storageEngine:         c.Storage.Engine,
+		storageGCInterval:     c.Storage.GCInterval.String(),
+		storageGCDiscardRatio: c.Storage.GCDiscardRatio,
+	}
+}
+
+// raftConfig raft settings of the node on top of the raft defaults
+func (c configRaft) raftConfig() *raft.Config {
+	conf := raft.DefaultConfig()
+	conf.LocalID = raft.ServerID(c.NodeId)
+	conf.SnapshotThreshold = c.SnapshotThreshold
+	conf.SnapshotInterval = c.SnapshotInterval
+	conf.TrailingLogs = c.TrailingLogs
+	conf.HeartbeatTimeout = c.HeartbeatTimeout
+	conf.ElectionTimeout = c.ElectionTimeout
+	conf.CommitTimeout = c.CommitTimeout
+	conf.LeaderLeaseTimeout = c.LeaderLeaseTimeout
+	conf.MaxAppendEntries = c.MaxAppendEntries
+	conf.LogLevel = c.LogLevel
+	return conf
+}
+
+// splitList values of a comma separated list option
+func splitList(value string) []string {
+	var values []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			values = append(values, item)
+		}
+	}
+	return values
+}
+
+func oneOf(value string, options ...string) bool {
+	for _, option := range options {
+		if value == option {
+			return true
+		}
+	}
+	return false
+}
+
+// redacted hides a secret option in the reported settings
+func redacted(secret string) string {
+	if secret == "" {
+		return ""
+	}
+	return "redacted"
+}
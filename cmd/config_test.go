@@ -0,0 +1,207 @@
+package main
+
+import (
+	"github.com/spf13/viper"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// required options without defaults
+var required = map[string]interface{}{
+	serverPort:       8080,
+	serverAdminToken: "secret",
+	raftNodeId:       "node1",
+	raftPort:         1111,
+	raftVolDir:       "/var/raft",
+}
+
+func TestParseConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		options map[string]interface{}
+		unset   []string
+		wantErr []string
+		check   func(t *testing.T, conf config)
+	}{
+		{
+			name: "defaults",
+			check: func(t *testing.T, conf config) {
+				if conf.Server.AdvertiseAddress != "http://127.0.0.1:8080" {
+					t.Errorf("advertise address = %q", conf.Server.AdvertiseAddress)
+				}
+				if conf.Raft.LogStore != "bolt" || conf.Storage.Engine != "badger" {
+					t.Errorf("engines = %s log and %s storage", conf.Raft.LogStore, conf.Storage.Engine)
+				}
+				if conf.Transport.MaxPool != defaultMaxPool || conf.Server.ApplyBatchSize != defaultApplyBatchSize {
+					t.Errorf("max pool = %d, apply batch size = %d", conf.Transport.MaxPool, conf.Server.ApplyBatchSize)
+				}
+				if len(conf.Raft.Join) != 0 {
+					t.Errorf("join = %v", conf.Raft.Join)
+				}
+			},
+		},
+		{
+			name: "overridden",
+			options: map[string]interface{}{
+				raftJoin:                   " http://node1:8080 ,https://node2:8443,",
+				serverDunningRetrySchedule: "1h,2h",
+				serverWriteTimeout:         "30s",
+				raftLog:                    "badger",
+				storageEngine:              "memory",
+			},
+			check: func(t *testing.T, conf config) {
+				if strings.Join(conf.Raft.Join, " ") != "http://node1:8080 https://node2:8443" {
+					t.Errorf("join = %q", conf.Raft.Join)
+				}
+				if len(conf.Server.DunningRetrySchedule) != 2 || conf.Server.DunningRetrySchedule[1] != 2*time.Hour {
+					t.Errorf("dunning retry schedule = %v", conf.Server.DunningRetrySchedule)
+				}
+				if conf.Server.WriteTimeout != 30*time.Second {
+					t.Errorf("write timeout = %s", conf.Server.WriteTimeout)
+				}
+			},
+		},
+		{
+			name:    "required options",
+			unset:   []string{serverAdminToken, raftNodeId, raftVolDir},
+			wantErr: []string{"server.admin_token: is required", "raft.node_id: is required", "raft.vol_dir: is required"},
+		},
+		{
+			name:    "ports",
+			options: map[string]interface{}{serverPort: 0, raftPort: 70000},
+			wantErr: []string{"server.port: must be a port, got 0", "raft.port: must be a port, got 70000"},
+		},
+		{
+			name:    "join url",
+			options: map[string]interface{}{raftJoin: "node1:8080"},
+			wantErr: []string{`raft.join: must be http or https urls of nodes, got "node1:8080"`},
+		},
+		{
+			name:    "advertise address with a path",
+			options: map[string]interface{}{serverAdvertiseAddress: "http://node1:8080/api"},
+			wantErr: []string{"server.advertise_address: must be http or https url without a path"},
+		},
+		{
+			name:    "engines",
+			options: map[string]interface{}{raftLog: "rocks", storageEngine: "rocks"},
+			wantErr: []string{`raft.log_store: unknown log store "rocks"`, `storage.engine: unknown engine "rocks"`},
+		},
+		{
+			name:    "unknown options",
+			options: map[string]interface{}{"server.prot": 8080, "raft.snapshots": 2},
+			wantErr: []string{"unknown config options: raft.snapshots, server.prot"},
+		},
+		{
+			name:    "processor timeout above write timeout",
+			options: map[string]interface{}{serverProcessorTimeout: "20s", serverWriteTimeout: "10s"},
+			wantErr: []string{"server.processor_timeout: must be below server.write_timeout"},
+		},
+		{
+			name:    "non positive values",
+			options: map[string]interface{}{serverReadTimeout: "0s", transportMaxPool: 0, serverApplyBatchSize: -1, storageGCDiscardRatio: 1.5},
+			wantErr: []string{
+				"server.read_timeout: must be positive",
+				"transport.max_pool: must be positive",
+				"server.apply_batch_size: must be positive",
+				"storage.gc_discard_ratio: must be between 0 and 1",
+			},
+		},
+		{
+			name:    "dunning retry schedule",
+			options: map[string]interface{}{serverDunningRetrySchedule: "1h,soon"},
+			wantErr: []string{"server.dunning_retry_schedule:"},
+		},
+		{
+			name:    "raft timeouts",
+			options: map[string]interface{}{raftLeaderLeaseTimeout: "5s", raftHeartbeatTimeout: "1s"},
+			wantErr: []string{"raft: LeaderLeaseTimeout (5s) cannot be larger than heartbeat timeout"},
+		},
+		{
+			name:    "log level",
+			options: map[string]interface{}{raftLogLevel: "LOUD"},
+			wantErr: []string{`raft.log_level: unknown level "LOUD"`},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := viper.New()
+			setDefaults(v)
+			for key, value := range required {
+				v.Set(key, value)
+			}
+			for _, key := range tt.unset {
+				v.Set(key, "")
+			}
+			for key, value := range tt.options {
+				v.Set(key, value)
+			}
+
+			conf, err := parseConfig(v)
+			if len(tt.wantErr) == 0 {
+				if err != nil {
+					t.Fatalf("parseConfig() error = %v", err)
+				}
+				if tt.check != nil {
+					tt.check(t, conf)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("parseConfig() succeeded, want %q", tt.wantErr)
+			}
+			for _, want := range tt.wantErr {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("parseConfig() error = %v, want %q", err, want)
+				}
+			}
+		})
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	file := `
+server:
+  port: 8080
+  admin_token: secret
+  write_timeout: 20s
+raft:
+  node_id: node1
+  port: 1111
+  vol_dir: /var/raft
+  snapshot_retain: 5
+transport:
+  max_pool: 7
+`
+	if err := os.WriteFile(path, []byte(file), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("SERVER_PORT", "9090")
+	t.Setenv("RAFT_JOIN", "http://node2:8080")
+
+	conf, _, err := loadConfig(path)
+	if err != nil {
+		t.Fatalf("loadConfig() error = %v", err)
+	}
+	if conf.Server.Port != 9090 {
+		t.Errorf("port = %d, want the environment 9090", conf.Server.Port)
+	}
+	if conf.Server.WriteTimeout != 20*time.Second || conf.Raft.SnapshotRetain != 5 || conf.Transport.MaxPool != 7 {
+		t.Errorf("options of the file = %s write timeout, %d snapshots, %d pool",
+			conf.Server.WriteTimeout, conf.Raft.SnapshotRetain, conf.Transport.MaxPool)
+	}
+	if len(conf.Raft.Join) != 1 || conf.Raft.Join[0] != "http://node2:8080" {
+		t.Errorf("join = %v", conf.Raft.Join)
+	}
+	if conf.settings()[serverAdminToken] != "redacted" {
+		t.Errorf("admin token is reported as %v", conf.settings()[serverAdminToken])
+	}
+
+	if _, _, err := loadConfig(filepath.Join(t.TempDir(), "missing.yaml")); err == nil {
+		t.Errorf("loadConfig() of a missing file succeeded")
+	}
+}
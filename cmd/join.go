@@ -0,0 +1,85 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	// joinRetryInterval is delay before the nodes to join
+	// through are tried once more.
+	joinRetryInterval = 2 * time.Second
+
+	// joinTimeout is how long a node is waited for to add this one.
+	joinTimeout = 10 * time.Second
+)
+
+// bootstrapsCluster tells whether a node without state starts the cluster. It is
+// the node without join addresses, or the one whose own address sorts first in
+// the join addresses when every node lists all of them. The others wait for it.
+func bootstrapsCluster(conf config) bool {
+	if len(conf.Raft.Join) == 0 {
+		return true
+	}
+
+	self := strings.TrimSuffix(conf.Server.AdvertiseAddress, "/")
+	first := ""
+	listed := false
+	for _, address := range conf.Raft.Join {
+		address = strings.TrimSuffix(address, "/")
+		if address == self {
+			listed = true
+		}
+		if first == "" || address < first {
+			first = address
+		}
+	}
+	return listed && first == self
+}
+
+// joinCluster asks the nodes in turn to add this node to their cluster until
+// the leader is among them. The node registers its HTTP address on the way.
+func joinCluster(conf config, raftAddr string) {
+	body, _ := json.Marshal(map[string]string{
+		"node_id":      conf.Raft.NodeId,
+		"raft_address": raftAddr,
+		"http_address": conf.Server.AdvertiseAddress,
+	})
+	client := &http.Client{Timeout: joinTimeout}
+
+	for {
+		for _, address := range conf.Raft.Join {
+			if strings.TrimSuffix(address, "/") == strings.TrimSuffix(conf.Server.AdvertiseAddress, "/") {
+				continue
+			}
+
+			err := join(client, address, body)
+			if err == nil {
+				log.Printf("joined cluster through %s", address)
+				return
+			}
+			log.Printf("error joining cluster through %s: %s", address, err.Error())
+		}
+		time.Sleep(joinRetryInterval)
+	}
+}
+
+func join(client *http.Client, address string, body []byte) error {
+	resp, err := client.Post(strings.TrimSuffix(address, "/")+"/raft/join", "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		message, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("status %d: %s", resp.StatusCode, strings.TrimSpace(string(message)))
+	}
+	return nil
+}
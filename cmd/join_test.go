@@ -0,0 +1,31 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestBootstrapsCluster(t *testing.T) {
+	all := []string{"http://127.0.0.1:2222", "http://127.0.0.1:2221/", "http://127.0.0.1:2223"}
+
+	tests := []struct {
+		name string
+		self string
+		join []string
+		want bool
+	}{
+		{name: "no join addresses", self: "http://127.0.0.1:2221", want: true},
+		{name: "first of the join addresses", self: "http://127.0.0.1:2221", join: all, want: true},
+		{name: "not first of the join addresses", self: "http://127.0.0.1:2222", join: all},
+		{name: "not in the join addresses", self: "http://127.0.0.1:2220", join: all},
+		{name: "joins through another node", self: "http://127.0.0.1:2222", join: []string{"http://127.0.0.1:2221"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conf := config{Server: configServer{AdvertiseAddress: tt.self}, Raft: configRaft{Join: tt.join}}
+			if got := bootstrapsCluster(conf); got != tt.want {
+				t.Errorf("bootstrapsCluster() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -1,129 +1,104 @@
 package main
 
 import (
+	"flag"
 	"fmt"
+	"github.com/KushnerykPavel/raft-test-project/internal/processor"
+	"github.com/KushnerykPavel/raft-test-project/internal/raftlog"
+	"github.com/KushnerykPavel/raft-test-project/internal/replication"
 	"github.com/KushnerykPavel/raft-test-project/internal/repo"
 	"github.com/KushnerykPavel/raft-test-project/internal/server"
-	"github.com/dgraph-io/badger/v2"
+	"github.com/KushnerykPavel/raft-test-project/internal/server/store_router"
+	"github.com/KushnerykPavel/raft-test-project/internal/storage"
+	"github.com/hashicorp/go-hclog"
 	"github.com/hashicorp/raft"
-	raftboltdb "github.com/hashicorp/raft-boltdb"
-	"github.com/spf13/viper"
 	"log"
 	"net"
 	"os"
-	"path/filepath"
-	"time"
-)
-
-// configRaft configuration for raft node
-type configRaft struct {
-	NodeId    string
-	Port      int
-	VolumeDir string
-}
-
-// configServer configuration for HTTP server
-type configServer struct {
-	Port int
-}
-
-// config configuration
-type config struct {
-	Server configServer
-	Raft   configRaft
-}
-
-const (
-	serverPort = "SERVER_PORT"
-
-	raftNodeId = "RAFT_NODE_ID"
-	raftPort   = "RAFT_PORT"
-	raftVolDir = "RAFT_VOL_DIR"
-)
-
-var confKeys = []string{
-	serverPort,
-
-	raftNodeId,
-	raftPort,
-	raftVolDir,
-}
-
-const (
-	// The maxPool controls how many connections we will pool.
-	maxPool = 3
-
-	// The timeout is used to apply I/O deadlines. For InstallSnapshot, we multiply
-	// the timeout by (SnapshotSize / TimeoutScale).
-	// https://github.com/hashicorp/raft/blob/v1.1.2/net_transport.go#L177-L181
-	tcpTimeout = 10 * time.Second
-
-	// The `retain` parameter controls how many
-	// snapshots are retained. Must be at least 1.
-	raftSnapShotRetain = 2
-
-	// raftLogCacheSize is the maximum number of logs to cache in-memory.
-	// This is used to reduce disk I/O for the recently committed entries.
-	raftLogCacheSize = 512
 )
 
 func main() {
-	var v = viper.New()
-	v.AutomaticEnv()
-	if err := v.BindEnv(confKeys...); err != nil {
+	configFile := flag.String("config", os.Getenv("CONFIG_FILE"), "path to YAML or TOML config file, environment variables override it")
+	flag.Parse()
+
+	conf, v, err := loadConfig(*configFile)
+	if err != nil {
 		log.Fatal(err)
 		return
 	}
 
-	conf := config{
-		Server: configServer{
-			Port: v.GetInt(serverPort),
-		},
-		Raft: configRaft{
-			NodeId:    v.GetString(raftNodeId),
-			Port:      v.GetInt(raftPort),
-			VolumeDir: v.GetString(raftVolDir),
-		},
-	}
+	log.Printf("%+v\n", conf.settings())
 
-	log.Printf("%+v\n", conf)
+	paymentProcessor, err := processor.New(conf.Server.Processor)
+	if err != nil {
+		log.Fatal(err)
+		return
+	}
 
-	// Preparing badgerDB
-	badgerOpt := badger.DefaultOptions(conf.Raft.VolumeDir)
-	badgerDB, err := badger.Open(badgerOpt)
+	// Preparing the FSM storage
+	fsmDB, err := storage.Open(conf.Storage.Engine, conf.Raft.VolumeDir)
 	if err != nil {
 		log.Fatal(err)
 		return
 	}
 
 	defer func() {
-		if err := badgerDB.Close(); err != nil {
-			_, _ = fmt.Fprintf(os.Stderr, "error close badgerDB: %s\n", err.Error())
+		if err := fsmDB.Close(); err != nil {
+			_, _ = fmt.Fprintf(os.Stderr, "error close %s storage: %s\n", conf.Storage.Engine, err.Error())
 		}
 	}()
 
-	var raftBinAddr = fmt.Sprintf("127.0.0.1:%d", conf.Raft.Port)
+	var raftBinAddr = net.JoinHostPort(conf.Transport.Host, fmt.Sprint(conf.Raft.Port))
 
-	raftConf := raft.DefaultConfig()
-	raftConf.LocalID = raft.ServerID(conf.Raft.NodeId)
-	raftConf.SnapshotThreshold = 1024
+	raftConf := conf.Raft.raftConfig()
+	// own logger, so its level is changed by the config reload
+	raftLogger := hclog.New(&hclog.LoggerOptions{
+		Name:   "raft",
+		Level:  hclog.LevelFromString(conf.Raft.LogLevel),
+		Output: os.Stderr,
+	})
+	raftConf.Logger = raftLogger
 
-	fsmStore := repo.NewBadger(badgerDB)
+	fsmStore := repo.NewFSM(fsmDB, conf.Server.ChangelogRetention)
 
-	store, err := raftboltdb.NewBoltStore(filepath.Join(conf.Raft.VolumeDir, "raft.dataRepo"))
-	if err != nil {
-		log.Fatal(err)
+	if err := fsmStore.MigrateMinorUnits(); err != nil {
+		log.Fatal("migrate minor units error: ", err)
 		return
 	}
 
-	// Wrap the store in a LogCache to improve performance.
-	cacheStore, err := raft.NewLogCache(raftLogCacheSize, store)
+	if err := fsmStore.MigrateOrderTransactions(); err != nil {
+		log.Fatal("migrate order transactions error: ", err)
+		return
+	}
+
+	if err := fsmStore.MigrateTransactionIndexes(); err != nil {
+		log.Fatal("migrate transaction indexes error: ", err)
+		return
+	}
+
+	logStore, err := raftlog.Open(conf.Raft.LogStore, conf.Raft.VolumeDir)
 	if err != nil {
 		log.Fatal(err)
 		return
 	}
 
-	snapshotStore, err := raft.NewFileSnapshotStore(conf.Raft.VolumeDir, raftSnapShotRetain, os.Stdout)
+	defer func() {
+		if err := logStore.Close(); err != nil {
+			_, _ = fmt.Fprintf(os.Stderr, "error close %s raft log: %s\n", conf.Raft.LogStore, err.Error())
+		}
+	}()
+
+	// Wrap the store in a LogCache to improve performance.
+	var cacheStore raft.LogStore = logStore
+	if conf.Raft.LogCacheSize > 0 {
+		cacheStore, err = raft.NewLogCache(conf.Raft.LogCacheSize, logStore)
+		if err != nil {
+			log.Fatal(err)
+			return
+		}
+	}
+
+	snapshotStore, err := raft.NewFileSnapshotStore(conf.Raft.VolumeDir, conf.Raft.SnapshotRetain, os.Stdout)
 	if err != nil {
 		log.Fatal(err)
 		return
@@ -135,31 +110,94 @@ func main() {
 		return
 	}
 
-	transport, err := raft.NewTCPTransport(raftBinAddr, tcpAddr, maxPool, tcpTimeout, os.Stdout)
+	transport, err := raft.NewTCPTransport(raftBinAddr, tcpAddr, conf.Transport.MaxPool, conf.Transport.Timeout, os.Stdout)
 	if err != nil {
 		log.Fatal("NewTCPTransport error: ", err)
 		return
 	}
 
-	raftServer, err := raft.NewRaft(raftConf, fsmStore, cacheStore, store, snapshotStore, transport)
+	// followers progress is recorded for the stats of the leader
+	replicationTransport := replication.NewTransport(transport)
+
+	raftServer, err := raft.NewRaft(raftConf, fsmStore, cacheStore, logStore, snapshotStore, replicationTransport)
 	if err != nil {
 		log.Fatal(err)
 		return
 	}
 
-	// always start single server as a leader
-	configuration := raft.Configuration{
-		Servers: []raft.Server{
-			{
-				ID:      raft.ServerID(conf.Raft.NodeId),
-				Address: transport.LocalAddr(),
+	hasState, err := raft.HasExistingState(logStore, logStore, snapshotStore)
+	if err != nil {
+		log.Fatal(err)
+		return
+	}
+
+	// a node with existing state takes the configuration from its log. A new node
+	// with join addresses starts empty and waits for the leader to add it,
+	// bootstrapping it as a single server leader would split the cluster.
+	// Only the first of the join addresses bootstraps when they list this node.
+	bootstrap := !hasState && bootstrapsCluster(conf)
+	if bootstrap {
+		// start single server as a leader
+		configuration := raft.Configuration{
+			Servers: []raft.Server{
+				{
+					ID:      raft.ServerID(conf.Raft.NodeId),
+					Address: transport.LocalAddr(),
+				},
 			},
-		},
+		}
+
+		raftServer.BootstrapCluster(configuration)
+	}
+
+	if len(conf.Raft.Join) > 0 && !bootstrap {
+		go joinCluster(conf, string(transport.LocalAddr()))
 	}
 
-	raftServer.BootstrapCluster(configuration)
+	settings := newLiveConfig(conf, raftServer, raftLogger)
+	if *configFile != "" {
+		settings.watch(v)
+	}
+
+	// the badger log keeps compacted entries in its value log until it is collected
+	var logCollector storage.Collector
+	if collector, ok := logStore.(storage.Collector); ok {
+		logCollector = collector
+	}
+
+	storeConf := store_router.Config{
+		NodeID:                     conf.Raft.NodeId,
+		RaftAddress:                string(transport.LocalAddr()),
+		HTTPAddress:                conf.Server.AdvertiseAddress,
+		DefaultMerchant:            conf.Server.DefaultMerchant,
+		IdempotencyRetention:       conf.Server.IdempotencyRetention,
+		AuthorizationTTL:           conf.Server.AuthorizationTTL,
+		AuthorizationSweepInterval: conf.Server.AuthorizationSweepInterval,
+		SchedulerInterval:          conf.Server.SchedulerInterval,
+		DunningRetrySchedule:       conf.Server.DunningRetrySchedule,
+		DunningMaxFailures:         conf.Server.DunningMaxFailures,
+		ProcessorTimeout:           conf.Server.ProcessorTimeout,
+		WebhookInterval:            conf.Server.WebhookInterval,
+		WebhookTimeout:             conf.Server.WebhookTimeout,
+		WebhookBackoff:             conf.Server.WebhookBackoff,
+		WebhookMaxAttempts:         conf.Server.WebhookMaxAttempts,
+		ApplyBatchSize:             conf.Server.ApplyBatchSize,
+		ApplyBatchLinger:           conf.Server.ApplyBatchLinger,
+		StorageGCInterval:          conf.Storage.GCInterval,
+		StorageGCDiscardRatio:      conf.Storage.GCDiscardRatio,
+		LogCollector:               logCollector,
+		ReadyMaxLastContact:        conf.Server.ReadyMaxLastContact,
+		ReadyMaxApplyLag:           conf.Server.ReadyMaxApplyLag,
+	}
+
+	srvConf := server.Config{
+		ReadTimeout:  conf.Server.ReadTimeout,
+		WriteTimeout: conf.Server.WriteTimeout,
+		Settings:     settings,
+		AdminToken:   conf.Server.AdminToken,
+	}
 
-	srv := server.New(fmt.Sprintf(":%d", conf.Server.Port), badgerDB, raftServer)
+	srv := server.New(fmt.Sprintf(":%d", conf.Server.Port), fsmStore, fsmDB, raftServer, replicationTransport, paymentProcessor, srvConf, storeConf)
 	if err := srv.Start(); err != nil {
 		log.Fatal("serve error: ", err)
 	}
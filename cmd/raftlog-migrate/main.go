@@ -0,0 +1,54 @@
+// Command raftlog-migrate copies the bolt raft log of a stopped node into
+// another log store. Start the node with RAFT_LOG_STORE set to the new store
+// afterwards, the bolt file is left as it was.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/KushnerykPavel/raft-test-project/internal/raftlog"
+	"log"
+	"os"
+)
+
+func main() {
+	dir := flag.String("dir", os.Getenv("RAFT_VOL_DIR"), "volume directory of the node, RAFT_VOL_DIR by default")
+	to := flag.String("to", raftlog.BadgerEngine, "log store to copy the bolt log into")
+	flag.Parse()
+
+	if *dir == "" {
+		log.Fatal("volume directory is not set")
+	}
+	if *to == raftlog.BoltEngine || *to == raftlog.MemoryEngine {
+		log.Fatalf("can't migrate into %s log store", *to)
+	}
+
+	copied, err := migrate(*dir, *to)
+	if err != nil {
+		log.Fatalf("migrate error after %d log entries: %s", copied, err)
+	}
+
+	log.Printf("copied %d log entries from bolt to %s", copied, *to)
+}
+
+func migrate(dir, to string) (int, error) {
+	from, err := raftlog.Open(raftlog.BoltEngine, dir)
+	if err != nil {
+		return 0, fmt.Errorf("error opening bolt log, is the node stopped? %w", err)
+	}
+	defer closeStore(raftlog.BoltEngine, from)
+
+	dest, err := raftlog.Open(to, dir)
+	if err != nil {
+		return 0, err
+	}
+	defer closeStore(to, dest)
+
+	return raftlog.Migrate(from, dest)
+}
+
+func closeStore(engine string, store raftlog.Store) {
+	if err := store.Close(); err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "error close %s raft log: %s\n", engine, err.Error())
+	}
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"fmt"
+	"github.com/fsnotify/fsnotify"
+	"github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/raft"
+	"github.com/spf13/viper"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// reloadableKeys options applied to the running node when the config file changes,
+// any other option takes effect after a restart
+var reloadableKeys = []string{
+	raftSnapshotThreshold,
+	raftSnapshotInterval,
+	raftTrailingLogs,
+	raftHeartbeatTimeout,
+	raftElectionTimeout,
+	raftLogLevel,
+}
+
+// liveConfig the configuration the node runs with
+type liveConfig struct {
+	mu     sync.RWMutex
+	conf   config
+	raft   *raft.Raft
+	logger hclog.Logger
+}
+
+func newLiveConfig(conf config, r *raft.Raft, logger hclog.Logger) *liveConfig {
+	return &liveConfig{
+		conf:   conf,
+		raft:   r,
+		logger: logger,
+	}
+}
+
+// Effective options the node runs with by their keys
+func (l *liveConfig) Effective() map[string]interface{} {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	return l.conf.settings()
+}
+
+// watch reloads the config file read by viper every time it is written
+func (l *liveConfig) watch(v *viper.Viper) {
+	v.OnConfigChange(func(event fsnotify.Event) {
+		l.reload(v)
+	})
+	v.WatchConfig()
+}
+
+// reload applies the runtime-safe options of the config file. An invalid
+// file is rejected as a whole, the node keeps running with the options it has.
+func (l *liveConfig) reload(v *viper.Viper) {
+	next, err := parseConfig(v)
+	if err != nil {
+		log.Printf("config reload rejected: %s", err)
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	applied := l.conf
+	applied.Raft.SnapshotThreshold = next.Raft.SnapshotThreshold
+	applied.Raft.SnapshotInterval = next.Raft.SnapshotInterval
+	applied.Raft.TrailingLogs = next.Raft.TrailingLogs
+	applied.Raft.HeartbeatTimeout = next.Raft.HeartbeatTimeout
+	applied.Raft.ElectionTimeout = next.Raft.ElectionTimeout
+	applied.Raft.LogLevel = next.Raft.LogLevel
+
+	err = l.raft.ReloadConfig(raft.ReloadableConfig{
+		TrailingLogs:      applied.Raft.TrailingLogs,
+		SnapshotInterval:  applied.Raft.SnapshotInterval,
+		SnapshotThreshold: applied.Raft.SnapshotThreshold,
+		HeartbeatTimeout:  applied.Raft.HeartbeatTimeout,
+		ElectionTimeout:   applied.Raft.ElectionTimeout,
+	})
+	if err != nil {
+		log.Printf("config reload rejected: %s", err)
+		return
+	}
+	l.logger.SetLevel(hclog.LevelFromString(applied.Raft.LogLevel))
+
+	if changed := diffSettings(l.conf.settings(), applied.settings()); len(changed) > 0 {
+		log.Printf("config reloaded: %s", strings.Join(changed, ", "))
+	}
+	if pending := diffSettings(applied.settings(), next.settings()); len(pending) > 0 {
+		log.Printf("config changes need a restart: %s", strings.Join(pending, ", "))
+	}
+	l.conf = applied
+}
+
+// diffSettings keys with different values, sorted
+func diffSettings(from, to map[string]interface{}) []string {
+	var changed []string
+	for key, value := range to {
+		if fmt.Sprint(from[key]) != fmt.Sprint(value) {
+			changed = append(changed, fmt.Sprintf("%s=%v", key, value))
+		}
+	}
+	sort.Strings(changed)
+	return changed
+}
@@ -2,15 +2,20 @@ package main
 
 import (
 	"encoding/json"
+	"flag"
 	"fmt"
 	"github.com/go-chi/chi/v5"
-	"io"
 	"log"
 	"net/http"
+	"os"
 	"strings"
+	"sync"
 	"time"
 )
 
+// defaultSeeds nodes asked for the cluster when FRONTEND_SEEDS is not set
+const defaultSeeds = "http://localhost:2221,http://localhost:2222,http://localhost:2223"
+
 type BackendState string
 
 var (
@@ -21,132 +26,253 @@ type BackendResponse struct {
 	State BackendState `json:"state"`
 }
 
-type follower struct {
-	Name string
-	URL  string
+// clusterNode node as listed by /v2/cluster/nodes
+type clusterNode struct {
+	ID          string `json:"id"`
+	HTTPAddress string `json:"http_address"`
+	Leader      bool   `json:"leader"`
 }
 
-var backendsList = []string{"http://localhost:2221", "http://localhost:2222", "http://localhost:2223"}
-var followerList = []follower{
-	{
-		Name: "node2",
-		URL:  "localhost:1112",
-	},
-	{
-		Name: "node3",
-		URL:  "localhost:1113",
-	},
+type clusterResponse struct {
+	Nodes []clusterNode `json:"nodes"`
 }
 
-func isLeader(addr string) bool {
-	resp, err := http.Get(fmt.Sprintf("%s/raft/stats", addr))
+var client = &http.Client{Timeout: 2 * time.Second}
+
+// cluster backends discovered from the nodes themselves, starting from the seeds
+type cluster struct {
+	seeds []string
+
+	mu       sync.RWMutex
+	backends []string
+	leader   string
+}
+
+func newCluster(seeds []string) *cluster {
+	return &cluster{
+		seeds:    seeds,
+		backends: seeds,
+	}
+}
+
+// run refreshes the backends every interval
+func (c *cluster) run(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		c.refresh()
+	}
+}
+
+// refresh asks the known backends, then the seeds, for the nodes of the cluster.
+// The first answer replaces the backends, nodes without HTTP address are skipped.
+func (c *cluster) refresh() {
+	for _, addr := range append(c.list(), c.seeds...) {
+		nodes, err := fetchNodes(addr)
+		if err != nil {
+			continue
+		}
+
+		var (
+			backends []string
+			leader   string
+		)
+		for _, node := range nodes {
+			if node.HTTPAddress == "" {
+				continue
+			}
+			backends = append(backends, strings.TrimSuffix(node.HTTPAddress, "/"))
+			if node.Leader {
+				leader = strings.TrimSuffix(node.HTTPAddress, "/")
+			}
+		}
+		if len(backends) == 0 {
+			continue
+		}
+
+		c.mu.Lock()
+		if strings.Join(c.backends, ",") != strings.Join(backends, ",") {
+			log.Printf("cluster backends: %s", strings.Join(backends, ", "))
+		}
+		c.backends, c.leader = backends, leader
+		c.mu.Unlock()
+		return
+	}
+	log.Print("no node answered the cluster discovery, keeping the backends")
+}
+
+func (c *cluster) list() []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return append([]string(nil), c.backends...)
+}
+
+// leaderBackend the leader when it still leads, otherwise the first backend
+// which says it is the leader
+func (c *cluster) leaderBackend() (string, bool) {
+	c.mu.RLock()
+	leader := c.leader
+	c.mu.RUnlock()
+	if leader != "" && isLeader(leader) {
+		return leader, true
+	}
+
+	for _, addr := range c.list() {
+		if isLeader(addr) {
+			c.mu.Lock()
+			c.leader = addr
+			c.mu.Unlock()
+			return addr, true
+		}
+	}
+	return "", false
+}
+
+func (c *cluster) availableBackend() (string, bool) {
+	for _, addr := range c.list() {
+		if isAvailable(addr) {
+			return addr, true
+		}
+	}
+	return "", false
+}
+
+func fetchNodes(addr string) ([]clusterNode, error) {
+	resp, err := client.Get(fmt.Sprintf("%s/v2/cluster/nodes", addr))
 	if err != nil {
-		return false
+		return nil, err
 	}
+	defer resp.Body.Close()
 	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("status %d", resp.StatusCode)
+	}
+
+	var response clusterResponse
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return nil, err
+	}
+	return response.Nodes, nil
+}
+
+func isLeader(addr string) bool {
+	resp, err := client.Get(fmt.Sprintf("%s/v2/raft/stats", addr))
+	if err != nil {
 		return false
 	}
 	defer resp.Body.Close()
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
+	if resp.StatusCode != http.StatusOK {
 		return false
 	}
+
 	var backendResponse BackendResponse
-	err = json.Unmarshal(body, &backendResponse)
-	if err != nil {
+	if err := json.NewDecoder(resp.Body).Decode(&backendResponse); err != nil {
 		return false
 	}
-	if backendResponse.State == LeaderBackendState {
-		return true
-	}
-
-	return false
+	return backendResponse.State == LeaderBackendState
 }
 
+// isAvailable the backend knows the leader and is not far behind it
 func isAvailable(addr string) bool {
-	resp, err := http.Get(fmt.Sprintf("%s/raft/stats", addr))
+	resp, err := client.Get(fmt.Sprintf("%s/readyz", addr))
 	if err != nil {
 		return false
 	}
+	defer resp.Body.Close()
 
-	if resp.StatusCode == http.StatusOK {
-		return true
-	}
-
-	return false
+	return resp.StatusCode == http.StatusOK
 }
 
 func main() {
-	router := chi.NewRouter()
+	seeds := flag.String("seeds", envOr("FRONTEND_SEEDS", defaultSeeds), "comma separated urls of cluster nodes to discover the cluster from")
+	refresh := flag.Duration("refresh", 10*time.Second, "how often the cluster nodes are discovered again")
+	flag.Parse()
+
+	var seedList []string
+	for _, seed := range strings.Split(*seeds, ",") {
+		if seed = strings.TrimSpace(seed); seed != "" {
+			seedList = append(seedList, strings.TrimSuffix(seed, "/"))
+		}
+	}
+	if len(seedList) == 0 {
+		log.Fatal("no seed nodes")
+	}
 
-	setupCluster(":2221", followerList)
+	c := newCluster(seedList)
+	c.refresh()
+	go c.run(*refresh)
 
-	router.Post("/api/pay", leaderProxy)
-	router.Post("/api/recurring", leaderProxy)
-	router.Get("/api/status/{order_id}", availableProxy)
+	router := chi.NewRouter()
+
+	router.Post("/raft/join", c.membershipProxy)
+	router.Post("/raft/remove", c.membershipProxy)
+	router.Post("/admin/merchants", c.leaderProxy)
+	router.Post("/api/pay", c.leaderProxy)
+	router.Post("/api/recurring", c.leaderProxy)
+	router.Post("/api/refund", c.leaderProxy)
+	router.Post("/api/authorize", c.leaderProxy)
+	router.Post("/api/capture", c.leaderProxy)
+	router.Post("/api/void", c.leaderProxy)
+	router.Get("/api/status/{order_id}", c.availableProxy)
+	router.Post("/api/subscriptions", c.leaderProxy)
+	router.Get("/api/subscriptions/{subscription_id}", c.availableProxy)
+	router.Get("/api/subscriptions/{subscription_id}/dunning", c.availableProxy)
+	router.Delete("/api/subscriptions/{subscription_id}", c.leaderProxy)
+	router.Put("/api/webhooks", c.leaderProxy)
+	router.Get("/api/webhooks", c.availableProxy)
+	router.Delete("/api/webhooks", c.leaderProxy)
+	router.Get("/api/webhooks/deliveries", c.availableProxy)
+	router.Get("/api/events", c.availableProxy)
+	router.Get("/api/transactions", c.availableProxy)
 	log.Print("frontend run")
 	http.ListenAndServe(":8080", router)
 }
 
-func setupCluster(masterAddress string, followersList []follower) {
-	url := fmt.Sprintf("http://localhost%s/raft/join", masterAddress)
-	method := "POST"
-	client := &http.Client{}
-
-	for _, f := range followerList {
-
-		payload := strings.NewReader(fmt.Sprintf(`{
- "node_id":"%s",
- "raft_address":"%s"
-}`, f.Name, f.URL))
-
-		req, err := http.NewRequest(method, url, payload)
-
-		if err != nil {
-			fmt.Println(err)
-			return
-		}
-		req.Header.Add("Content-Type", "application/json")
-
-		res, err := client.Do(req)
-		if err != nil {
-			fmt.Println(err)
-			return
-		}
-		defer res.Body.Close()
-
-		body, err := io.ReadAll(res.Body)
-		if err != nil {
-			fmt.Println(err)
-			return
-		}
-		fmt.Println(string(body))
-		time.Sleep(2 * time.Second)
+func envOr(name, fallback string) string {
+	if value := os.Getenv(name); value != "" {
+		return value
 	}
+	return fallback
 }
 
-func leaderProxy(w http.ResponseWriter, r *http.Request) {
-	for _, addr := range backendsList {
-		if isLeader(addr) {
-			url := fmt.Sprintf("%s%s", addr, r.URL.Path)
-			proxyRequest(url, w, r)
-			return
-		}
-	}
-	w.WriteHeader(http.StatusBadGateway)
+// membershipProxy sends join and remove to the leader and discovers the cluster
+// again, so the changed membership is used right away
+func (c *cluster) membershipProxy(w http.ResponseWriter, r *http.Request) {
+	c.leaderProxy(w, r)
+	c.refresh()
 }
 
-func availableProxy(w http.ResponseWriter, r *http.Request) {
-	for _, addr := range backendsList {
-		if isAvailable(addr) {
-			url := fmt.Sprintf("%s%s", addr, r.URL.Path)
-			proxyRequest(url, w, r)
-			return
-		}
+func (c *cluster) leaderProxy(w http.ResponseWriter, r *http.Request) {
+	addr, ok := c.leaderBackend()
+	if !ok {
+		// the leader may be a node the frontend does not know yet
+		c.refresh()
+		addr, ok = c.leaderBackend()
+	}
+	if !ok {
+		w.WriteHeader(http.StatusBadGateway)
+		return
 	}
-	w.WriteHeader(http.StatusBadGateway)
+
+	url := fmt.Sprintf("%s%s", addr, r.URL.RequestURI())
+	proxyRequest(url, w, r)
 }
 
+func (c *cluster) availableProxy(w http.ResponseWriter, r *http.Request) {
+	addr, ok := c.availableBackend()
+	if !ok {
+		c.refresh()
+		addr, ok = c.availableBackend()
+	}
+	if !ok {
+		w.WriteHeader(http.StatusBadGateway)
+		return
+	}
+
+	url := fmt.Sprintf("%s%s", addr, r.URL.RequestURI())
+	proxyRequest(url, w, r)
+}
 func proxyRequest(url string, w http.ResponseWriter, r *http.Request) {
 	proxyReq, err := http.NewRequest(r.Method, url, r.Body)
 	if err != nil {
@@ -179,6 +305,22 @@ func proxyRequest(url string, w http.ResponseWriter, r *http.Request) {
 	// Set the status code of the original response to the status code of the proxy response
 	w.WriteHeader(resp.StatusCode)
 
-	// Copy the body of the proxy response to the original response
-	io.Copy(w, resp.Body)
+	// Copy the body of the proxy response to the original response,
+	// flushing every chunk so streamed responses reach the client right away
+	flusher, _ := w.(http.Flusher)
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := resp.Body.Read(buf)
+		if n > 0 {
+			if _, werr := w.Write(buf[:n]); werr != nil {
+				return
+			}
+			if flusher != nil {
+				flusher.Flush()
+			}
+		}
+		if err != nil {
+			return
+		}
+	}
 }
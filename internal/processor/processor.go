@@ -0,0 +1,59 @@
+package processor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ErrTimeout processor did not answer in time, the outcome of the operation is unknown
+var ErrTimeout = errors.New("processor timeout")
+
+// Card data of the cardholder sent to the acquirer
+type Card struct {
+	Number    string
+	ExpiredAt string
+	Cvv       string
+}
+
+// Request operation on the processor.
+// Reference is our transaction id, the processor uses it as idempotency key.
+type Request struct {
+	Reference string
+	Card      *Card
+	Amount    int64 // minor units of the currency
+	Currency  string
+
+	// OriginalReference processor reference of the authorization captured or voided,
+	// or of the charge refunded
+	OriginalReference string
+}
+
+// Response outcome of the operation
+type Response struct {
+	Approved  bool
+	Reference string
+	Code      string
+	Message   string
+}
+
+// Processor talks to the acquirer. It is called only by the leader,
+// the outcome is replicated as part of the transaction.
+type Processor interface {
+	Authorize(ctx context.Context, req *Request) (*Response, error)
+	Capture(ctx context.Context, req *Request) (*Response, error)
+	Refund(ctx context.Context, req *Request) (*Response, error)
+	Void(ctx context.Context, req *Request) (*Response, error)
+}
+
+// SimulatorName name of the in-process simulator in the configuration
+const SimulatorName = "simulator"
+
+// New creates processor by its configured name
+func New(name string) (Processor, error) {
+	switch name {
+	case SimulatorName:
+		return NewSimulator(), nil
+	}
+	return nil, fmt.Errorf("unknown processor %q", name)
+}
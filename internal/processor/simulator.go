@@ -0,0 +1,63 @@
+package processor
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+)
+
+// Test card numbers of the simulator. Any other valid card number is approved.
+const (
+	DeclineCardNumber           = "4000000000000002"
+	InsufficientFundsCardNumber = "4000000000009995"
+	TimeoutCardNumber           = "4000000000000119"
+)
+
+// Simulator in-process processor with deterministic outcomes by card number.
+// Operations without a card (capture, refund, void) are always approved.
+type Simulator struct{}
+
+func NewSimulator() *Simulator {
+	return &Simulator{}
+}
+
+// reference is derived from our reference, so a retried request gets the same one
+func (s *Simulator) reference(operation string, req *Request) string {
+	h := sha1.Sum([]byte(operation + "_" + req.Reference))
+	return "sim_" + hex.EncodeToString(h[:8])
+}
+
+func (s *Simulator) Authorize(ctx context.Context, req *Request) (*Response, error) {
+	if req.Card == nil {
+		return nil, fmt.Errorf("card is required for authorization")
+	}
+
+	switch req.Card.Number {
+	case DeclineCardNumber:
+		return &Response{Approved: false, Reference: s.reference("authorize", req), Code: "05", Message: "do not honor"}, nil
+	case InsufficientFundsCardNumber:
+		return &Response{Approved: false, Reference: s.reference("authorize", req), Code: "51", Message: "insufficient funds"}, nil
+	case TimeoutCardNumber:
+		<-ctx.Done()
+		return nil, ErrTimeout
+	}
+
+	return s.approve("authorize", req), nil
+}
+
+func (s *Simulator) Capture(ctx context.Context, req *Request) (*Response, error) {
+	return s.approve("capture", req), nil
+}
+
+func (s *Simulator) Refund(ctx context.Context, req *Request) (*Response, error) {
+	return s.approve("refund", req), nil
+}
+
+func (s *Simulator) Void(ctx context.Context, req *Request) (*Response, error) {
+	return s.approve("void", req), nil
+}
+
+func (s *Simulator) approve(operation string, req *Request) *Response {
+	return &Response{Approved: true, Reference: s.reference(operation, req), Code: "00", Message: "approved"}
+}
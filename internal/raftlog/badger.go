@@ -0,0 +1,226 @@
+package raftlog
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"github.com/dgraph-io/badger/v2"
+	"github.com/hashicorp/go-msgpack/codec"
+	"github.com/hashicorp/raft"
+)
+
+var (
+	// logPrefix prefix of log entries, followed by the big endian index
+	logPrefix = []byte("log/")
+	// stablePrefix prefix of the stable store keys
+	stablePrefix = []byte("stable/")
+)
+
+// deleteChunk log entries deleted in one badger transaction
+const deleteChunk = 1000
+
+// BadgerStore raft log and stable store on badger. Entries are encoded
+// with msgpack, the same as raft-boltdb does.
+type BadgerStore struct {
+	db *badger.DB
+}
+
+// OpenBadger opens badger log store in the directory
+func OpenBadger(dir string) (*BadgerStore, error) {
+	db, err := badger.Open(badger.DefaultOptions(dir).WithSyncWrites(true))
+	if err != nil {
+		return nil, err
+	}
+	return &BadgerStore{db: db}, nil
+}
+
+func (s *BadgerStore) Close() error {
+	return s.db.Close()
+}
+
+// FirstIndex returns the first index of the log, zero for empty log
+func (s *BadgerStore) FirstIndex() (uint64, error) {
+	return s.edgeIndex(false)
+}
+
+// LastIndex returns the last index of the log, zero for empty log
+func (s *BadgerStore) LastIndex() (uint64, error) {
+	return s.edgeIndex(true)
+}
+
+func (s *BadgerStore) edgeIndex(last bool) (uint64, error) {
+	var index uint64
+	err := s.db.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.PrefetchValues = false
+		opts.Reverse = last
+		it := txn.NewIterator(opts)
+		defer it.Close()
+
+		seek := logPrefix
+		if last {
+			seek = append(append([]byte(nil), logPrefix...), 0xff)
+		}
+		it.Seek(seek)
+		if it.ValidForPrefix(logPrefix) {
+			index = logIndex(it.Item().Key())
+		}
+		return nil
+	})
+	return index, err
+}
+
+func (s *BadgerStore) GetLog(index uint64, log *raft.Log) error {
+	return s.db.View(func(txn *badger.Txn) error {
+		item, err := txn.Get(logKey(index))
+		if errors.Is(err, badger.ErrKeyNotFound) {
+			return raft.ErrLogNotFound
+		}
+		if err != nil {
+			return err
+		}
+
+		return item.Value(func(val []byte) error {
+			return codec.NewDecoder(bytes.NewReader(val), &codec.MsgpackHandle{}).Decode(log)
+		})
+	})
+}
+
+func (s *BadgerStore) StoreLog(log *raft.Log) error {
+	return s.StoreLogs([]*raft.Log{log})
+}
+
+func (s *BadgerStore) StoreLogs(logs []*raft.Log) error {
+	return s.db.Update(func(txn *badger.Txn) error {
+		for _, log := range logs {
+			var buf bytes.Buffer
+			if err := codec.NewEncoder(&buf, &codec.MsgpackHandle{}).Encode(log); err != nil {
+				return err
+			}
+			if err := txn.Set(logKey(log.Index), buf.Bytes()); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// DeleteRange deletes log entries from first to last inclusive. Large ranges are
+// deleted in several transactions, in the order which keeps the log contiguous
+// if the node stops in the middle: from the head when compacting, from the tail
+// when removing conflicting entries.
+func (s *BadgerStore) DeleteRange(first, last uint64) error {
+	var keys [][]byte
+	err := s.db.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.PrefetchValues = false
+		it := txn.NewIterator(opts)
+		defer it.Close()
+
+		for it.Seek(logKey(first)); it.ValidForPrefix(logPrefix); it.Next() {
+			if logIndex(it.Item().Key()) > last {
+				break
+			}
+			keys = append(keys, it.Item().KeyCopy(nil))
+		}
+		return nil
+	})
+	if err != nil || len(keys) == 0 {
+		return err
+	}
+
+	lastIndex, err := s.LastIndex()
+	if err != nil {
+		return err
+	}
+	if lastIndex <= last {
+		for i, j := 0, len(keys)-1; i < j; i, j = i+1, j-1 {
+			keys[i], keys[j] = keys[j], keys[i]
+		}
+	}
+
+	for len(keys) > 0 {
+		chunk := keys[:min(deleteChunk, len(keys))]
+		keys = keys[len(chunk):]
+
+		err := s.db.Update(func(txn *badger.Txn) error {
+			for _, key := range chunk {
+				if err := txn.Delete(key); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CollectGarbage rewrites value log files until none has enough stale data.
+// Entries deleted by the log compaction stay in the value log until then.
+func (s *BadgerStore) CollectGarbage(discardRatio float64) (int, error) {
+	rewritten := 0
+	for {
+		err := s.db.RunValueLogGC(discardRatio)
+		if errors.Is(err, badger.ErrNoRewrite) {
+			return rewritten, nil
+		}
+		if err != nil {
+			return rewritten, err
+		}
+		rewritten++
+	}
+}
+
+func (s *BadgerStore) Set(key []byte, val []byte) error {
+	return s.db.Update(func(txn *badger.Txn) error {
+		return txn.Set(stableKey(key), val)
+	})
+}
+
+func (s *BadgerStore) Get(key []byte) ([]byte, error) {
+	var value []byte
+	err := s.db.View(func(txn *badger.Txn) error {
+		item, err := txn.Get(stableKey(key))
+		if errors.Is(err, badger.ErrKeyNotFound) {
+			return ErrKeyNotFound
+		}
+		if err != nil {
+			return err
+		}
+		value, err = item.ValueCopy(nil)
+		return err
+	})
+	return value, err
+}
+
+func (s *BadgerStore) SetUint64(key []byte, val uint64) error {
+	buf := make([]byte, 8)
+	binary.BigEndian.PutUint64(buf, val)
+	return s.Set(key, buf)
+}
+
+func (s *BadgerStore) GetUint64(key []byte) (uint64, error) {
+	val, err := s.Get(key)
+	if err != nil {
+		return 0, err
+	}
+	return binary.BigEndian.Uint64(val), nil
+}
+
+func logKey(index uint64) []byte {
+	key := make([]byte, len(logPrefix)+8)
+	copy(key, logPrefix)
+	binary.BigEndian.PutUint64(key[len(logPrefix):], index)
+	return key
+}
+
+func logIndex(key []byte) uint64 {
+	return binary.BigEndian.Uint64(key[len(logPrefix):])
+}
+
+func stableKey(key []byte) []byte {
+	return append(append([]byte(nil), stablePrefix...), key...)
+}
@@ -0,0 +1,76 @@
+package raftlog
+
+import (
+	"errors"
+	"fmt"
+	"github.com/hashicorp/raft"
+)
+
+// migrateChunk log entries copied in one write
+const migrateChunk = 256
+
+// stableKeys keys raft keeps in the stable store
+var stableKeys = [][]byte{
+	[]byte("CurrentTerm"),
+	[]byte("LastVoteTerm"),
+	[]byte("LastVoteCand"),
+}
+
+// Migrate copies the log and the stable keys into an empty store.
+// It returns how many log entries were copied.
+func Migrate(from, to Store) (int, error) {
+	last, err := to.LastIndex()
+	if err != nil {
+		return 0, err
+	}
+	if last != 0 {
+		return 0, fmt.Errorf("destination log is not empty, its last index is %d", last)
+	}
+
+	for _, key := range stableKeys {
+		val, err := from.Get(key)
+		if err != nil && err.Error() == ErrKeyNotFound.Error() {
+			continue
+		}
+		if err != nil {
+			return 0, fmt.Errorf("error reading %s: %w", key, err)
+		}
+		if err := to.Set(key, val); err != nil {
+			return 0, fmt.Errorf("error writing %s: %w", key, err)
+		}
+	}
+
+	first, err := from.FirstIndex()
+	if err != nil {
+		return 0, err
+	}
+	last, err = from.LastIndex()
+	if err != nil {
+		return 0, err
+	}
+	if last == 0 {
+		return 0, nil
+	}
+
+	copied := 0
+	chunk := make([]*raft.Log, 0, migrateChunk)
+	for index := first; index <= last; index++ {
+		log := &raft.Log{}
+		if err := from.GetLog(index, log); err != nil {
+			if errors.Is(err, raft.ErrLogNotFound) {
+				return copied, fmt.Errorf("log entry %d is missing, the log has a gap", index)
+			}
+			return copied, err
+		}
+
+		chunk = append(chunk, log)
+		if len(chunk) == migrateChunk || index == last {
+			if err := to.StoreLogs(chunk); err != nil {
+				return copied, err
+			}
+			copied += len(chunk)
+			chunk = chunk[:0]
+		}
+	}
+	return copied, nil
+}
@@ -0,0 +1,64 @@
+package raftlog
+
+import (
+	"errors"
+	"fmt"
+	"github.com/boltdb/bolt"
+	"github.com/hashicorp/raft"
+	raftboltdb "github.com/hashicorp/raft-boltdb"
+	"path/filepath"
+	"time"
+)
+
+// ErrKeyNotFound missing key of the stable store.
+// Raft tells it apart from other errors by the message.
+var ErrKeyNotFound = errors.New("not found")
+
+// Store raft log and stable store of the node
+type Store interface {
+	raft.LogStore
+	raft.StableStore
+	Close() error
+}
+
+// Engine names in the configuration
+const (
+	BoltEngine   = "bolt"
+	BadgerEngine = "badger"
+	MemoryEngine = "memory"
+)
+
+const (
+	// boltFile file of the bolt log in the volume directory
+	boltFile = "raft.dataRepo"
+	// badgerDir directory of the badger log in the volume directory,
+	// the volume directory itself holds the FSM storage
+	badgerDir = "raft-log"
+)
+
+// Open opens the log store of the configured engine in the volume directory
+func Open(engine, dir string) (Store, error) {
+	switch engine {
+	case BoltEngine:
+		return raftboltdb.New(raftboltdb.Options{
+			Path: filepath.Join(dir, boltFile),
+			// fail instead of waiting for the node which holds the file
+			BoltOptions: &bolt.Options{Timeout: time.Second},
+		})
+	case BadgerEngine:
+		return OpenBadger(filepath.Join(dir, badgerDir))
+	case MemoryEngine:
+		return memoryStore{InmemStore: raft.NewInmemStore()}, nil
+	}
+	return nil, fmt.Errorf("unknown raft log store %q", engine)
+}
+
+// memoryStore keeps the log in memory, for tests. The node forgets
+// its log and term when it stops.
+type memoryStore struct {
+	*raft.InmemStore
+}
+
+func (memoryStore) Close() error {
+	return nil
+}
@@ -0,0 +1,197 @@
+package raftlog
+
+import (
+	"errors"
+	"fmt"
+	"github.com/hashicorp/raft"
+	"testing"
+)
+
+// openLog opens a store of the engine with entries first to last
+func openLog(t *testing.T, engine string, first, last uint64) Store {
+	t.Helper()
+
+	s, err := Open(engine, t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = s.Close() })
+
+	var logs []*raft.Log
+	for index := first; index <= last && index > 0; index++ {
+		logs = append(logs, &raft.Log{Index: index, Term: 1, Type: raft.LogCommand, Data: []byte(fmt.Sprint(index))})
+	}
+	if len(logs) > 0 {
+		if err := s.StoreLogs(logs); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return s
+}
+
+// indexes returns the first and the last index of the log and checks
+// every entry between them is there
+func indexes(t *testing.T, s Store) (uint64, uint64) {
+	t.Helper()
+
+	first, err := s.FirstIndex()
+	if err != nil {
+		t.Fatal(err)
+	}
+	last, err := s.LastIndex()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for index := first; index <= last && index > 0; index++ {
+		var log raft.Log
+		if err := s.GetLog(index, &log); err != nil {
+			t.Fatalf("GetLog(%d) error = %v", index, err)
+		}
+		if log.Index != index || string(log.Data) != fmt.Sprint(index) {
+			t.Errorf("GetLog(%d) = index %d data %q", index, log.Index, log.Data)
+		}
+	}
+	return first, last
+}
+
+func TestBadgerDeleteRange(t *testing.T) {
+	tests := []struct {
+		name      string
+		last      uint64
+		from, to  uint64
+		wantFirst uint64
+		wantLast  uint64
+	}{
+		{name: "compaction", last: 10, from: 1, to: 4, wantFirst: 5, wantLast: 10},
+		{name: "conflicting tail", last: 10, from: 7, to: 10, wantFirst: 1, wantLast: 6},
+		{name: "tail beyond the last", last: 10, from: 7, to: 20, wantFirst: 1, wantLast: 6},
+		{name: "whole log", last: 10, from: 1, to: 10},
+		{name: "nothing in the range", last: 10, from: 11, to: 20, wantFirst: 1, wantLast: 10},
+		{name: "compaction over chunks", last: 2*deleteChunk + 10, from: 1, to: 2*deleteChunk + 5, wantFirst: 2*deleteChunk + 6, wantLast: 2*deleteChunk + 10},
+		{name: "tail over chunks", last: 2*deleteChunk + 10, from: 6, to: 2*deleteChunk + 10, wantFirst: 1, wantLast: 5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := openLog(t, BadgerEngine, 1, tt.last)
+
+			if err := s.DeleteRange(tt.from, tt.to); err != nil {
+				t.Fatalf("DeleteRange(%d, %d) error = %v", tt.from, tt.to, err)
+			}
+			first, last := indexes(t, s)
+			if first != tt.wantFirst || last != tt.wantLast {
+				t.Errorf("log = %d..%d, want %d..%d", first, last, tt.wantFirst, tt.wantLast)
+			}
+
+			if _, err := s.(*BadgerStore).CollectGarbage(0.5); err != nil {
+				t.Errorf("CollectGarbage() after DeleteRange error = %v", err)
+			}
+
+			var log raft.Log
+			if tt.from <= tt.last {
+				if err := s.GetLog(tt.from, &log); !errors.Is(err, raft.ErrLogNotFound) {
+					t.Errorf("GetLog(%d) error = %v, want %v", tt.from, err, raft.ErrLogNotFound)
+				}
+			}
+		})
+	}
+}
+
+func TestBadgerStable(t *testing.T) {
+	s := openLog(t, BadgerEngine, 0, 0)
+
+	if _, err := s.Get([]byte("CurrentTerm")); err == nil || err.Error() != ErrKeyNotFound.Error() {
+		t.Errorf("Get() of a missing key error = %v, want %v", err, ErrKeyNotFound)
+	}
+	if _, err := s.GetUint64([]byte("CurrentTerm")); err == nil || err.Error() != ErrKeyNotFound.Error() {
+		t.Errorf("GetUint64() of a missing key error = %v, want %v", err, ErrKeyNotFound)
+	}
+
+	if err := s.SetUint64([]byte("CurrentTerm"), 7); err != nil {
+		t.Fatal(err)
+	}
+	if term, err := s.GetUint64([]byte("CurrentTerm")); err != nil || term != 7 {
+		t.Errorf("GetUint64() = %d, %v, want 7", term, err)
+	}
+
+	// stable keys are not log entries
+	if first, last := indexes(t, s); first != 0 || last != 0 {
+		t.Errorf("log of the stable store = %d..%d, want empty", first, last)
+	}
+}
+
+func TestMigrate(t *testing.T) {
+	tests := []struct {
+		name       string
+		from, to   string
+		first      uint64
+		last       uint64
+		stable     bool
+		destLast   uint64
+		wantCopied int
+		wantErr    bool
+	}{
+		{name: "bolt to badger", from: BoltEngine, to: BadgerEngine, first: 1, last: 10, stable: true, wantCopied: 10},
+		{name: "badger to bolt", from: BadgerEngine, to: BoltEngine, first: 1, last: 10, stable: true, wantCopied: 10},
+		{name: "compacted log", from: BoltEngine, to: BadgerEngine, first: 50, last: 60, stable: true, wantCopied: 11},
+		{name: "over chunks", from: MemoryEngine, to: BadgerEngine, first: 1, last: 2*migrateChunk + 3, wantCopied: 2*migrateChunk + 3},
+		{name: "empty log", from: BoltEngine, to: BadgerEngine, stable: true},
+		{name: "destination not empty", from: BoltEngine, to: BadgerEngine, first: 1, last: 10, destLast: 3, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			from := openLog(t, tt.from, tt.first, tt.last)
+			to := openLog(t, tt.to, 1, tt.destLast)
+			if tt.stable {
+				if err := from.SetUint64([]byte("CurrentTerm"), 3); err != nil {
+					t.Fatal(err)
+				}
+				if err := from.Set([]byte("LastVoteCand"), []byte("node1")); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			copied, err := Migrate(from, to)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Migrate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if copied != tt.wantCopied {
+				t.Errorf("Migrate() copied %d, want %d", copied, tt.wantCopied)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			first, last := indexes(t, to)
+			if first != tt.first || last != tt.last {
+				t.Errorf("migrated log = %d..%d, want %d..%d", first, last, tt.first, tt.last)
+			}
+
+			if !tt.stable {
+				return
+			}
+			if term, err := to.GetUint64([]byte("CurrentTerm")); err != nil || term != 3 {
+				t.Errorf("migrated term = %d, %v, want 3", term, err)
+			}
+			if candidate, err := to.Get([]byte("LastVoteCand")); err != nil || string(candidate) != "node1" {
+				t.Errorf("migrated candidate = %q, %v, want node1", candidate, err)
+			}
+			if _, err := to.Get([]byte("LastVoteTerm")); err == nil {
+				t.Errorf("missing key is migrated")
+			}
+		})
+	}
+}
+
+func TestMigrateGap(t *testing.T) {
+	from := openLog(t, MemoryEngine, 1, 10)
+	if err := from.DeleteRange(4, 5); err != nil {
+		t.Fatal(err)
+	}
+	to := openLog(t, BadgerEngine, 0, 0)
+
+	if _, err := Migrate(from, to); err == nil {
+		t.Fatalf("Migrate() of a log with a gap succeeded")
+	}
+}
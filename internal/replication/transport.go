@@ -0,0 +1,158 @@
+package replication
+
+import (
+	"github.com/hashicorp/raft"
+	"io"
+	"sync"
+	"time"
+)
+
+// Progress of a follower as seen by the leader
+type Progress struct {
+	ID      raft.ServerID
+	Address raft.ServerAddress
+	// Term of the leader which recorded the progress
+	Term uint64
+	// MatchIndex highest log index known to be replicated to the follower
+	MatchIndex uint64
+	// LastContact time of the last successful response of the follower
+	LastContact time.Time
+}
+
+// Transport raft transport which records the progress of the followers.
+// The raft library keeps the match index of a follower to itself, so the
+// leader records it from the responses to AppendEntries and InstallSnapshot.
+type Transport struct {
+	raft.Transport
+
+	mu       sync.RWMutex
+	progress map[raft.ServerID]*Progress
+}
+
+func NewTransport(transport raft.Transport) *Transport {
+	return &Transport{
+		Transport: transport,
+		progress:  make(map[raft.ServerID]*Progress),
+	}
+}
+
+// Progress of the follower, false when nothing was replicated to it yet
+func (t *Transport) Progress(id raft.ServerID) (Progress, bool) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	progress, ok := t.progress[id]
+	if !ok {
+		return Progress{}, false
+	}
+	return *progress, true
+}
+
+// Close closes the wrapped transport, raft calls it on shutdown
+func (t *Transport) Close() error {
+	if closer, ok := t.Transport.(raft.WithClose); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+func (t *Transport) AppendEntries(id raft.ServerID, target raft.ServerAddress, args *raft.AppendEntriesRequest, resp *raft.AppendEntriesResponse) error {
+	if err := t.Transport.AppendEntries(id, target, args, resp); err != nil {
+		return err
+	}
+	t.appended(id, target, args, resp)
+	return nil
+}
+
+func (t *Transport) AppendEntriesPipeline(id raft.ServerID, target raft.ServerAddress) (raft.AppendPipeline, error) {
+	inner, err := t.Transport.AppendEntriesPipeline(id, target)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &pipeline{
+		AppendPipeline: inner,
+		consumer:       make(chan raft.AppendFuture),
+		done:           make(chan struct{}),
+	}
+	go p.run(func(args *raft.AppendEntriesRequest, resp *raft.AppendEntriesResponse) {
+		t.appended(id, target, args, resp)
+	})
+	return p, nil
+}
+
+func (t *Transport) InstallSnapshot(id raft.ServerID, target raft.ServerAddress, args *raft.InstallSnapshotRequest, resp *raft.InstallSnapshotResponse, data io.Reader) error {
+	if err := t.Transport.InstallSnapshot(id, target, args, resp, data); err != nil {
+		return err
+	}
+	if resp.Success {
+		t.record(id, target, args.Term, args.LastLogIndex, true)
+	}
+	return nil
+}
+
+// appended records the response to AppendEntries. A heartbeat carries
+// no entries and tells nothing of the log of the follower.
+func (t *Transport) appended(id raft.ServerID, target raft.ServerAddress, args *raft.AppendEntriesRequest, resp *raft.AppendEntriesResponse) {
+	if !resp.Success {
+		return
+	}
+	heartbeat := len(args.Entries) == 0 && args.PrevLogEntry == 0
+	t.record(id, target, args.Term, args.PrevLogEntry+uint64(len(args.Entries)), !heartbeat)
+}
+
+func (t *Transport) record(id raft.ServerID, target raft.ServerAddress, term, index uint64, matched bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	progress, ok := t.progress[id]
+	if !ok || progress.Term != term {
+		// the match index of another term may be truncated since
+		progress = &Progress{ID: id, Term: term}
+		t.progress[id] = progress
+	}
+	progress.Address = target
+	progress.LastContact = time.Now()
+	if matched && index > progress.MatchIndex {
+		progress.MatchIndex = index
+	}
+}
+
+// pipeline passes the responses of the wrapped pipeline to raft, recording them on the way
+type pipeline struct {
+	raft.AppendPipeline
+
+	consumer  chan raft.AppendFuture
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+func (p *pipeline) Consumer() <-chan raft.AppendFuture {
+	return p.consumer
+}
+
+func (p *pipeline) Close() error {
+	p.closeOnce.Do(func() { close(p.done) })
+	return p.AppendPipeline.Close()
+}
+
+func (p *pipeline) run(appended func(args *raft.AppendEntriesRequest, resp *raft.AppendEntriesResponse)) {
+	responses := p.AppendPipeline.Consumer()
+	for {
+		select {
+		case <-p.done:
+			return
+		case future := <-responses:
+			// futures are consumed once the response arrived, Error does not block
+			if future.Error() == nil {
+				appended(future.Request(), future.Response())
+			}
+
+			select {
+			case p.consumer <- future:
+			case <-p.done:
+				return
+			}
+		}
+	}
+}
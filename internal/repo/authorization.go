@@ -0,0 +1,79 @@
+package repo
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// AuthorizationIndex entry of open authorization, written by the FSM
+// next to the order and removed once the hold is released.
+type AuthorizationIndex struct {
+	OrderKey      string `json:"order_key"`
+	OrderID       string `json:"order_id"`
+	TransactionID string `json:"transaction_id"`
+	ExpiresAt     int64  `json:"expires_at"`
+}
+
+type AuthorizeResponse struct {
+	TransactionID string             `json:"transaction_id"`
+	Status        TransactionStatus  `json:"status"`
+	Processor     *ProcessorResponse `json:"processor,omitempty"`
+	Token         string             `json:"token,omitempty"`
+	ExpiresAt     int64              `json:"expires_at,omitempty"`
+	Addr          string             `json:"addr"`
+}
+
+func (rd *AuthorizeResponse) Render(w http.ResponseWriter, r *http.Request) error {
+	return nil
+}
+
+type CaptureRequest struct {
+	OrderID string `json:"order_id"`
+	// Amount to capture, empty captures the full authorized amount.
+	// Currency is required together with the amount.
+	Amount   json.Number `json:"amount"`
+	Currency string      `json:"currency"`
+
+	// AmountMinor amount in minor units of the currency, set by Bind
+	AmountMinor int64 `json:"-"`
+}
+
+func (p *CaptureRequest) Bind(r *http.Request) error {
+	errs := ValidationErrors{}
+	errs.Add("order_id", ValidateOrderID(p.OrderID))
+
+	if p.Amount != "" || p.Currency != "" {
+		errs.Add("currency", ValidateCurrency(p.Currency))
+
+		amount, err := ValidateAmount(p.Amount, p.Currency)
+		errs.Add("amount", err)
+		p.AmountMinor = amount
+	}
+
+	return errs.Err()
+}
+
+type VoidRequest struct {
+	OrderID string `json:"order_id"`
+}
+
+func (p *VoidRequest) Bind(r *http.Request) error {
+	errs := ValidationErrors{}
+	errs.Add("order_id", ValidateOrderID(p.OrderID))
+
+	return errs.Err()
+}
+
+type HoldResponse struct {
+	TransactionID string             `json:"transaction_id"`
+	Status        TransactionStatus  `json:"status"`
+	Processor     *ProcessorResponse `json:"processor,omitempty"`
+	Amount        string             `json:"amount"`
+	AmountMinor   int64              `json:"amount_minor"`
+	Currency      string             `json:"currency"`
+	Addr          string             `json:"addr"`
+}
+
+func (rd *HoldResponse) Render(w http.ResponseWriter, r *http.Request) error {
+	return nil
+}
@@ -0,0 +1,57 @@
+package repo
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/hashicorp/raft"
+	"strings"
+)
+
+// batchOperation command carrying several commands in one log entry.
+// Its value is the list of the commands.
+const batchOperation = "BATCH"
+
+// BatchResponse responses of the commands of a batch, in the order of the commands
+type BatchResponse struct {
+	Responses []*ApplyResponse
+}
+
+// NewBatchPayload wraps the commands into one command
+func NewBatchPayload(commands []CommandPayload) CommandPayload {
+	return CommandPayload{
+		Operation: batchOperation,
+		Value:     commands,
+	}
+}
+
+// applyBatch applies the commands one by one. Each of them succeeds or fails
+// on its own, as if it was a log entry of its own.
+func (b *FSM) applyBatch(l *raft.Log, payload *CommandPayload) interface{} {
+	raw, err := json.Marshal(payload.Value)
+	if err != nil {
+		return &ApplyResponse{Error: err}
+	}
+	var commands []CommandPayload
+	if err := json.Unmarshal(raw, &commands); err != nil {
+		return &ApplyResponse{Error: err}
+	}
+
+	responses := make([]*ApplyResponse, len(commands))
+	for i := range commands {
+		c := newChanges()
+		var response *ApplyResponse
+		if strings.EqualFold(strings.TrimSpace(commands[i].Operation), batchOperation) {
+			response = &ApplyResponse{Error: fmt.Errorf("batch can't contain a batch")}
+		} else {
+			response = b.applyCommand(c, &commands[i])
+		}
+		if response == nil {
+			response = &ApplyResponse{Error: fmt.Errorf("unknown command operation %s", commands[i].Operation)}
+		}
+
+		b.recordChange(c, l, i, &commands[i], response)
+		responses[i] = response
+	}
+
+	return &BatchResponse{Responses: responses}
+}
@@ -0,0 +1,115 @@
+package repo
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/KushnerykPavel/raft-test-project/internal/storage"
+	"github.com/hashicorp/raft"
+	"testing"
+	"time"
+)
+
+func TestApplyBatch(t *testing.T) {
+	store := storage.NewMemory()
+	fsm := NewFSM(store, time.Hour)
+
+	var changes []*Change
+	fsm.OnApply(func(change *Change) {
+		changes = append(changes, change)
+	})
+
+	orderKey := OrderKey("m1", "o1")
+	commands := []CommandPayload{
+		{Operation: "SET", Key: "plain", Value: map[string]int{"x": 1}},
+		{Operation: "SET_TRANSACTIONS", Key: orderKey, Value: Transaction{ID: "r1", OrderID: "o1", Type: RefundTransactionType, Amount: 100, Currency: "USD"}},
+		{Operation: "NOPE", Key: "unknown"},
+		{Operation: "batch", Value: []CommandPayload{{Operation: "SET", Key: "nested", Value: 1}}},
+		{Operation: "SET_TRANSACTIONS", Key: orderKey, Value: Transaction{ID: "t1", OrderID: "o1", Type: FirstTransactionType, Status: SucceededTransactionStatus, Amount: 100, Currency: "USD"}},
+		{Operation: "SET_TRANSACTIONS", Key: orderKey, Value: Transaction{ID: "r2", OrderID: "o1", Type: RefundTransactionType, Amount: 100, Currency: "USD"}},
+	}
+	data, err := json.Marshal(NewBatchPayload(commands))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	appendedAt := time.Date(2026, time.March, 15, 12, 0, 0, 0, time.UTC)
+	result := fsm.Apply(&raft.Log{Type: raft.LogCommand, Index: 7, Term: 2, Data: data, AppendedAt: appendedAt})
+
+	batch, ok := result.(*BatchResponse)
+	if !ok {
+		t.Fatalf("Apply() = %T, want *BatchResponse", result)
+	}
+	if len(batch.Responses) != len(commands) {
+		t.Fatalf("got %d responses for %d commands", len(batch.Responses), len(commands))
+	}
+
+	tests := []struct {
+		name       string
+		wantErr    bool
+		wantReject bool
+		wantOrders []string
+	}{
+		{name: "set"},
+		{name: "refund of pending order", wantErr: true, wantReject: true},
+		{name: "unknown operation", wantErr: true},
+		{name: "nested batch", wantErr: true},
+		{name: "charge", wantOrders: []string{orderKey}},
+		{name: "refund sees the charge of the batch", wantOrders: []string{orderKey}},
+	}
+
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			response := batch.Responses[i]
+			if (response.Error != nil) != tt.wantErr {
+				t.Fatalf("response error = %v, wantErr %v", response.Error, tt.wantErr)
+			}
+			if tt.wantReject && !errors.Is(response.Error, ErrTransitionNotAllowed) {
+				t.Errorf("response error = %v, want %v", response.Error, ErrTransitionNotAllowed)
+			}
+
+			if i >= len(changes) {
+				t.Fatalf("hook got %d changes", len(changes))
+			}
+			change := changes[i]
+			if change.Index != 7 || change.Offset != i || change.Term != 2 {
+				t.Errorf("change position = %d.%d term %d, want 7.%d term 2", change.Index, change.Offset, change.Term, i)
+			}
+			if (change.Error != "") != tt.wantErr {
+				t.Errorf("change error = %q, wantErr %v", change.Error, tt.wantErr)
+			}
+			if len(change.Orders) != len(tt.wantOrders) || (len(tt.wantOrders) > 0 && change.Orders[0] != tt.wantOrders[0]) {
+				t.Errorf("change orders = %v, want %v", change.Orders, tt.wantOrders)
+			}
+
+			if _, err := store.Get(ChangelogKey(7, i)); err != nil {
+				t.Errorf("changelog entry %d: %v", i, err)
+			}
+		})
+	}
+
+	if _, err := store.Get("plain"); err != nil {
+		t.Errorf("write of the succeeded command: %v", err)
+	}
+	if _, err := store.Get("r1"); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("write of the refused command: %v, want %v", err, storage.ErrNotFound)
+	}
+	if _, err := store.Get("nested"); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("write of the nested batch: %v, want %v", err, storage.ErrNotFound)
+	}
+
+	order, err := fsm.getOrder(orderKey, "o1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if order.State != RefundedOrderState || order.Index != 7 {
+		t.Errorf("order state = %s index %d, want %s index 7", order.State, order.Index, RefundedOrderState)
+	}
+
+	trx, ok := batch.Responses[4].Data.(*Transaction)
+	if !ok {
+		t.Fatalf("charge response data = %T, want *Transaction", batch.Responses[4].Data)
+	}
+	if trx.CreatedAt != appendedAt.Unix() {
+		t.Errorf("charge created at %d, want the log entry time %d", trx.CreatedAt, appendedAt.Unix())
+	}
+}
@@ -0,0 +1,115 @@
+package repo
+
+import (
+	"fmt"
+	"github.com/hashicorp/raft"
+	"log"
+	"strings"
+)
+
+// ChangelogPrefix prefix of applied commands ordered by the raft index
+const ChangelogPrefix = "changelog/"
+
+// ChangelogKey key of the command applied at the raft index.
+// Commands of a batch share the index and are told apart by the offset.
+func ChangelogKey(index uint64, offset int) string {
+	return fmt.Sprintf("%s%020d/%04d", ChangelogPrefix, index, offset)
+}
+
+// Change command applied by the FSM at the raft index
+type Change struct {
+	Index     uint64      `json:"index"`
+	Offset    int         `json:"offset"` // position of the command in the batch of the log entry
+	Term      uint64      `json:"term"`
+	Operation string      `json:"operation"`
+	Key       string      `json:"key"`
+	Value     interface{} `json:"value,omitempty"`
+	Error     string      `json:"error,omitempty"`
+	// Orders keys of the orders written by the command, they may differ from
+	// the key of the command, e.g. the order charged by a subscription
+	Orders []string `json:"orders,omitempty"`
+}
+
+// After reports whether the change was applied after the position
+func (c *Change) After(index uint64, offset int) bool {
+	return c.Index > index || (c.Index == index && c.Offset > offset)
+}
+
+// Changed reports whether the command wrote the key
+func (c *Change) Changed(key string) bool {
+	if c.Key == key {
+		return true
+	}
+	for _, order := range c.Orders {
+		if order == key {
+			return true
+		}
+	}
+	return false
+}
+
+// MerchantID returns merchant the changed key belongs to, empty for cluster wide keys
+func (c *Change) MerchantID() string {
+	if !strings.HasPrefix(c.Key, merchantPrefix) {
+		return ""
+	}
+	return MerchantOfKey(c.Key)
+}
+
+// ApplyHook is called after every applied command, in the order of the raft log.
+// It runs on the FSM goroutine, so it must not block.
+type ApplyHook func(change *Change)
+
+// OnApply registers the hook
+func (b *FSM) OnApply(hook ApplyHook) {
+	b.hooksMu.Lock()
+	defer b.hooksMu.Unlock()
+	b.hooks = append(b.hooks, hook)
+}
+
+// recordChange commits the writes of the applied command together with its changelog
+// entry and passes the change to the hooks. Writes of a failed command are dropped,
+// only its entry is written. Card data of recurring tokens and webhook signing
+// secrets never leave the FSM, the value of such commands is dropped.
+func (b *FSM) recordChange(c *changes, l *raft.Log, offset int, payload *CommandPayload, response *ApplyResponse) {
+	op := strings.ToUpper(strings.TrimSpace(payload.Operation))
+	if op == "GET" {
+		return
+	}
+
+	if response.Error != nil {
+		c = newChanges()
+	}
+
+	change := &Change{
+		Index:     l.Index,
+		Offset:    offset,
+		Term:      l.Term,
+		Operation: op,
+		Key:       payload.Key,
+		Value:     payload.Value,
+		Orders:    c.orderKeys(),
+	}
+	if response.Error != nil {
+		change.Error = response.Error.Error()
+	}
+	if strings.Contains(payload.Key, "/token/") || isWebhookKey(payload.Key) {
+		change.Value = nil
+	}
+
+	if b.changeRetention > 0 {
+		c.setWithTTL(ChangelogKey(change.Index, change.Offset), change, b.changeRetention)
+	}
+	if err := b.commit(c); err != nil {
+		log.Printf("error committing change %d: %s", change.Index, err.Error())
+		response.Error = err
+		change.Error = err.Error()
+		change.Orders = nil
+	}
+
+	b.hooksMu.RLock()
+	defer b.hooksMu.RUnlock()
+	for _, hook := range b.hooks {
+		hook(change)
+	}
+}
@@ -0,0 +1,66 @@
+package repo
+
+import (
+	"encoding/json"
+	"github.com/KushnerykPavel/raft-test-project/internal/storage"
+	"github.com/hashicorp/raft"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRecordChangeRedacts(t *testing.T) {
+	tests := []struct {
+		name      string
+		operation string
+		key       string
+		value     any
+		secret    string
+	}{
+		{name: "webhook endpoint", operation: "SET_WEBHOOK", key: WebhookKey("m1"), value: WebhookEndpoint{URL: "http://hook", Secret: "whsec"}, secret: "whsec"},
+		{name: "webhook endpoint set before its operation", operation: "SET", key: WebhookKey("m1"), value: WebhookEndpoint{URL: "http://hook", Secret: "whsec"}, secret: "whsec"},
+		{name: "recurring token", operation: "SET", key: TokenKey("m1", "tok"), value: map[string]string{"card_number": "4111111111111111"}, secret: "4111111111111111"},
+		{name: "plain value", operation: "SET", key: MerchantKey("m1") + "/note", value: "visible"},
+	}
+
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := storage.NewMemory()
+			fsm := NewFSM(store, time.Hour)
+
+			var change *Change
+			fsm.OnApply(func(c *Change) { change = c })
+
+			data, err := json.Marshal(CommandPayload{Operation: tt.operation, Key: tt.key, Value: tt.value})
+			if err != nil {
+				t.Fatal(err)
+			}
+			index := uint64(i + 1)
+			response, ok := fsm.Apply(&raft.Log{Type: raft.LogCommand, Index: index, Data: data}).(*ApplyResponse)
+			if !ok || response.Error != nil {
+				t.Fatalf("Apply() = %+v", response)
+			}
+
+			if _, err := store.Get(tt.key); err != nil {
+				t.Errorf("value of the command is not stored: %v", err)
+			}
+			entry, err := store.Get(ChangelogKey(index, 0))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if tt.secret == "" {
+				if change.Value == nil {
+					t.Errorf("value of the change is dropped")
+				}
+				return
+			}
+			if change.Value != nil {
+				t.Errorf("change value = %v, want it dropped", change.Value)
+			}
+			if strings.Contains(string(entry), tt.secret) {
+				t.Errorf("changelog entry %s holds the secret", entry)
+			}
+		})
+	}
+}
@@ -0,0 +1,97 @@
+package repo
+
+import (
+	"encoding/json"
+	"github.com/KushnerykPavel/raft-test-project/internal/storage"
+	"sort"
+	"time"
+)
+
+// changes keys written and removed by one command.
+// They are committed by the FSM in a single store update.
+type changes struct {
+	values  map[string]interface{}
+	ttls    map[string]time.Duration
+	deletes map[string]struct{}
+	// orders keys of the orders written by the command
+	orders map[string]struct{}
+}
+
+func newChanges() *changes {
+	return &changes{
+		values:  map[string]interface{}{},
+		ttls:    map[string]time.Duration{},
+		deletes: map[string]struct{}{},
+		orders:  map[string]struct{}{},
+	}
+}
+
+func (c *changes) set(key string, value interface{}) {
+	delete(c.deletes, key)
+	delete(c.ttls, key)
+	c.values[key] = value
+}
+
+// setOrder writes the order and records its key as changed by the command
+func (c *changes) setOrder(key string, order *Order) {
+	c.set(key, order)
+	c.orders[key] = struct{}{}
+}
+
+// orderKeys keys of the orders written by the command in key order
+func (c *changes) orderKeys() []string {
+	keys := make([]string, 0, len(c.orders))
+	for key := range c.orders {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// setWithTTL writes the key which is gone once the ttl is over
+func (c *changes) setWithTTL(key string, value interface{}, ttl time.Duration) {
+	c.set(key, value)
+	c.ttls[key] = ttl
+}
+
+func (c *changes) delete(key string) {
+	delete(c.values, key)
+	delete(c.ttls, key)
+	c.deletes[key] = struct{}{}
+}
+
+func (c *changes) empty() bool {
+	return len(c.values) == 0 && len(c.deletes) == 0
+}
+
+// commit writes the changes in one store update
+func (b *FSM) commit(c *changes) error {
+	if c.empty() {
+		return nil
+	}
+
+	return b.store.Update(func(w storage.Writer) error {
+		for key := range c.deletes {
+			if err := w.Delete(key); err != nil {
+				return err
+			}
+		}
+
+		for key, value := range c.values {
+			data, err := json.Marshal(value)
+			if err != nil {
+				return err
+			}
+
+			if ttl, ok := c.ttls[key]; ok {
+				err = w.PutWithTTL(key, data, ttl)
+			} else {
+				err = w.Put(key, data)
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
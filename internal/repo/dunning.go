@@ -0,0 +1,110 @@
+package repo
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// DunningPolicy retries of failed subscription charges.
+// It is sent by the leader in every charge command, so all replicas apply
+// the same policy even when nodes are configured differently.
+type DunningPolicy struct {
+	// RetryDelays delay in seconds before the retry after the first, second, ... failure.
+	// The last delay is repeated when there are more failures than delays.
+	RetryDelays []int64 `json:"retry_delays"`
+	// MaxFailures the subscription is suspended after so many failures in a row
+	MaxFailures int `json:"max_failures"`
+}
+
+// ParseRetrySchedule parses comma separated durations like "24h,72h,168h"
+func ParseRetrySchedule(schedule string) ([]time.Duration, error) {
+	var delays []time.Duration
+	for _, value := range strings.Split(schedule, ",") {
+		value = strings.TrimSpace(value)
+		if value == "" {
+			continue
+		}
+
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return nil, fmt.Errorf("retry delay %q: %w", value, err)
+		}
+		if d <= 0 {
+			return nil, fmt.Errorf("retry delay %q must be positive", value)
+		}
+		delays = append(delays, d)
+	}
+
+	if len(delays) == 0 {
+		return nil, errors.New("retry schedule must have at least one delay")
+	}
+	return delays, nil
+}
+
+// NewDunningPolicy builds policy from the retry schedule
+func NewDunningPolicy(delays []time.Duration, maxFailures int) DunningPolicy {
+	policy := DunningPolicy{MaxFailures: maxFailures}
+	for _, d := range delays {
+		policy.RetryDelays = append(policy.RetryDelays, int64(d/time.Second))
+	}
+	return policy
+}
+
+// retryDelay delay before the retry after the given number of failures
+func (p DunningPolicy) retryDelay(failures int) int64 {
+	if len(p.RetryDelays) == 0 {
+		return int64(24 * time.Hour / time.Second)
+	}
+	if failures > len(p.RetryDelays) {
+		failures = len(p.RetryDelays)
+	}
+	return p.RetryDelays[failures-1]
+}
+
+// DunningPolicy policy the subscription is retried with, the fallback
+// when the subscription has no policy of its own
+func (s *Subscription) DunningPolicy(fallback DunningPolicy) DunningPolicy {
+	if s.Dunning != nil {
+		return *s.Dunning
+	}
+	return fallback
+}
+
+// fail records failed charge of the run at runAt. The subscription is
+// retried according to the policy or suspended after too many failures.
+func (s *Subscription) fail(runAt int64, reason string, policy DunningPolicy) {
+	if s.CycleAt == 0 {
+		s.CycleAt = runAt
+	}
+
+	s.Failures++
+	s.LastFailure = reason
+	s.LastFailureAt = runAt
+
+	if policy.MaxFailures > 0 && s.Failures >= policy.MaxFailures {
+		s.Status = SuspendedSubscriptionStatus
+		return
+	}
+
+	s.Status = PastDueSubscriptionStatus
+	s.NextRunAt = runAt + policy.retryDelay(s.Failures)
+}
+
+// DunningResponse dunning state of the subscription
+type DunningResponse struct {
+	SubscriptionID string             `json:"subscription_id"`
+	Status         SubscriptionStatus `json:"status"`
+	Failures       int                `json:"failures"`
+	MaxFailures    int                `json:"max_failures"`
+	NextRetryAt    int64              `json:"next_retry_at,omitempty"`
+	LastFailure    string             `json:"last_failure,omitempty"`
+	LastFailureAt  int64              `json:"last_failure_at,omitempty"`
+	Addr           string             `json:"addr"`
+}
+
+func (rd *DunningResponse) Render(w http.ResponseWriter, r *http.Request) error {
+	return nil
+}
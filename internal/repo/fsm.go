@@ -0,0 +1,420 @@
+package repo
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/KushnerykPavel/raft-test-project/internal/storage"
+	"github.com/hashicorp/raft"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CommandPayload is payload sent by system when calling raft.Apply(cmd []byte, timeout time.Duration)
+type CommandPayload struct {
+	Operation string
+	Key       string
+	Value     interface{}
+}
+
+// ApplyResponse response from Apply raft
+type ApplyResponse struct {
+	Error error
+	Data  interface{}
+}
+
+// FSM applies commands of the raft log to the storage
+type FSM struct {
+	store storage.Store
+
+	// changeRetention how long applied commands are kept in the changelog
+	changeRetention time.Duration
+	// index raft index of the command being applied
+	index uint64
+	// appendedAt time the leader appended the command being applied to its log
+	appendedAt time.Time
+
+	hooksMu sync.RWMutex
+	hooks   []ApplyHook
+}
+
+func (b *FSM) get(key string) (interface{}, error) {
+	var data interface{}
+
+	value, err := b.store.Get(key)
+	if err != nil {
+		data = map[string]interface{}{}
+		return data, err
+	}
+
+	if value != nil && len(value) > 0 {
+		err = json.Unmarshal(value, &data)
+	}
+
+	if err != nil {
+		data = map[string]interface{}{}
+	}
+
+	return data, err
+}
+
+func (b *FSM) set(c *changes, key string, value interface{}) {
+	log.Print("set: key:  ", key, " value: ", value)
+
+	c.set(key, value)
+}
+
+func (b *FSM) setMerchant(c *changes, value interface{}) error {
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	var record MerchantRecord
+	if err := json.Unmarshal(raw, &record); err != nil {
+		return err
+	}
+
+	c.set(MerchantKey(record.Merchant.ID), record.Merchant)
+	c.set(apiKeyPrefix+record.APIKeyHash, record.Merchant.ID)
+	return nil
+}
+
+// setIdempotency stores response of idempotent request until its expiration.
+// The store drops the key on its own once the ttl is over, a replayed entry
+// is stored again for the whole ttl and the handler skips it once expired.
+// It is a compare-and-set: a pending record reserves a key with no unexpired
+// record, the response completes only the pending record of the same request.
+func (b *FSM) setIdempotency(c *changes, key string, value interface{}) error {
+	log.Print("set_idempotency: key: ", key)
+
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	var record IdempotencyRecord
+	if err := json.Unmarshal(raw, &record); err != nil {
+		return err
+	}
+
+	// every replica measures the ttl from the log entry, never from its own clock
+	appendedAt := b.appendedAt
+	if appendedAt.IsZero() {
+		appendedAt = time.Now()
+	}
+	ttl := time.Unix(record.ExpiresAt, 0).Sub(appendedAt)
+	if ttl <= 0 {
+		return nil
+	}
+
+	var stored IdempotencyRecord
+	current, err := b.store.Get(key)
+	switch {
+	case errors.Is(err, storage.ErrNotFound):
+	case err != nil:
+		return err
+	default:
+		if err := json.Unmarshal(current, &stored); err != nil {
+			return err
+		}
+		if stored.Expired(appendedAt) {
+			stored = IdempotencyRecord{}
+		}
+	}
+
+	if record.Pending() && stored.RequestHash != "" {
+		return fmt.Errorf("%w: %s is reserved", ErrIdempotencyKeyInUse, record.Key)
+	}
+	if !record.Pending() && (!stored.Pending() || stored.RequestHash != record.RequestHash) {
+		return fmt.Errorf("%w: %s is not reserved by the request", ErrIdempotencyKeyInUse, record.Key)
+	}
+
+	c.setWithTTL(key, json.RawMessage(raw), ttl)
+	return nil
+}
+
+func (b *FSM) toTransaction(value any) (*Transaction, error) {
+	trx, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	var transaction Transaction
+	err = json.Unmarshal(trx, &transaction)
+	return &transaction, err
+}
+
+// getOrder reads the order stored under key.
+// Not existing order is returned as a new pending one.
+func (b *FSM) getOrder(key string, orderID string) (*Order, error) {
+	order := NewOrder(orderID)
+
+	value, err := b.store.Get(key)
+	if errors.Is(err, storage.ErrNotFound) {
+		return order, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(value, order); err != nil {
+		return nil, err
+	}
+
+	if order.ID == "" {
+		order.ID = orderID
+	}
+
+	return order, nil
+}
+
+// setTransactions adds the transaction to the order stored under key.
+// The order state transition is validated here, so every replica
+// makes the same decision for the same log entry.
+func (b *FSM) setTransactions(c *changes, key string, value interface{}) (*Transaction, error) {
+	log.Print("set_transactions: key:  ", key, " value: ", value)
+	trx, err := b.toTransaction(value)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := b.addTransaction(c, key, trx); err != nil {
+		return nil, err
+	}
+
+	return trx, nil
+}
+
+// addTransaction collects changes of adding the transaction to the order.
+// It returns false when the transaction was already applied before.
+func (b *FSM) addTransaction(c *changes, key string, trx *Transaction) (bool, error) {
+	_, err := b.get(trx.ID)
+	if err == nil {
+		return false, nil
+	}
+	if err != nil && !errors.Is(err, storage.ErrNotFound) {
+		return false, err
+	}
+
+	order, err := b.getOrder(key, trx.OrderID)
+	if err != nil {
+		return false, err
+	}
+
+	// every replica takes the time from the log entry, never from its own clock.
+	// The outcome of an intent is checked at the time of the intent, the processor
+	// call in between doesn't expire the hold it captures.
+	now := b.appendedAt
+	intentKey := IntentKey(key, trx.ID)
+	intent, err := b.getIntent(intentKey)
+	switch {
+	case err == nil:
+		now = time.Unix(intent.CreatedAt, 0)
+		c.delete(intentKey)
+	case !errors.Is(err, storage.ErrNotFound):
+		return false, err
+	}
+
+	if trx.CreatedAt == 0 && !now.IsZero() {
+		trx.CreatedAt = now.Unix()
+	}
+	if trx.Token == "" {
+		trx.Token = order.Token
+	}
+
+	if err := order.AddTransaction(trx, now); err != nil {
+		return false, &rejectedError{err: err}
+	}
+	order.Index = b.index
+	splitOrder(c, key, order)
+
+	c.set(trx.ID, trx)
+	c.setOrder(key, order)
+	b.indexTransaction(c, key, trx)
+
+	if err := b.addEvent(c, key, order, trx); err != nil {
+		return false, err
+	}
+
+	// keep the index of open authorizations in sync with the order
+	indexKey := AuthorizationIndexKey(key)
+	if order.Authorization == nil {
+		c.delete(indexKey)
+		return true, nil
+	}
+
+	c.set(indexKey, &AuthorizationIndex{
+		OrderKey:      key,
+		OrderID:       order.ID,
+		TransactionID: order.Authorization.TransactionID,
+		ExpiresAt:     order.Authorization.ExpiresAt,
+	})
+	return true, nil
+}
+
+func (b *FSM) delete(c *changes, key string) {
+	c.delete(key)
+}
+
+// Apply log is invoked once a log entry is committed.
+// It returns a value which will be made available in the
+// ApplyFuture returned by Raft.Apply method if that
+// method was called on the same Raft node as the FSM.
+func (b *FSM) Apply(log *raft.Log) interface{} {
+	switch log.Type {
+	case raft.LogCommand:
+		var payload = CommandPayload{}
+		if err := json.Unmarshal(log.Data, &payload); err != nil {
+			_, _ = fmt.Fprintf(os.Stderr, "error marshalling store payload %s\n", err.Error())
+			return nil
+		}
+
+		b.index = log.Index
+		b.appendedAt = log.AppendedAt
+		if strings.EqualFold(strings.TrimSpace(payload.Operation), batchOperation) {
+			return b.applyBatch(log, &payload)
+		}
+
+		c := newChanges()
+		response := b.applyCommand(c, &payload)
+		if response == nil {
+			return nil
+		}
+		b.recordChange(c, log, 0, &payload, response)
+		return response
+	}
+
+	_, _ = fmt.Fprintf(os.Stderr, "not raft log command type\n")
+	return nil
+}
+
+// applyCommand executes the command, its writes are collected into c.
+// It returns nil for unknown operation.
+func (b *FSM) applyCommand(c *changes, payload *CommandPayload) *ApplyResponse {
+	op := strings.ToUpper(strings.TrimSpace(payload.Operation))
+	switch op {
+	case "SET_TRANSACTIONS":
+		trx, err := b.setTransactions(c, payload.Key, payload.Value)
+		return &ApplyResponse{
+			Error: err,
+			Data:  trx,
+		}
+	case "SET_SUBSCRIPTION":
+		subscription, err := b.setSubscription(c, payload.Key, payload.Value)
+		return &ApplyResponse{
+			Error: err,
+			Data:  subscription,
+		}
+	case "CANCEL_SUBSCRIPTION":
+		subscription, err := b.cancelSubscription(c, payload.Key)
+		return &ApplyResponse{
+			Error: err,
+			Data:  subscription,
+		}
+	case "CHARGE_SUBSCRIPTION":
+		subscription, err := b.chargeSubscription(c, payload.Value)
+		return &ApplyResponse{
+			Error: err,
+			Data:  subscription,
+		}
+	case "WEBHOOK_ATTEMPT":
+		delivery, err := b.recordWebhookAttempt(c, payload.Value)
+		return &ApplyResponse{
+			Error: err,
+			Data:  delivery,
+		}
+	case "SET_INTENT":
+		trx, err := b.setIntent(c, payload.Key, payload.Value)
+		return &ApplyResponse{
+			Error: err,
+			Data:  trx,
+		}
+	case "SET_INTENT_OUTCOME":
+		return &ApplyResponse{
+			Error: b.setIntentOutcome(c, payload.Key, payload.Value),
+			Data:  nil,
+		}
+	case "MIGRATE_FLAT_KEYS":
+		migration, err := b.migrateFlatKeys(c, payload.Value)
+		return &ApplyResponse{
+			Error: err,
+			Data:  migration,
+		}
+	case "SET_WEBHOOK":
+		return &ApplyResponse{
+			Error: b.setWebhook(c, payload.Key, payload.Value),
+			Data:  nil,
+		}
+	case "SET_MERCHANT":
+		return &ApplyResponse{
+			Error: b.setMerchant(c, payload.Value),
+			Data:  payload.Value,
+		}
+	case "SET_IDEMPOTENCY":
+		return &ApplyResponse{
+			Error: b.setIdempotency(c, payload.Key, payload.Value),
+			Data:  nil,
+		}
+	case "SET":
+		b.set(c, payload.Key, payload.Value)
+		return &ApplyResponse{
+			Data: payload.Value,
+		}
+	case "GET":
+		data, err := b.get(payload.Key)
+		return &ApplyResponse{
+			Error: err,
+			Data:  data,
+		}
+
+	case "DELETE":
+		b.delete(c, payload.Key)
+		return &ApplyResponse{
+			Data: nil,
+		}
+	}
+
+	_, _ = fmt.Fprintf(os.Stderr, "unknown command operation %s\n", payload.Operation)
+	return nil
+}
+
+// Snapshot takes a consistent view of the store, it is streamed into the
+// snapshot by Persist, so the log can be compacted without losing state.
+func (b *FSM) Snapshot() (raft.FSMSnapshot, error) {
+	snapshot, err := b.store.Snapshot()
+	if err != nil {
+		return nil, err
+	}
+	return &fsmSnapshot{snapshot: snapshot}, nil
+}
+
+// Restore is used to restore an FSM from a Snapshot. It is not called
+// concurrently with any other command. The FSM must discard all previous
+// state, the store is replaced with the keys of the snapshot.
+func (b *FSM) Restore(rClose io.ReadCloser) error {
+	defer func() {
+		if err := rClose.Close(); err != nil {
+			_, _ = fmt.Fprintf(os.Stdout, "[FINALLY RESTORE] close error %s\n", err.Error())
+		}
+	}()
+
+	_, _ = fmt.Fprintf(os.Stdout, "[START RESTORE] read all keys from snapshot\n")
+
+	totalRestored, err := b.restore(rClose)
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stdout, "[END RESTORE] error %s\n", err.Error())
+		return err
+	}
+
+	_, _ = fmt.Fprintf(os.Stdout, "[END RESTORE] success restore %d keys in snapshot\n", totalRestored)
+	return nil
+}
+
+func NewFSM(store storage.Store, changeRetention time.Duration) *FSM {
+	return &FSM{
+		store:           store,
+		changeRetention: changeRetention,
+	}
+}
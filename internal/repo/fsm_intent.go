@@ -0,0 +1,115 @@
+package repo
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/KushnerykPavel/raft-test-project/internal/storage"
+	"log"
+	"time"
+)
+
+func (b *FSM) toIntent(value any) (*Intent, error) {
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	var intent Intent
+	err = json.Unmarshal(raw, &intent)
+	return &intent, err
+}
+
+// getIntent reads the intent stored under key
+func (b *FSM) getIntent(key string) (*Intent, error) {
+	value, err := b.store.Get(key)
+	if err != nil {
+		return nil, err
+	}
+	var intent Intent
+	err = json.Unmarshal(value, &intent)
+	return &intent, err
+}
+
+// setIntent records the transaction of the order before it is sent to the processor.
+// The order has to accept it and have no other transaction at the processor. Amount,
+// currency and authorization taken from the order are filled into the transaction.
+// A transaction applied before is returned as it was applied, it is not sent again.
+// An intent of the same transaction is replaced, the processor deduplicates it by the id.
+func (b *FSM) setIntent(c *changes, key string, value any) (*Transaction, error) {
+	intent, err := b.toIntent(value)
+	if err != nil {
+		return nil, err
+	}
+	trx := &intent.Transaction
+
+	applied, err := b.get(trx.ID)
+	if err == nil {
+		return b.toTransaction(applied)
+	}
+	if !errors.Is(err, storage.ErrNotFound) {
+		return nil, err
+	}
+
+	// every replica takes the time from the log entry, never from its own clock
+	now := b.appendedAt
+	if now.IsZero() {
+		now = time.Now()
+	}
+
+	err = b.store.Scan(IntentOrderPrefix(key), "", func(_ string, val []byte) error {
+		var other Intent
+		if err := json.Unmarshal(val, &other); err != nil {
+			return err
+		}
+		if other.Transaction.ID != trx.ID && other.Locked(now) {
+			return &rejectedError{err: fmt.Errorf("%w: transaction %s of order %s is at the processor",
+				ErrTransactionInProgress, other.Transaction.ID, trx.OrderID)}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	order, err := b.getOrder(key, trx.OrderID)
+	if err != nil {
+		return nil, err
+	}
+	preview := *trx
+	preview.Status = SucceededTransactionStatus
+	if err := order.AddTransaction(&preview, now); err != nil {
+		return nil, &rejectedError{err: err}
+	}
+
+	trx.Amount = preview.Amount
+	trx.Currency = preview.Currency
+	trx.AuthorizationID = preview.AuthorizationID
+	trx.Status = PendingTransactionStatus
+	trx.CreatedAt = now.Unix()
+
+	intent.OrderKey = key
+	intent.CreatedAt = now.Unix()
+	c.set(IntentKey(key, trx.ID), intent)
+
+	return trx, nil
+}
+
+// setIntentOutcome keeps the processor outcome the leader failed to apply on the intent,
+// the transaction is left for reconciliation
+func (b *FSM) setIntentOutcome(c *changes, key string, value any) error {
+	trx, err := b.toTransaction(value)
+	if err != nil {
+		return err
+	}
+
+	intentKey := IntentKey(key, trx.ID)
+	intent, err := b.getIntent(intentKey)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("set_intent_outcome: transaction %s of order %s is left for reconciliation", trx.ID, trx.OrderID)
+	intent.Outcome = trx.Processor
+	c.set(intentKey, intent)
+	return nil
+}
@@ -0,0 +1,131 @@
+package repo
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+)
+
+func (b *FSM) toSubscription(value any) (*Subscription, error) {
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	var subscription Subscription
+	err = json.Unmarshal(raw, &subscription)
+	return &subscription, err
+}
+
+// getSubscription reads the subscription stored under key
+func (b *FSM) getSubscription(key string) (*Subscription, error) {
+	value, err := b.get(key)
+	if err != nil {
+		return nil, err
+	}
+	return b.toSubscription(value)
+}
+
+// setSubscription creates the subscription and puts it into the schedule
+func (b *FSM) setSubscription(c *changes, key string, value interface{}) (*Subscription, error) {
+	subscription, err := b.toSubscription(value)
+	if err != nil {
+		return nil, err
+	}
+
+	c.set(key, subscription)
+	if subscription.Scheduled() {
+		c.set(ScheduleKey(subscription.NextRunAt, key), key)
+	}
+
+	return subscription, nil
+}
+
+// cancelSubscription stops the subscription and removes it from the schedule
+func (b *FSM) cancelSubscription(c *changes, key string) (*Subscription, error) {
+	log.Print("cancel_subscription: key: ", key)
+
+	subscription, err := b.getSubscription(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if !subscription.Scheduled() {
+		return nil, fmt.Errorf("%w: subscription %s is %s", ErrSubscriptionNotActive, subscription.ID, subscription.Status)
+	}
+
+	c.delete(ScheduleKey(subscription.NextRunAt, key))
+	subscription.Status = CanceledSubscriptionStatus
+	c.set(key, subscription)
+
+	return subscription, nil
+}
+
+// chargeSubscription charges the subscription run and moves it to the next run.
+// The charge and the schedule update are written together. A command for a run
+// the subscription has already passed is skipped, so a new leader repeating the
+// run of the previous one doesn't charge twice. A charge refused by the order
+// is recorded as a failure and retried by the dunning policy of the command.
+func (b *FSM) chargeSubscription(c *changes, value interface{}) (*Subscription, error) {
+	log.Print("charge_subscription: value: ", value)
+
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	var charge SubscriptionCharge
+	if err := json.Unmarshal(raw, &charge); err != nil {
+		return nil, err
+	}
+
+	subscription, err := b.getSubscription(charge.SubscriptionKey)
+	if err != nil {
+		return nil, err
+	}
+
+	if !subscription.Scheduled() || subscription.NextRunAt != charge.RunAt {
+		log.Printf("charge_subscription: run %d of subscription %s is already done", charge.RunAt, subscription.ID)
+		return subscription, nil
+	}
+
+	trx := &Transaction{
+		ID:       ChargeTransactionID(subscription.ID, charge.RunAt),
+		OrderID:  subscription.OrderID,
+		Type:     RecurringTransactionType,
+		Status:   SucceededTransactionStatus,
+		Amount:   subscription.Amount,
+		Currency: subscription.Currency,
+		Token:    subscription.Token,
+	}
+	if charge.Processor != nil {
+		trx.Processor = charge.Processor
+		if !charge.Processor.Approved {
+			trx.Status = FailedTransactionStatus
+		}
+	}
+
+	c.delete(ScheduleKey(charge.RunAt, charge.SubscriptionKey))
+
+	_, err = b.addTransaction(c, OrderKey(subscription.MerchantID, subscription.OrderID), trx)
+	switch {
+	case isRejected(err):
+		log.Printf("charge_subscription: run %d of subscription %s failed: %s", charge.RunAt, subscription.ID, err.Error())
+		subscription.fail(charge.RunAt, err.Error(), subscription.DunningPolicy(charge.Dunning))
+	case err != nil:
+		return nil, err
+	case trx.Status == FailedTransactionStatus:
+		reason := fmt.Sprintf("processor declined with code %s: %s", trx.Processor.Code, trx.Processor.Message)
+		log.Printf("charge_subscription: run %d of subscription %s failed: %s", charge.RunAt, subscription.ID, reason)
+		subscription.fail(charge.RunAt, reason, subscription.DunningPolicy(charge.Dunning))
+	default:
+		if err := subscription.advance(charge.RunAt); err != nil {
+			return nil, err
+		}
+	}
+
+	if subscription.Scheduled() {
+		c.set(ScheduleKey(subscription.NextRunAt, charge.SubscriptionKey), charge.SubscriptionKey)
+	}
+	c.set(charge.SubscriptionKey, subscription)
+
+	return subscription, nil
+}
@@ -0,0 +1,157 @@
+package repo
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/KushnerykPavel/raft-test-project/internal/storage"
+	"log"
+)
+
+// nextSequence increments the counter under the key within the changes
+func (b *FSM) nextSequence(c *changes, key string) (uint64, error) {
+	var sequence uint64
+	if value, ok := c.values[key]; ok {
+		sequence = value.(uint64)
+	} else {
+		value, err := b.get(key)
+		switch {
+		case errors.Is(err, storage.ErrNotFound):
+		case err != nil:
+			return 0, err
+		default:
+			number, ok := value.(float64)
+			if !ok {
+				return 0, errors.New("sequence is not a number")
+			}
+			sequence = uint64(number)
+		}
+	}
+
+	sequence++
+	c.set(key, sequence)
+	return sequence, nil
+}
+
+// addEvent writes event of the applied transaction into the outbox.
+// Events are kept only for merchants with a webhook endpoint.
+func (b *FSM) addEvent(c *changes, orderKey string, order *Order, trx *Transaction) error {
+	eventType, ok := eventTypes[trx.Type]
+	if !ok {
+		return nil
+	}
+
+	merchantID := MerchantOfKey(orderKey)
+	if _, err := b.get(WebhookKey(merchantID)); err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return nil
+		}
+		return err
+	}
+
+	sequence, err := b.nextSequence(c, outboxSequenceKey)
+	if err != nil {
+		return err
+	}
+
+	c.set(OutboxKey(sequence), &OutboxEntry{
+		Event: Event{
+			ID:          trx.ID,
+			Type:        eventType,
+			Sequence:    sequence,
+			MerchantID:  merchantID,
+			OrderID:     order.ID,
+			OrderState:  order.State,
+			Transaction: *trx,
+		},
+	})
+	return nil
+}
+
+// setWebhook stores the endpoint of the merchant. Its signing secret is never logged.
+func (b *FSM) setWebhook(c *changes, key string, value any) error {
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	var endpoint WebhookEndpoint
+	if err := json.Unmarshal(raw, &endpoint); err != nil {
+		return err
+	}
+
+	c.set(key, &endpoint)
+	return nil
+}
+
+func (b *FSM) toOutboxEntry(value any) (*OutboxEntry, error) {
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	var entry OutboxEntry
+	err = json.Unmarshal(raw, &entry)
+	return &entry, err
+}
+
+func (b *FSM) toWebhookAttempt(value any) (*WebhookAttempt, error) {
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	var attempt WebhookAttempt
+	err = json.Unmarshal(raw, &attempt)
+	return &attempt, err
+}
+
+// recordWebhookAttempt writes the attempt into the delivery log and
+// reschedules or removes the event. An attempt repeated by a new leader is ignored.
+func (b *FSM) recordWebhookAttempt(c *changes, value any) (*WebhookDelivery, error) {
+	log.Print("webhook_attempt: value: ", value)
+
+	attempt, err := b.toWebhookAttempt(value)
+	if err != nil {
+		return nil, err
+	}
+
+	stored, err := b.get(attempt.OutboxKey)
+	if errors.Is(err, storage.ErrNotFound) {
+		log.Printf("webhook_attempt: event %s is already delivered", attempt.OutboxKey)
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	entry, err := b.toOutboxEntry(stored)
+	if err != nil {
+		return nil, err
+	}
+	if entry.Attempts+1 != attempt.Attempt {
+		log.Printf("webhook_attempt: attempt %d of event %s is already recorded", attempt.Attempt, entry.Event.ID)
+		return nil, nil
+	}
+
+	delivery := &WebhookDelivery{
+		EventID:       entry.Event.ID,
+		EventType:     entry.Event.Type,
+		Sequence:      entry.Event.Sequence,
+		Attempt:       attempt.Attempt,
+		URL:           attempt.URL,
+		Status:        attempt.Status,
+		StatusCode:    attempt.StatusCode,
+		Error:         attempt.Error,
+		AttemptedAt:   attempt.AttemptedAt,
+		NextAttemptAt: attempt.NextAttemptAt,
+	}
+
+	c.set(DeliveryKey(entry.Event.MerchantID, entry.Event.Sequence, attempt.Attempt), delivery)
+
+	if attempt.Status == RetryingDeliveryStatus {
+		entry.Attempts = attempt.Attempt
+		entry.NextAttemptAt = attempt.NextAttemptAt
+		c.set(attempt.OutboxKey, entry)
+	} else {
+		c.delete(attempt.OutboxKey)
+	}
+
+	return delivery, nil
+}
@@ -0,0 +1,33 @@
+package repo
+
+import (
+	"net/http"
+)
+
+// Reasons of the health and readiness checks
+const (
+	HealthOK                 = "ok"
+	HealthStorageUnreadable  = "storage_unreadable"
+	HealthNoLeader           = "no_leader"
+	HealthLeaderContactStale = "leader_contact_stale"
+	HealthApplyLagging       = "apply_lagging"
+)
+
+// HealthResponse outcome of a health or readiness check of the node
+type HealthResponse struct {
+	Ready  bool   `json:"ready"`
+	Reason string `json:"reason"`
+	Error  string `json:"error,omitempty"`
+	Addr   string `json:"addr"`
+
+	// set by the readiness check
+	State        string `json:"state,omitempty"`
+	LeaderID     string `json:"leader_id,omitempty"`
+	LastContact  string `json:"last_contact,omitempty"` // followers only
+	AppliedIndex uint64 `json:"applied_index,omitempty"`
+	CommitIndex  uint64 `json:"commit_index,omitempty"`
+}
+
+func (rd *HealthResponse) Render(w http.ResponseWriter, r *http.Request) error {
+	return nil
+}
@@ -0,0 +1,47 @@
+package repo
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"time"
+)
+
+// ErrIdempotencyKeyInUse is returned by Apply when the key is reserved by another
+// request, or the response is stored for a key the request doesn't hold.
+var ErrIdempotencyKeyInUse = errors.New("idempotency key is in use")
+
+// IdempotencyRecord is the response stored for Idempotency-Key header.
+// A retry with the same key and the same request gets this response back
+// instead of being executed again. The record is stored without response
+// before the request is executed, so the key is never executed twice.
+type IdempotencyRecord struct {
+	Key         string `json:"key"`
+	RequestHash string `json:"request_hash"`
+	StatusCode  int    `json:"status_code"`
+	ContentType string `json:"content_type"`
+	Body        []byte `json:"body"`
+	ExpiresAt   int64  `json:"expires_at"`
+}
+
+// Expired reports whether the record is out of its retention period
+func (i *IdempotencyRecord) Expired(now time.Time) bool {
+	return now.Unix() >= i.ExpiresAt
+}
+
+// Pending reports whether the request of the record has no stored response
+func (i *IdempotencyRecord) Pending() bool {
+	return i.StatusCode == 0
+}
+
+// HashRequest fingerprint of the request bound to an idempotency key
+func HashRequest(method, path string, body []byte) string {
+	h := sha256.New()
+	h.Write([]byte(method))
+	h.Write([]byte{0})
+	h.Write([]byte(path))
+	h.Write([]byte{0})
+	h.Write(body)
+
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,89 @@
+package repo
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/KushnerykPavel/raft-test-project/internal/storage"
+	"github.com/hashicorp/raft"
+	"testing"
+	"time"
+)
+
+func TestSetIdempotency(t *testing.T) {
+	store := storage.NewMemory()
+	fsm := NewFSM(store, time.Hour)
+
+	key := IdempotencyKey("m1", "k1")
+	start := time.Date(2026, time.March, 15, 12, 0, 0, 0, time.UTC)
+	expiresAt := start.Add(time.Minute).Unix()
+	reserve := func(hash string) IdempotencyRecord {
+		return IdempotencyRecord{Key: "k1", RequestHash: hash, ExpiresAt: expiresAt}
+	}
+	complete := func(hash string) IdempotencyRecord {
+		record := reserve(hash)
+		record.StatusCode = 201
+		record.Body = []byte(`{}`)
+		return record
+	}
+
+	tests := []struct {
+		name       string
+		operation  string
+		record     IdempotencyRecord
+		appendedAt time.Time
+		wantErr    bool
+		wantStatus int
+	}{
+		{name: "response without reservation", operation: "SET_IDEMPOTENCY", record: complete("a"), appendedAt: start, wantErr: true},
+		{name: "reserve", operation: "SET_IDEMPOTENCY", record: reserve("a"), appendedAt: start, wantStatus: 0},
+		{name: "concurrent reservation of the same request", operation: "SET_IDEMPOTENCY", record: reserve("a"), appendedAt: start.Add(time.Second), wantErr: true},
+		{name: "reservation of another request", operation: "SET_IDEMPOTENCY", record: reserve("b"), appendedAt: start.Add(time.Second), wantErr: true},
+		{name: "response of another request", operation: "SET_IDEMPOTENCY", record: complete("b"), appendedAt: start.Add(time.Second), wantErr: true},
+		{name: "response", operation: "SET_IDEMPOTENCY", record: complete("a"), appendedAt: start.Add(2 * time.Second), wantStatus: 201},
+		{name: "reservation over the response", operation: "SET_IDEMPOTENCY", record: reserve("a"), appendedAt: start.Add(3 * time.Second), wantErr: true},
+		{name: "response stored twice", operation: "SET_IDEMPOTENCY", record: complete("a"), appendedAt: start.Add(3 * time.Second), wantErr: true},
+		{name: "release", operation: "DELETE", appendedAt: start.Add(4 * time.Second)},
+		{name: "reserve after the release", operation: "SET_IDEMPOTENCY", record: reserve("b"), appendedAt: start.Add(5 * time.Second), wantStatus: 0},
+	}
+
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			payload := CommandPayload{Operation: tt.operation, Key: key}
+			if tt.operation != "DELETE" {
+				payload.Value = tt.record
+			}
+			data, err := json.Marshal(payload)
+			if err != nil {
+				t.Fatal(err)
+			}
+			response, ok := fsm.Apply(&raft.Log{Type: raft.LogCommand, Index: uint64(i + 1), Data: data, AppendedAt: tt.appendedAt}).(*ApplyResponse)
+			if !ok {
+				t.Fatalf("Apply() returned no response")
+			}
+			if tt.wantErr {
+				if !errors.Is(response.Error, ErrIdempotencyKeyInUse) {
+					t.Fatalf("Apply() error = %v, want %v", response.Error, ErrIdempotencyKeyInUse)
+				}
+				return
+			}
+			if response.Error != nil {
+				t.Fatalf("Apply() error = %v", response.Error)
+			}
+			if tt.operation == "DELETE" {
+				return
+			}
+
+			value, err := store.Get(key)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var stored IdempotencyRecord
+			if err := json.Unmarshal(value, &stored); err != nil {
+				t.Fatal(err)
+			}
+			if stored.RequestHash != tt.record.RequestHash || stored.StatusCode != tt.wantStatus {
+				t.Errorf("stored record = %s %d, want %s %d", stored.RequestHash, stored.StatusCode, tt.record.RequestHash, tt.wantStatus)
+			}
+		})
+	}
+}
@@ -0,0 +1,40 @@
+package repo
+
+import (
+	"errors"
+	"net/http"
+	"time"
+)
+
+// ErrTransactionInProgress is returned by Apply when another transaction
+// of the order was sent to the processor and its outcome is not applied yet.
+var ErrTransactionInProgress = errors.New("order has a transaction in progress")
+
+// Intent transaction of the order sent to the processor. The leader applies it
+// before the processor call and the outcome replaces it, so money moved by the
+// processor is never unknown to the cluster: an intent left after its lock is
+// to be reconciled with the processor by its transaction id.
+type Intent struct {
+	OrderKey    string      `json:"order_key"`
+	Transaction Transaction `json:"transaction"`
+	CreatedAt   int64       `json:"created_at"`
+	// LockedUntil unix time until which other transactions of the order wait for the outcome
+	LockedUntil int64 `json:"locked_until"`
+	// Outcome processor response the leader failed to apply
+	Outcome *ProcessorResponse `json:"outcome,omitempty"`
+}
+
+// Locked reports whether the intent still holds the order at now
+func (i *Intent) Locked(now time.Time) bool {
+	return now.Unix() <= i.LockedUntil
+}
+
+// IntentsResponse intents of the node storage waiting for their outcome or reconciliation
+type IntentsResponse struct {
+	Intents []Intent `json:"intents"`
+	Addr    string   `json:"addr"`
+}
+
+func (rd *IntentsResponse) Render(w http.ResponseWriter, r *http.Request) error {
+	return nil
+}
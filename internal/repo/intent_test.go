@@ -0,0 +1,95 @@
+package repo
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/KushnerykPavel/raft-test-project/internal/storage"
+	"github.com/hashicorp/raft"
+	"testing"
+	"time"
+)
+
+func TestIntent(t *testing.T) {
+	store := storage.NewMemory()
+	fsm := NewFSM(store, time.Hour)
+
+	orderKey := OrderKey("m1", "o1")
+	start := time.Date(2026, time.March, 15, 12, 0, 0, 0, time.UTC)
+	at := func(d time.Duration) time.Time { return start.Add(d) }
+	intent := func(trx Transaction, lock time.Duration, now time.Time) Intent {
+		return Intent{Transaction: trx, LockedUntil: now.Add(lock).Unix()}
+	}
+
+	auth := Transaction{ID: "a1", OrderID: "o1", Type: AuthorizationTransactionType, Amount: 100, Currency: "USD", ExpiresAt: at(time.Minute).Unix()}
+	authorized := auth
+	authorized.Status = SucceededTransactionStatus
+	capture := Transaction{ID: "c1", OrderID: "o1", Type: CaptureTransactionType}
+	captured := capture
+	captured.Status = SucceededTransactionStatus
+	refund := Transaction{ID: "r1", OrderID: "o1", Type: RefundTransactionType, Amount: 100, Currency: "USD"}
+	refunded := refund
+	refunded.Processor = &ProcessorResponse{Code: "00"}
+	secondRefund := Transaction{ID: "r2", OrderID: "o1", Type: RefundTransactionType, Amount: 50, Currency: "USD"}
+
+	tests := []struct {
+		name       string
+		operation  string
+		value      any
+		appendedAt time.Time
+		wantErr    error
+		wantStatus TransactionStatus
+		wantAmount int64
+		wantIntent bool
+	}{
+		{name: "authorization sent", operation: "SET_INTENT", value: intent(auth, 10*time.Second, at(0)), appendedAt: at(0), wantStatus: PendingTransactionStatus, wantAmount: 100, wantIntent: true},
+		{name: "capture waits for the authorization", operation: "SET_INTENT", value: intent(capture, 10*time.Second, at(time.Second)), appendedAt: at(time.Second), wantErr: ErrTransactionInProgress},
+		{name: "authorization outcome", operation: "SET_TRANSACTIONS", value: authorized, appendedAt: at(2 * time.Second), wantStatus: SucceededTransactionStatus},
+		{name: "applied authorization is not sent again", operation: "SET_INTENT", value: intent(auth, 10*time.Second, at(3*time.Second)), appendedAt: at(3 * time.Second), wantStatus: SucceededTransactionStatus},
+		{name: "capture sent before the expiry", operation: "SET_INTENT", value: intent(capture, 10*time.Second, at(59*time.Second)), appendedAt: at(59 * time.Second), wantStatus: PendingTransactionStatus, wantAmount: 100, wantIntent: true},
+		{name: "capture outcome after the expiry", operation: "SET_TRANSACTIONS", value: captured, appendedAt: at(61 * time.Second), wantStatus: SucceededTransactionStatus},
+		{name: "refund sent", operation: "SET_INTENT", value: intent(refund, 10*time.Second, at(2*time.Minute)), appendedAt: at(2 * time.Minute), wantStatus: PendingTransactionStatus, wantAmount: 100, wantIntent: true},
+		{name: "refund outcome left for reconciliation", operation: "SET_INTENT_OUTCOME", value: refunded, appendedAt: at(2*time.Minute + time.Second), wantIntent: true},
+		{name: "refund of the same order waits", operation: "SET_INTENT", value: intent(secondRefund, 10*time.Second, at(2*time.Minute+5*time.Second)), appendedAt: at(2*time.Minute + 5*time.Second), wantErr: ErrTransactionInProgress},
+		{name: "order released after the lock", operation: "SET_INTENT", value: intent(secondRefund, 10*time.Second, at(3*time.Minute)), appendedAt: at(3 * time.Minute), wantStatus: PendingTransactionStatus, wantAmount: 50, wantIntent: true},
+	}
+
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := json.Marshal(CommandPayload{Operation: tt.operation, Key: orderKey, Value: tt.value})
+			if err != nil {
+				t.Fatal(err)
+			}
+			response, ok := fsm.Apply(&raft.Log{Type: raft.LogCommand, Index: uint64(i + 1), Data: data, AppendedAt: tt.appendedAt}).(*ApplyResponse)
+			if !ok {
+				t.Fatalf("Apply() returned no response")
+			}
+			if tt.wantErr != nil || response.Error != nil {
+				if !errors.Is(response.Error, tt.wantErr) {
+					t.Fatalf("Apply() error = %v, want %v", response.Error, tt.wantErr)
+				}
+				return
+			}
+
+			var id string
+			if trx, ok := response.Data.(*Transaction); ok {
+				id = trx.ID
+				if trx.Status != tt.wantStatus {
+					t.Errorf("transaction status = %q, want %q", trx.Status, tt.wantStatus)
+				}
+				if tt.wantAmount != 0 && trx.Amount != tt.wantAmount {
+					t.Errorf("transaction amount = %d, want %d", trx.Amount, tt.wantAmount)
+				}
+			} else {
+				id = refunded.ID
+			}
+
+			stored, err := fsm.getIntent(IntentKey(orderKey, id))
+			if (err == nil) != tt.wantIntent {
+				t.Fatalf("intent of %s stored = %v, want %v", id, err == nil, tt.wantIntent)
+			}
+			if tt.operation == "SET_INTENT_OUTCOME" && (stored.Outcome == nil || stored.Outcome.Code != "00") {
+				t.Errorf("intent outcome = %+v, want the processor response", stored.Outcome)
+			}
+		})
+	}
+}
@@ -0,0 +1,176 @@
+package repo
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// Key layout of the FSM storage. Everything that belongs to a merchant lives
+// under the merchant prefix, so two merchants can use the same order id or
+// receive the same recurring token without touching each other's records.
+const (
+	merchantPrefix = "merchant/"
+	apiKeyPrefix   = "apikey/"
+
+	// AuthorizationIndexPrefix prefix of open authorizations, scanned by the expiration sweeper
+	AuthorizationIndexPrefix = "authorization/"
+
+	// IntentPrefix prefix of transactions sent to the processor without an applied outcome
+	IntentPrefix = "intent/"
+
+	// SchedulePrefix prefix of active subscriptions ordered by the next run, scanned by the scheduler
+	SchedulePrefix = "schedule/"
+
+	// OutboxPrefix prefix of events waiting for webhook delivery, ordered by the sequence
+	OutboxPrefix = "outbox/"
+	// outboxSequenceKey last sequence given to an event
+	outboxSequenceKey = "sequence/outbox"
+
+	// NodePrefix prefix of the HTTP addresses of the cluster nodes, keyed by raft server id
+	NodePrefix = "node/"
+
+	// HealthProbeKey key read by the health check of the node, never written
+	HealthProbeKey = "health/probe"
+)
+
+// NodeKey key of the cluster node record
+func NodeKey(nodeID string) string {
+	return NodePrefix + nodeID
+}
+
+// MerchantKey key of the merchant entity
+func MerchantKey(merchantID string) string {
+	return merchantPrefix + merchantID
+}
+
+// APIKeyKey key mapping an api key to the merchant id.
+// Only the sha256 of the api key is persisted.
+func APIKeyKey(apiKey string) string {
+	return apiKeyPrefix + HashAPIKey(apiKey)
+}
+
+// OrderKey key of the merchant order
+func OrderKey(merchantID, orderID string) string {
+	return fmt.Sprintf("%s%s/order/%s", merchantPrefix, merchantID, orderID)
+}
+
+// IsOrderKey reports whether the key is an order record, not a key nested under it
+func IsOrderKey(key string) bool {
+	_, orderID, ok := strings.Cut(strings.TrimPrefix(key, merchantPrefix), "/order/")
+	return ok && strings.HasPrefix(key, merchantPrefix) && !strings.Contains(orderID, "/")
+}
+
+// OrderTransactionPrefix prefix of the order transactions ordered as they were applied
+func OrderTransactionPrefix(orderKey string) string {
+	return orderKey + "/trx/"
+}
+
+// OrderTransactionKey key of the n-th transaction of the order
+func OrderTransactionKey(orderKey string, n int) string {
+	return fmt.Sprintf("%s%010d", OrderTransactionPrefix(orderKey), n)
+}
+
+// OrderHistoryPrefix prefix of the order state changes
+func OrderHistoryPrefix(orderKey string) string {
+	return orderKey + "/history/"
+}
+
+// OrderHistoryKey key of the state change made by the n-th transaction of the order
+func OrderHistoryKey(orderKey string, n int) string {
+	return fmt.Sprintf("%s%010d", OrderHistoryPrefix(orderKey), n)
+}
+
+// TokenKey key of the merchant recurring token
+func TokenKey(merchantID, token string) string {
+	return fmt.Sprintf("%s%s/token/%s", merchantPrefix, merchantID, token)
+}
+
+// MerchantOfKey returns merchant id of the key under the merchant prefix
+func MerchantOfKey(key string) string {
+	merchantID, _, _ := strings.Cut(strings.TrimPrefix(key, merchantPrefix), "/")
+	return merchantID
+}
+
+// HashAPIKey returns hex encoded sha256 of the api key
+func HashAPIKey(apiKey string) string {
+	h := sha256.Sum256([]byte(apiKey))
+	return hex.EncodeToString(h[:])
+}
+
+// IdempotencyKey key of the stored response for merchant idempotency key
+func IdempotencyKey(merchantID, key string) string {
+	return fmt.Sprintf("%s%s/idempotency/%s", merchantPrefix, merchantID, key)
+}
+
+// AuthorizationIndexKey key of the open authorization of the order
+func AuthorizationIndexKey(orderKey string) string {
+	return AuthorizationIndexPrefix + orderKey
+}
+
+// IntentOrderPrefix prefix of the intents of the order
+func IntentOrderPrefix(orderKey string) string {
+	return IntentPrefix + orderKey + "/"
+}
+
+// IntentKey key of the intent of the transaction
+func IntentKey(orderKey, transactionID string) string {
+	return IntentOrderPrefix(orderKey) + transactionID
+}
+
+// SubscriptionKey key of the merchant subscription
+func SubscriptionKey(merchantID, subscriptionID string) string {
+	return fmt.Sprintf("%s%s/subscription/%s", merchantPrefix, merchantID, subscriptionID)
+}
+
+// ScheduleKey key of the subscription in the schedule.
+// Keys are ordered by the run time, so due subscriptions are at the start of the prefix.
+func ScheduleKey(runAt int64, subscriptionKey string) string {
+	return fmt.Sprintf("%s%020d/%s", SchedulePrefix, runAt, subscriptionKey)
+}
+
+// WebhookKey key of the merchant webhook endpoint
+func WebhookKey(merchantID string) string {
+	return fmt.Sprintf("%s%s/webhook", merchantPrefix, merchantID)
+}
+
+// isWebhookKey reports whether the key holds a webhook endpoint of a merchant
+func isWebhookKey(key string) bool {
+	return strings.HasPrefix(key, merchantPrefix) && strings.HasSuffix(key, "/webhook")
+}
+
+// OutboxKey key of the event in the outbox
+func OutboxKey(sequence uint64) string {
+	return fmt.Sprintf("%s%020d", OutboxPrefix, sequence)
+}
+
+// DeliveryPrefix prefix of the merchant webhook delivery log
+func DeliveryPrefix(merchantID string) string {
+	return fmt.Sprintf("%s%s/delivery/", merchantPrefix, merchantID)
+}
+
+// DeliveryKey key of the delivery attempt, attempts are ordered by the event sequence
+func DeliveryKey(merchantID string, sequence uint64, attempt int) string {
+	return fmt.Sprintf("%s%020d/%04d", DeliveryPrefix(merchantID), sequence, attempt)
+}
+
+// TransactionsByCreatedPrefix prefix of the merchant transactions ordered by the creation time
+func TransactionsByCreatedPrefix(merchantID string) string {
+	return fmt.Sprintf("%s%s/trx/created/", merchantPrefix, merchantID)
+}
+
+// TransactionsByTokenPrefix prefix of the merchant transactions of the token ordered by the creation time
+func TransactionsByTokenPrefix(merchantID, token string) string {
+	return fmt.Sprintf("%s%s/trx/token/%s/", merchantPrefix, merchantID, token)
+}
+
+// TransactionsByCurrencyPrefix prefix of the merchant transactions in the currency ordered by the creation time
+func TransactionsByCurrencyPrefix(merchantID, currency string) string {
+	return fmt.Sprintf("%s%s/trx/currency/%s/", merchantPrefix, merchantID, currency)
+}
+
+// TransactionIndexKey key of the transaction in the index with the prefix
+func TransactionIndexKey(prefix string, createdAt int64, transactionID string) string {
+	return fmt.Sprintf("%s%020d/%s", prefix, createdAt, transactionID)
+}
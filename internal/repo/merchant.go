@@ -0,0 +1,55 @@
+package repo
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strings"
+)
+
+type Merchant struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// MerchantRecord is the value of the SET_MERCHANT command.
+// The merchant and its api key mapping are written in one store update.
+type MerchantRecord struct {
+	Merchant   Merchant `json:"merchant"`
+	APIKeyHash string   `json:"api_key_hash"`
+}
+
+// NewAPIKey generates random api key for a merchant
+func NewAPIKey() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+type MerchantRequest struct {
+	Name string `json:"name"`
+}
+
+func (m *MerchantRequest) Bind(r *http.Request) error {
+	errs := ValidationErrors{}
+	m.Name = strings.TrimSpace(m.Name)
+	if m.Name == "" || len(m.Name) > 128 {
+		errs.Add("name", errors.New("must be 1 to 128 characters"))
+	}
+
+	return errs.Err()
+}
+
+type MerchantResponse struct {
+	ID     string `json:"id"`
+	Name   string `json:"name"`
+	APIKey string `json:"api_key"`
+	Addr   string `json:"addr"`
+}
+
+func (rd *MerchantResponse) Render(w http.ResponseWriter, r *http.Request) error {
+	return nil
+}
@@ -0,0 +1,213 @@
+package repo
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/KushnerykPavel/raft-test-project/internal/storage"
+	"log"
+	"strings"
+)
+
+// MigrateMinorUnits rewrites orders stored with float amounts into minor units.
+// Every node runs it over its own copy of the data on start, the result is the
+// same on all of them since the conversion depends only on the stored record.
+// Log entries with float amounts replayed afterwards are converted on decode.
+func (b *FSM) MigrateMinorUnits() error {
+	updates := map[string][]byte{}
+
+	err := b.store.Scan(merchantPrefix, "", func(key string, value []byte) error {
+		if !IsOrderKey(key) {
+			return nil
+		}
+
+		var order Order
+		if err := json.Unmarshal(value, &order); err != nil {
+			return fmt.Errorf("error decoding order %s: %w", key, err)
+		}
+
+		migrated, err := json.Marshal(&order)
+		if err != nil {
+			return err
+		}
+
+		if !bytes.Equal(value, migrated) {
+			updates[key] = migrated
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if len(updates) == 0 {
+		return nil
+	}
+
+	if err := b.putChunked(updates); err != nil {
+		return err
+	}
+
+	log.Printf("migrated %d orders to minor units", len(updates))
+	return nil
+}
+
+// migrationChunk keys written by a migration in one store update
+const migrationChunk = 1000
+
+// putChunked writes the values in updates of migrationChunk keys, so a large
+// migration doesn't exceed the transaction size of the store
+func (b *FSM) putChunked(values map[string][]byte) error {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+
+	for len(keys) > 0 {
+		chunk := keys[:min(migrationChunk, len(keys))]
+		keys = keys[len(chunk):]
+
+		err := b.store.Update(func(w storage.Writer) error {
+			for _, key := range chunk {
+				if err := w.Put(key, values[key]); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// flatKeysChunk records moved by one flat keys migration command
+const flatKeysChunk = 100
+
+// FlatKeysMigration command moving records written before merchants existed, when
+// orders and recurring tokens were stored under bare keys, under the merchant
+type FlatKeysMigration struct {
+	MerchantID string `json:"merchant_id"`
+	// After flat key the previous command stopped at, empty for the first command
+	After string `json:"after,omitempty"`
+
+	// Moved records moved by the command
+	Moved int `json:"moved"`
+	// Next flat key to continue after, empty once every key is examined
+	Next string `json:"next,omitempty"`
+}
+
+// migrateFlatKeys moves up to flatKeysChunk bare orders and recurring tokens under the
+// merchant. It is applied through raft, so every node moves the same keys at the same
+// index. Bare transaction records keep their keys, the current layout stores them so too.
+// A record whose merchant key is taken already is left where it is.
+func (b *FSM) migrateFlatKeys(c *changes, value any) (*FlatKeysMigration, error) {
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	migration := &FlatKeysMigration{}
+	if err := json.Unmarshal(raw, migration); err != nil {
+		return nil, err
+	}
+	migration.Moved = 0
+	migration.Next = ""
+
+	if _, err := b.store.Get(MerchantKey(migration.MerchantID)); err != nil {
+		return nil, fmt.Errorf("merchant %s: %w", migration.MerchantID, err)
+	}
+
+	err = b.store.Scan("", migration.After, func(key string, val []byte) error {
+		if key == migration.After || strings.Contains(key, "/") {
+			return nil
+		}
+
+		moved, err := b.moveFlatKey(c, migration.MerchantID, key, val)
+		if err != nil {
+			return fmt.Errorf("error moving %s: %w", key, err)
+		}
+		if !moved {
+			return nil
+		}
+
+		migration.Moved++
+		if migration.Moved == flatKeysChunk {
+			migration.Next = key
+			return storage.ErrStop
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if migration.Moved > 0 {
+		log.Printf("migrate_flat_keys: moved %d records under merchant %s", migration.Moved, migration.MerchantID)
+	}
+	return migration, nil
+}
+
+// moveFlatKey moves the bare order or recurring token under the merchant.
+// It returns false for any other record.
+func (b *FSM) moveFlatKey(c *changes, merchantID, key string, val []byte) (bool, error) {
+	trimmed := bytes.TrimSpace(val)
+	if len(trimmed) == 0 {
+		return false, nil
+	}
+
+	// orders were stored as bare transaction lists
+	if trimmed[0] == '[' {
+		orderKey := OrderKey(merchantID, key)
+		if taken, err := b.exists(orderKey); err != nil || taken {
+			if taken {
+				log.Printf("migrate_flat_keys: order %s exists under merchant %s, the bare order is left", key, merchantID)
+			}
+			return false, err
+		}
+
+		order := &Order{}
+		if err := json.Unmarshal(trimmed, order); err != nil {
+			return false, err
+		}
+		order.ID = key
+		order.Index = b.index
+		for i := range order.Transactions {
+			order.Transactions[i].OrderID = key
+			b.indexTransaction(c, orderKey, &order.Transactions[i])
+		}
+		splitOrder(c, orderKey, order)
+		c.setOrder(orderKey, order)
+		c.delete(key)
+		return true, nil
+	}
+
+	// recurring tokens were stored as the pay request of the card
+	var token struct {
+		CardNumber *string `json:"card_number"`
+	}
+	if trimmed[0] != '{' || json.Unmarshal(trimmed, &token) != nil || token.CardNumber == nil {
+		return false, nil
+	}
+
+	tokenKey := TokenKey(merchantID, key)
+	if taken, err := b.exists(tokenKey); err != nil || taken {
+		if taken {
+			log.Printf("migrate_flat_keys: token %s exists under merchant %s, the bare token is left", key, merchantID)
+		}
+		return false, err
+	}
+	c.set(tokenKey, json.RawMessage(append([]byte(nil), trimmed...)))
+	c.delete(key)
+	return true, nil
+}
+
+// exists reports whether the key is stored
+func (b *FSM) exists(key string) (bool, error) {
+	_, err := b.store.Get(key)
+	if errors.Is(err, storage.ErrNotFound) {
+		return false, nil
+	}
+	return err == nil, err
+}
@@ -0,0 +1,129 @@
+package repo
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/KushnerykPavel/raft-test-project/internal/storage"
+	"github.com/hashicorp/raft"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMigrateFlatKeys(t *testing.T) {
+	bareOrder := `[{"id":"t1","type":"first","amount":10.5,"currency":"USD"}]`
+	bareToken := `{"card_number":"4111111111111111","expired_at":"12/30","cvv":"123","amount":10.5,"currency":"USD","order_id":"o1"}`
+	bareTransaction := `{"id":"t1","type":"first","amount":10.5,"currency":"USD"}`
+
+	manyOrders := map[string]string{}
+	for i := 0; i < flatKeysChunk+20; i++ {
+		manyOrders[fmt.Sprintf("o%03d", i)] = bareOrder
+	}
+
+	tests := []struct {
+		name       string
+		merchantID string
+		stored     map[string]string
+		wantMoved  []int
+		wantKept   []string
+		wantOrder  bool
+		wantErr    bool
+	}{
+		{
+			name:       "order and token",
+			merchantID: "m1",
+			stored:     map[string]string{"o1": bareOrder, "tok": bareToken, "t1": bareTransaction},
+			wantMoved:  []int{2},
+			wantKept:   []string{"t1"},
+			wantOrder:  true,
+		},
+		{
+			name:       "keys taken under the merchant",
+			merchantID: "m1",
+			stored:     map[string]string{"o1": bareOrder, OrderKey("m1", "o1"): `{"id":"o1","state":"pending"}`, "tok": bareToken, TokenKey("m1", "tok"): `{}`},
+			wantMoved:  []int{0},
+			wantKept:   []string{"o1", "tok"},
+		},
+		{
+			name:       "over chunks",
+			merchantID: "m1",
+			stored:     manyOrders,
+			wantMoved:  []int{flatKeysChunk, 20},
+		},
+		{
+			name:       "unknown merchant",
+			merchantID: "m2",
+			stored:     map[string]string{"o1": bareOrder},
+			wantErr:    true,
+			wantKept:   []string{"o1"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := storage.NewMemory()
+			fsm := NewFSM(store, time.Hour)
+			if err := store.Put(MerchantKey("m1"), []byte(`{"id":"m1","name":"shop"}`)); err != nil {
+				t.Fatal(err)
+			}
+			for key, value := range tt.stored {
+				if err := store.Put(key, []byte(value)); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			migration := FlatKeysMigration{MerchantID: tt.merchantID}
+			var moved []int
+			for index := uint64(1); ; index++ {
+				data, err := json.Marshal(CommandPayload{Operation: "MIGRATE_FLAT_KEYS", Value: migration})
+				if err != nil {
+					t.Fatal(err)
+				}
+				response := fsm.Apply(&raft.Log{Type: raft.LogCommand, Index: index, Data: data}).(*ApplyResponse)
+				if (response.Error != nil) != tt.wantErr {
+					t.Fatalf("Apply() error = %v, wantErr %v", response.Error, tt.wantErr)
+				}
+				if response.Error != nil {
+					break
+				}
+
+				applied := response.Data.(*FlatKeysMigration)
+				moved = append(moved, applied.Moved)
+				if applied.Next == "" {
+					break
+				}
+				migration.After = applied.Next
+			}
+			if fmt.Sprint(moved) != fmt.Sprint(tt.wantMoved) {
+				t.Errorf("moved = %v, want %v", moved, tt.wantMoved)
+			}
+
+			kept := map[string]bool{}
+			for _, key := range tt.wantKept {
+				kept[key] = true
+			}
+			for key := range tt.stored {
+				if strings.Contains(key, "/") {
+					continue
+				}
+				if _, err := store.Get(key); (err == nil) != kept[key] {
+					t.Errorf("flat key %s stored = %v, want %v", key, err == nil, kept[key])
+				}
+			}
+			if !tt.wantOrder {
+				return
+			}
+
+			order, err := LoadOrder(store, OrderKey("m1", "o1"))
+			if err != nil {
+				t.Fatalf("LoadOrder() error = %v", err)
+			}
+			if order.ID != "o1" || order.State != PaidOrderState || len(order.Transactions) != 1 || order.Transactions[0].Amount != 1050 {
+				t.Errorf("moved order = %+v", order)
+			}
+			if _, err := store.Get(TokenKey("m1", "tok")); err != nil {
+				t.Errorf("moved token: %v", err)
+			}
+		})
+	}
+}
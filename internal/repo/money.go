@@ -0,0 +1,112 @@
+package repo
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// currencyExponents ISO 4217 number of digits after the decimal separator.
+// Amounts are kept as integers in minor units of the currency, e.g. cents for USD.
+var currencyExponents = map[string]int{
+	"AED": 2, "AFN": 2, "ALL": 2, "AMD": 2, "ANG": 2, "AOA": 2, "ARS": 2, "AUD": 2, "AWG": 2, "AZN": 2,
+	"BAM": 2, "BBD": 2, "BDT": 2, "BGN": 2, "BHD": 3, "BIF": 0, "BMD": 2, "BND": 2, "BOB": 2, "BRL": 2,
+	"BSD": 2, "BTN": 2, "BWP": 2, "BYN": 2, "BZD": 2, "CAD": 2, "CDF": 2, "CHF": 2, "CLF": 4, "CLP": 0,
+	"CNY": 2, "COP": 2, "CRC": 2, "CUP": 2, "CVE": 2, "CZK": 2, "DJF": 0, "DKK": 2, "DOP": 2, "DZD": 2,
+	"EGP": 2, "ERN": 2, "ETB": 2, "EUR": 2, "FJD": 2, "FKP": 2, "GBP": 2, "GEL": 2, "GHS": 2, "GIP": 2,
+	"GMD": 2, "GNF": 0, "GTQ": 2, "GYD": 2, "HKD": 2, "HNL": 2, "HTG": 2, "HUF": 2, "IDR": 2, "ILS": 2,
+	"INR": 2, "IQD": 3, "IRR": 2, "ISK": 0, "JMD": 2, "JOD": 3, "JPY": 0, "KES": 2, "KGS": 2, "KHR": 2,
+	"KMF": 0, "KPW": 2, "KRW": 0, "KWD": 3, "KYD": 2, "KZT": 2, "LAK": 2, "LBP": 2, "LKR": 2, "LRD": 2,
+	"LSL": 2, "LYD": 3, "MAD": 2, "MDL": 2, "MGA": 2, "MKD": 2, "MMK": 2, "MNT": 2, "MOP": 2, "MRU": 2,
+	"MUR": 2, "MVR": 2, "MWK": 2, "MXN": 2, "MYR": 2, "MZN": 2, "NAD": 2, "NGN": 2, "NIO": 2, "NOK": 2,
+	"NPR": 2, "NZD": 2, "OMR": 3, "PAB": 2, "PEN": 2, "PGK": 2, "PHP": 2, "PKR": 2, "PLN": 2, "PYG": 0,
+	"QAR": 2, "RON": 2, "RSD": 2, "RUB": 2, "RWF": 0, "SAR": 2, "SBD": 2, "SCR": 2, "SDG": 2, "SEK": 2,
+	"SGD": 2, "SHP": 2, "SLE": 2, "SOS": 2, "SRD": 2, "SSP": 2, "STN": 2, "SVC": 2, "SYP": 2, "SZL": 2,
+	"THB": 2, "TJS": 2, "TMT": 2, "TND": 3, "TOP": 2, "TRY": 2, "TTD": 2, "TWD": 2, "TZS": 2, "UAH": 2,
+	"UGX": 0, "USD": 2, "UYI": 0, "UYU": 2, "UYW": 4, "UZS": 2, "VES": 2, "VND": 0, "VUV": 0, "WST": 2,
+	"XAF": 0, "XCD": 2, "XOF": 0, "XPF": 0, "YER": 2, "ZAR": 2, "ZMW": 2, "ZWL": 2,
+}
+
+// CurrencyExponent returns number of minor unit digits of ISO 4217 currency
+func CurrencyExponent(currency string) (int, error) {
+	exp, ok := currencyExponents[currency]
+	if !ok {
+		return 0, fmt.Errorf("unknown currency %q", currency)
+	}
+	return exp, nil
+}
+
+// ParseAmount converts decimal amount such as "10.50" into minor units of the currency.
+// The amount is parsed from its text, so no precision is lost on the way.
+func ParseAmount(amount json.Number, currency string) (int64, error) {
+	exp, err := CurrencyExponent(currency)
+	if err != nil {
+		return 0, err
+	}
+
+	value := strings.TrimSpace(amount.String())
+	if value == "" {
+		return 0, fmt.Errorf("amount must not be empty")
+	}
+
+	negative := strings.HasPrefix(value, "-")
+	value = strings.TrimPrefix(value, "-")
+
+	whole, fraction, _ := strings.Cut(value, ".")
+	if whole == "" || !isDigits(whole) || !isDigits(fraction) {
+		return 0, fmt.Errorf("amount %q must be a plain decimal number", amount)
+	}
+
+	fraction = strings.TrimRight(fraction, "0")
+	if len(fraction) > exp {
+		return 0, fmt.Errorf("amount %s has more than %d decimal places for %s", amount, exp, currency)
+	}
+	fraction += strings.Repeat("0", exp-len(fraction))
+
+	minor, err := strconv.ParseInt(whole+fraction, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("amount %s is out of range", amount)
+	}
+
+	if negative {
+		minor = -minor
+	}
+	return minor, nil
+}
+
+// FormatAmount formats minor units as decimal amount of the currency
+func FormatAmount(minor int64, currency string) string {
+	exp, err := CurrencyExponent(currency)
+	if err != nil || exp == 0 {
+		return strconv.FormatInt(minor, 10)
+	}
+
+	sign := ""
+	if minor < 0 {
+		sign = "-"
+		minor = -minor
+	}
+
+	value := fmt.Sprintf("%0*d", exp+1, minor)
+	return sign + value[:len(value)-exp] + "." + value[len(value)-exp:]
+}
+
+// legacyAmount converts float amount written before minor units were introduced
+func legacyAmount(amount float64, currency string) int64 {
+	exp, err := CurrencyExponent(currency)
+	if err != nil {
+		exp = 2
+	}
+	return int64(math.Round(amount * math.Pow10(exp)))
+}
+
+func isDigits(s string) bool {
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
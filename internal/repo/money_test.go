@@ -0,0 +1,146 @@
+package repo
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestCurrencyExponent(t *testing.T) {
+	tests := []struct {
+		currency string
+		exp      int
+		wantErr  bool
+	}{
+		{currency: "USD", exp: 2},
+		{currency: "EUR", exp: 2},
+		{currency: "JPY", exp: 0},
+		{currency: "KRW", exp: 0},
+		{currency: "BHD", exp: 3},
+		{currency: "KWD", exp: 3},
+		{currency: "CLF", exp: 4},
+		{currency: "UYW", exp: 4},
+		{currency: "usd", wantErr: true},
+		{currency: "XXX", wantErr: true},
+		{currency: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.currency, func(t *testing.T) {
+			exp, err := CurrencyExponent(tt.currency)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("CurrencyExponent(%q) error = %v, wantErr %v", tt.currency, err, tt.wantErr)
+			}
+			if exp != tt.exp {
+				t.Errorf("CurrencyExponent(%q) = %d, want %d", tt.currency, exp, tt.exp)
+			}
+		})
+	}
+}
+
+func TestParseAmount(t *testing.T) {
+	tests := []struct {
+		name     string
+		amount   json.Number
+		currency string
+		minor    int64
+		wantErr  bool
+	}{
+		{name: "whole", amount: "10", currency: "USD", minor: 1000},
+		{name: "cents", amount: "10.50", currency: "USD", minor: 1050},
+		{name: "one decimal place", amount: "10.5", currency: "USD", minor: 1050},
+		{name: "trailing zeros", amount: "10.5000", currency: "USD", minor: 1050},
+		{name: "no float rounding", amount: "0.29", currency: "USD", minor: 29},
+		{name: "large", amount: "92233720368547758.07", currency: "USD", minor: 9223372036854775807},
+		{name: "negative", amount: "-1.25", currency: "USD", minor: -125},
+		{name: "spaces", amount: " 1.00 ", currency: "USD", minor: 100},
+		{name: "zero exponent", amount: "500", currency: "JPY", minor: 500},
+		{name: "zero exponent trailing zeros", amount: "500.00", currency: "JPY", minor: 500},
+		{name: "three decimal places", amount: "1.234", currency: "BHD", minor: 1234},
+		{name: "four decimal places", amount: "1.2345", currency: "CLF", minor: 12345},
+		{name: "too many decimal places", amount: "10.505", currency: "USD", wantErr: true},
+		{name: "fraction of zero exponent", amount: "500.5", currency: "JPY", wantErr: true},
+		{name: "empty", amount: "", currency: "USD", wantErr: true},
+		{name: "no whole part", amount: ".50", currency: "USD", wantErr: true},
+		{name: "exponent notation", amount: "1e2", currency: "USD", wantErr: true},
+		{name: "plus sign", amount: "+1.00", currency: "USD", wantErr: true},
+		{name: "letters", amount: "ten", currency: "USD", wantErr: true},
+		{name: "out of range", amount: "92233720368547758.08", currency: "USD", wantErr: true},
+		{name: "unknown currency", amount: "1.00", currency: "ABC", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			minor, err := ParseAmount(tt.amount, tt.currency)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseAmount(%q, %s) error = %v, wantErr %v", tt.amount, tt.currency, err, tt.wantErr)
+			}
+			if minor != tt.minor {
+				t.Errorf("ParseAmount(%q, %s) = %d, want %d", tt.amount, tt.currency, minor, tt.minor)
+			}
+		})
+	}
+}
+
+func TestFormatAmount(t *testing.T) {
+	tests := []struct {
+		name     string
+		minor    int64
+		currency string
+		want     string
+	}{
+		{name: "cents", minor: 1050, currency: "USD", want: "10.50"},
+		{name: "below one", minor: 5, currency: "USD", want: "0.05"},
+		{name: "zero", minor: 0, currency: "USD", want: "0.00"},
+		{name: "negative", minor: -125, currency: "USD", want: "-1.25"},
+		{name: "negative below one", minor: -5, currency: "USD", want: "-0.05"},
+		{name: "zero exponent", minor: 500, currency: "JPY", want: "500"},
+		{name: "three decimal places", minor: 1234, currency: "BHD", want: "1.234"},
+		{name: "four decimal places", minor: 5, currency: "CLF", want: "0.0005"},
+		{name: "unknown currency", minor: 1050, currency: "ABC", want: "1050"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := FormatAmount(tt.minor, tt.currency); got != tt.want {
+				t.Errorf("FormatAmount(%d, %s) = %q, want %q", tt.minor, tt.currency, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormatParseAmountRoundTrip(t *testing.T) {
+	for currency := range currencyExponents {
+		for _, minor := range []int64{0, 1, 99, 100, 12345, -7} {
+			formatted := FormatAmount(minor, currency)
+			parsed, err := ParseAmount(json.Number(formatted), currency)
+			if err != nil {
+				t.Fatalf("ParseAmount(%q, %s) error = %v", formatted, currency, err)
+			}
+			if parsed != minor {
+				t.Errorf("ParseAmount(FormatAmount(%d, %s)) = %d", minor, currency, parsed)
+			}
+		}
+	}
+}
+
+func TestLegacyAmount(t *testing.T) {
+	tests := []struct {
+		name     string
+		amount   float64
+		currency string
+		want     int64
+	}{
+		{name: "cents", amount: 10.5, currency: "USD", want: 1050},
+		{name: "float error rounded", amount: 0.29, currency: "USD", want: 29},
+		{name: "zero exponent", amount: 500, currency: "JPY", want: 500},
+		{name: "unknown currency as cents", amount: 1.25, currency: "ABC", want: 125},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := legacyAmount(tt.amount, tt.currency); got != tt.want {
+				t.Errorf("legacyAmount(%v, %s) = %d, want %d", tt.amount, tt.currency, got, tt.want)
+			}
+		})
+	}
+}
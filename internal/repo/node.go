@@ -0,0 +1,33 @@
+package repo
+
+import (
+	"net/http"
+)
+
+// Node addresses of a cluster node. It is written by the leader when
+// the node joins and by every leader for itself, so clients can find
+// the HTTP address of a raft server.
+type Node struct {
+	ID          string `json:"id"`
+	RaftAddress string `json:"raft_address"`
+	HTTPAddress string `json:"http_address"`
+}
+
+// ClusterNode server of the latest raft configuration
+type ClusterNode struct {
+	ID          string `json:"id"`
+	RaftAddress string `json:"raft_address"`
+	HTTPAddress string `json:"http_address,omitempty"` // empty until the node is registered
+	Suffrage    string `json:"suffrage"`
+	Leader      bool   `json:"leader"`
+}
+
+type ClusterResponse struct {
+	LeaderID string        `json:"leader_id,omitempty"`
+	Nodes    []ClusterNode `json:"nodes"`
+	Addr     string        `json:"addr"`
+}
+
+func (rd *ClusterResponse) Render(w http.ResponseWriter, r *http.Request) error {
+	return nil
+}
@@ -0,0 +1,442 @@
+package repo
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+type OrderState string
+
+var (
+	PendingOrderState           OrderState = "pending"
+	AuthorizedOrderState        OrderState = "authorized"
+	VoidedOrderState            OrderState = "voided"
+	ExpiredOrderState           OrderState = "expired"
+	PaidOrderState              OrderState = "paid"
+	PartiallyRefundedOrderState OrderState = "partially_refunded"
+	RefundedOrderState          OrderState = "refunded"
+	FailedOrderState            OrderState = "failed"
+)
+
+// ErrTransitionNotAllowed is returned by Apply when the transaction
+// would move the order into a state not reachable from the current one.
+var ErrTransitionNotAllowed = errors.New("order state transition is not allowed")
+
+// ErrRefundExceedsCaptured is returned by Apply when refunds of the order
+// would be more than was captured in the currency.
+var ErrRefundExceedsCaptured = errors.New("refund exceeds captured amount")
+
+// ErrCaptureExceedsAuthorized is returned by Apply when the capture
+// doesn't fit into the open authorization of the order.
+var ErrCaptureExceedsAuthorized = errors.New("capture exceeds authorized amount")
+
+// ErrAuthorizationExpired is returned by Apply when the capture
+// comes after the open authorization of the order has expired.
+var ErrAuthorizationExpired = errors.New("authorization has expired")
+
+// rejectedError the order refused the transaction. It is a business outcome
+// of the command, unlike storage errors.
+type rejectedError struct {
+	err error
+}
+
+func (e *rejectedError) Error() string { return e.err.Error() }
+
+func (e *rejectedError) Unwrap() error { return e.err }
+
+// isRejected reports whether the order refused the transaction
+func isRejected(err error) bool {
+	var rejected *rejectedError
+	return errors.As(err, &rejected)
+}
+
+// orderTransitions allowed moves between order states by succeeded transactions.
+// Staying in the same state must be listed explicitly as well. A paid order stays
+// paid only by recurring charges, see validateCharge, and a failed transaction
+// is recorded in any state without moving the order.
+var orderTransitions = map[OrderState][]OrderState{
+	PendingOrderState:           {PaidOrderState, AuthorizedOrderState, FailedOrderState},
+	AuthorizedOrderState:        {AuthorizedOrderState, PaidOrderState, VoidedOrderState, ExpiredOrderState},
+	VoidedOrderState:            {},
+	ExpiredOrderState:           {},
+	PaidOrderState:              {PaidOrderState, PartiallyRefundedOrderState, RefundedOrderState},
+	PartiallyRefundedOrderState: {PaidOrderState, PartiallyRefundedOrderState, RefundedOrderState},
+	RefundedOrderState:          {PaidOrderState},
+	FailedOrderState:            {PaidOrderState, AuthorizedOrderState, FailedOrderState},
+}
+
+// Authorization funds held on the order until capture, void or expiration
+type Authorization struct {
+	TransactionID string `json:"transaction_id"`
+	Amount        int64  `json:"amount_minor"` // minor units of the currency
+	Currency      string `json:"currency"`
+	ExpiresAt     int64  `json:"expires_at"`
+}
+
+// UnmarshalJSON reads authorization, converting float amount written before minor units
+func (a *Authorization) UnmarshalJSON(data []byte) error {
+	type authorization Authorization
+	var value struct {
+		authorization
+		AmountMinor   *int64      `json:"amount_minor"`
+		DecimalAmount json.Number `json:"amount"`
+	}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+
+	*a = Authorization(value.authorization)
+	amount, err := minorAmount(value.AmountMinor, value.DecimalAmount, a.Currency)
+	if err != nil {
+		return err
+	}
+	a.Amount = amount
+
+	return nil
+}
+
+// OrderEvent single change of the order state
+type OrderEvent struct {
+	From          OrderState `json:"from"`
+	To            OrderState `json:"to"`
+	TransactionID string     `json:"transaction_id"`
+}
+
+type Order struct {
+	ID    string     `json:"id"`
+	State OrderState `json:"state"`
+
+	// Transactions and History of the order. Each of them is kept under its own key
+	// next to the order record, see LoadOrder. In the record they are found only
+	// in orders written before that, which are split on start.
+	Transactions []Transaction `json:"transactions,omitempty"`
+	History      []OrderEvent  `json:"history,omitempty"`
+	// Count transactions stored under their own keys
+	Count int `json:"transaction_count"`
+
+	// Authorization open hold of the order, nil when there is nothing to capture
+	Authorization *Authorization `json:"authorization,omitempty"`
+
+	// Captured and Refunded totals of succeeded transactions by currency in minor units
+	Captured map[string]int64 `json:"captured_minor"`
+	Refunded map[string]int64 `json:"refunded_minor"`
+
+	// Index raft index of the command which changed the order last
+	Index uint64 `json:"index"`
+	// Token recurring token of the card the order was paid with last
+	Token string `json:"token,omitempty"`
+}
+
+// NewOrder creates order in pending state
+func NewOrder(id string) *Order {
+	return &Order{
+		ID:           id,
+		State:        PendingOrderState,
+		Transactions: []Transaction{},
+		History:      []OrderEvent{},
+		Captured:     map[string]int64{},
+		Refunded:     map[string]int64{},
+	}
+}
+
+// Transaction returns transaction of the order by id
+func (o *Order) Transaction(id string) *Transaction {
+	for i := range o.Transactions {
+		if o.Transactions[i].ID == id {
+			return &o.Transactions[i]
+		}
+	}
+	return nil
+}
+
+// LastCharge returns the latest succeeded charge of the order in the currency
+func (o *Order) LastCharge(currency string) *Transaction {
+	for i := len(o.Transactions) - 1; i >= 0; i-- {
+		trx := &o.Transactions[i]
+		if trx.Status == FailedTransactionStatus || trx.Currency != currency {
+			continue
+		}
+		switch trx.Type {
+		case FirstTransactionType, RecurringTransactionType, CaptureTransactionType:
+			return trx
+		}
+	}
+	return nil
+}
+
+// orderToken token of the card the order was paid with last
+func (o *Order) orderToken() string {
+	for i := len(o.Transactions) - 1; i >= 0; i-- {
+		if o.Transactions[i].Token != "" {
+			return o.Transactions[i].Token
+		}
+	}
+	return ""
+}
+
+// CanTransit reports whether the order can move into the state
+func (o *Order) CanTransit(to OrderState) bool {
+	for _, state := range orderTransitions[o.State] {
+		if state == to {
+			return true
+		}
+	}
+	return false
+}
+
+// nextState state the order moves into after the transaction
+func (o *Order) nextState(trx *Transaction) OrderState {
+	if trx.Status == FailedTransactionStatus {
+		if o.State == PendingOrderState {
+			return FailedOrderState
+		}
+		return o.State
+	}
+
+	switch trx.Type {
+	case FirstTransactionType, RecurringTransactionType, CaptureTransactionType:
+		return PaidOrderState
+	case AuthorizationTransactionType:
+		return AuthorizedOrderState
+	case VoidTransactionType:
+		return VoidedOrderState
+	case ExpirationTransactionType:
+		return ExpiredOrderState
+	case RefundTransactionType:
+		if o.fullyRefunded(trx) {
+			return RefundedOrderState
+		}
+		return PartiallyRefundedOrderState
+	}
+
+	return o.State
+}
+
+// fullyRefunded reports whether nothing is left to refund after the transaction
+func (o *Order) fullyRefunded(trx *Transaction) bool {
+	for currency, captured := range o.Captured {
+		refunded := o.Refunded[currency]
+		if currency == trx.Currency {
+			refunded += trx.Amount
+		}
+		if captured > refunded {
+			return false
+		}
+	}
+	return true
+}
+
+// validateRefund checks the refund against captured amount of its currency
+func (o *Order) validateRefund(trx *Transaction) error {
+	if trx.Amount <= 0 {
+		return fmt.Errorf("refund amount must be positive, got %s", FormatAmount(trx.Amount, trx.Currency))
+	}
+
+	captured := o.Captured[trx.Currency]
+	refunded := o.Refunded[trx.Currency]
+	if refunded+trx.Amount > captured {
+		return fmt.Errorf("%w: order %s captured %s %s, refunded %s, requested %s",
+			ErrRefundExceedsCaptured, o.ID, FormatAmount(captured, trx.Currency), trx.Currency,
+			FormatAmount(refunded, trx.Currency), FormatAmount(trx.Amount, trx.Currency))
+	}
+
+	return nil
+}
+
+// validateHold checks the transaction against the open authorization at now.
+// Capture of zero amount or without currency is filled from the authorization.
+// Zero now skips the expiration check.
+func (o *Order) validateHold(trx *Transaction, now time.Time) error {
+	switch trx.Type {
+	case AuthorizationTransactionType:
+		if o.Authorization != nil {
+			return fmt.Errorf("%w: order %s already has authorization %s",
+				ErrTransitionNotAllowed, o.ID, o.Authorization.TransactionID)
+		}
+		if trx.Amount <= 0 {
+			return fmt.Errorf("authorization amount must be positive, got %s", FormatAmount(trx.Amount, trx.Currency))
+		}
+		return nil
+	case CaptureTransactionType, VoidTransactionType, ExpirationTransactionType:
+	default:
+		if o.Authorization != nil {
+			return fmt.Errorf("%w: order %s has open authorization %s, capture or void it first",
+				ErrTransitionNotAllowed, o.ID, o.Authorization.TransactionID)
+		}
+		return nil
+	}
+
+	if o.Authorization == nil {
+		return fmt.Errorf("%w: order %s has no open authorization", ErrTransitionNotAllowed, o.ID)
+	}
+	if trx.AuthorizationID == "" {
+		trx.AuthorizationID = o.Authorization.TransactionID
+	}
+	if trx.AuthorizationID != o.Authorization.TransactionID {
+		return fmt.Errorf("%w: order %s authorization is %s, not %s",
+			ErrTransitionNotAllowed, o.ID, o.Authorization.TransactionID, trx.AuthorizationID)
+	}
+	if trx.Currency == "" {
+		trx.Currency = o.Authorization.Currency
+	}
+
+	if trx.Type != CaptureTransactionType {
+		trx.Amount = o.Authorization.Amount
+		return nil
+	}
+
+	if expiresAt := o.Authorization.ExpiresAt; expiresAt > 0 && !now.IsZero() && now.Unix() > expiresAt {
+		return fmt.Errorf("%w: order %s authorization %s expired at %s",
+			ErrAuthorizationExpired, o.ID, o.Authorization.TransactionID, time.Unix(expiresAt, 0).UTC().Format(time.RFC3339))
+	}
+	if trx.Amount == 0 {
+		trx.Amount = o.Authorization.Amount
+	}
+	if trx.Amount < 0 {
+		return fmt.Errorf("capture amount must be positive, got %s", FormatAmount(trx.Amount, trx.Currency))
+	}
+	if trx.Currency != o.Authorization.Currency || trx.Amount > o.Authorization.Amount {
+		return fmt.Errorf("%w: order %s authorized %s %s, requested %s %s",
+			ErrCaptureExceedsAuthorized, o.ID,
+			FormatAmount(o.Authorization.Amount, o.Authorization.Currency), o.Authorization.Currency,
+			FormatAmount(trx.Amount, trx.Currency), trx.Currency)
+	}
+
+	return nil
+}
+
+// addTotals accounts succeeded transaction in captured and refunded totals
+func (o *Order) addTotals(trx *Transaction) {
+	if trx.Status == FailedTransactionStatus {
+		return
+	}
+
+	switch trx.Type {
+	case FirstTransactionType, RecurringTransactionType, CaptureTransactionType:
+		o.Captured[trx.Currency] += trx.Amount
+	case RefundTransactionType:
+		o.Refunded[trx.Currency] += trx.Amount
+	}
+}
+
+// updateHold opens or releases the authorization after succeeded transaction.
+// Capture releases the rest of the hold, only one capture per authorization.
+func (o *Order) updateHold(trx *Transaction) {
+	if trx.Status == FailedTransactionStatus {
+		return
+	}
+
+	switch trx.Type {
+	case AuthorizationTransactionType:
+		o.Authorization = &Authorization{
+			TransactionID: trx.ID,
+			Amount:        trx.Amount,
+			Currency:      trx.Currency,
+			ExpiresAt:     trx.ExpiresAt,
+		}
+	case CaptureTransactionType, VoidTransactionType, ExpirationTransactionType:
+		o.Authorization = nil
+	}
+}
+
+// validateCharge allows one first charge per order: once the order was paid it is
+// charged again only by recurring transactions, a repeated first payment is refused.
+func (o *Order) validateCharge(trx *Transaction) error {
+	if trx.Type != FirstTransactionType || trx.Status == FailedTransactionStatus {
+		return nil
+	}
+	if o.State != PendingOrderState && o.State != FailedOrderState {
+		return fmt.Errorf("%w: order %s is %s, first transaction %s can't charge it again",
+			ErrTransitionNotAllowed, o.ID, o.State, trx.ID)
+	}
+	return nil
+}
+
+// AddTransaction appends the transaction at now and moves the order into the next state.
+// The order is left untouched when the transition is not allowed.
+func (o *Order) AddTransaction(trx *Transaction, now time.Time) error {
+	if err := o.validateCharge(trx); err != nil {
+		return err
+	}
+	return o.addTransaction(trx, now)
+}
+
+// addTransaction appends the transaction without the single first charge check,
+// orders written before the state machine may hold several of them
+func (o *Order) addTransaction(trx *Transaction, now time.Time) error {
+	to := o.nextState(trx)
+	if trx.Status != FailedTransactionStatus && !o.CanTransit(to) {
+		return fmt.Errorf("%w: order %s is %s, transaction %s %s would move it to %s",
+			ErrTransitionNotAllowed, o.ID, o.State, trx.Type, trx.ID, to)
+	}
+
+	if trx.Status != FailedTransactionStatus {
+		if err := o.validateHold(trx, now); err != nil {
+			return err
+		}
+		if trx.Type == RefundTransactionType {
+			if err := o.validateRefund(trx); err != nil {
+				return err
+			}
+		}
+	}
+
+	o.Transactions = append(o.Transactions, *trx)
+	o.History = append(o.History, OrderEvent{
+		From:          o.State,
+		To:            to,
+		TransactionID: trx.ID,
+	})
+	o.State = to
+	o.addTotals(trx)
+	o.updateHold(trx)
+	if trx.Token != "" {
+		o.Token = trx.Token
+	}
+
+	return nil
+}
+
+// UnmarshalJSON reads order record. Orders written before the state machine
+// are stored as a bare transaction list, they are treated as paid.
+func (o *Order) UnmarshalJSON(data []byte) error {
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '[' {
+		var transactions []Transaction
+		if err := json.Unmarshal(trimmed, &transactions); err != nil {
+			return err
+		}
+
+		*o = *NewOrder("")
+		for i := range transactions {
+			if err := o.addTransaction(&transactions[i], time.Time{}); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	type order Order
+	var value order
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+	*o = Order(value)
+
+	if o.Token == "" {
+		o.Token = o.orderToken()
+	}
+
+	// orders written before refunds or before minor units have no totals
+	if o.Captured == nil || o.Refunded == nil {
+		o.Captured = map[string]int64{}
+		o.Refunded = map[string]int64{}
+		for i := range o.Transactions {
+			o.addTotals(&o.Transactions[i])
+		}
+	}
+
+	return nil
+}
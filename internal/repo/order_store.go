@@ -0,0 +1,110 @@
+package repo
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/KushnerykPavel/raft-test-project/internal/storage"
+	"log"
+)
+
+// splitOrder moves transactions and history held by the order into their own keys.
+// The order record stays small, so adding a transaction doesn't rewrite all the previous ones.
+func splitOrder(c *changes, key string, order *Order) {
+	for i := range order.Transactions {
+		c.set(OrderTransactionKey(key, order.Count+i), &order.Transactions[i])
+	}
+	for i := range order.History {
+		c.set(OrderHistoryKey(key, order.Count+i), &order.History[i])
+	}
+
+	order.Count += len(order.Transactions)
+	order.Transactions = nil
+	order.History = nil
+}
+
+// LoadOrder reads the order with its transactions and history.
+// It returns storage.ErrNotFound when there is no such order.
+// The reader should be a snapshot, so the order and its children match.
+func LoadOrder(r storage.Reader, key string) (*Order, error) {
+	value, err := r.Get(key)
+	if err != nil {
+		return nil, err
+	}
+
+	order := &Order{}
+	if err := json.Unmarshal(value, order); err != nil {
+		return nil, err
+	}
+
+	transactions := make([]Transaction, 0, order.Count+len(order.Transactions))
+	err = r.Scan(OrderTransactionPrefix(key), "", func(_ string, val []byte) error {
+		var trx Transaction
+		if err := json.Unmarshal(val, &trx); err != nil {
+			return err
+		}
+		transactions = append(transactions, trx)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	history := make([]OrderEvent, 0, order.Count+len(order.History))
+	err = r.Scan(OrderHistoryPrefix(key), "", func(_ string, val []byte) error {
+		var event OrderEvent
+		if err := json.Unmarshal(val, &event); err != nil {
+			return err
+		}
+		history = append(history, event)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	order.Transactions = append(transactions, order.Transactions...)
+	order.History = append(history, order.History...)
+
+	return order, nil
+}
+
+// MigrateOrderTransactions splits orders stored with transaction arrays into per transaction keys.
+// It runs on every node over its own data on start, before the log is replayed.
+func (b *FSM) MigrateOrderTransactions() error {
+	legacy := map[string]*Order{}
+
+	err := b.store.Scan(merchantPrefix, "", func(key string, val []byte) error {
+		if !IsOrderKey(key) {
+			return nil
+		}
+
+		var order Order
+		if err := json.Unmarshal(val, &order); err != nil {
+			return fmt.Errorf("error decoding order %s: %w", key, err)
+		}
+
+		if len(order.Transactions) > 0 || len(order.History) > 0 {
+			legacy[key] = &order
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// one transaction per order keeps every commit small
+	for key, order := range legacy {
+		c := newChanges()
+		splitOrder(c, key, order)
+		c.set(key, order)
+
+		if err := b.commit(c); err != nil {
+			return fmt.Errorf("error splitting order %s: %w", key, err)
+		}
+	}
+
+	if len(legacy) > 0 {
+		log.Printf("split transactions of %d orders into their own keys", len(legacy))
+	}
+	return nil
+}
@@ -0,0 +1,512 @@
+package repo
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+)
+
+// orderWith replays the transactions on a new order
+func orderWith(t *testing.T, transactions ...Transaction) *Order {
+	t.Helper()
+
+	order := NewOrder("order")
+	for i := range transactions {
+		trx := transactions[i]
+		if err := order.AddTransaction(&trx, time.Time{}); err != nil {
+			t.Fatalf("AddTransaction(%s %s) error = %v", trx.Type, trx.ID, err)
+		}
+	}
+	return order
+}
+
+func charge(id string, amount int64) Transaction {
+	return Transaction{ID: id, Type: FirstTransactionType, Status: SucceededTransactionStatus, Amount: amount, Currency: "USD"}
+}
+
+func recurring(id string, amount int64) Transaction {
+	return Transaction{ID: id, Type: RecurringTransactionType, Status: SucceededTransactionStatus, Amount: amount, Currency: "USD"}
+}
+
+func declined(id string) Transaction {
+	return Transaction{ID: id, Type: FirstTransactionType, Status: FailedTransactionStatus, Amount: 100, Currency: "USD"}
+}
+
+func TestOrderTransitions(t *testing.T) {
+	tests := []struct {
+		name    string
+		before  []Transaction
+		trx     Transaction
+		want    OrderState
+		wantErr error
+	}{
+		{
+			name: "pending is paid",
+			trx:  charge("t1", 100),
+			want: PaidOrderState,
+		},
+		{
+			name: "pending fails",
+			trx:  declined("t1"),
+			want: FailedOrderState,
+		},
+		{
+			name:   "failed is paid on retry",
+			before: []Transaction{declined("t1")},
+			trx:    charge("t2", 100),
+			want:   PaidOrderState,
+		},
+		{
+			name:   "failed fails again",
+			before: []Transaction{declined("t1")},
+			trx:    declined("t2"),
+			want:   FailedOrderState,
+		},
+		{
+			name:   "paid is charged again",
+			before: []Transaction{charge("t1", 100)},
+			trx:    recurring("t2", 100),
+			want:   PaidOrderState,
+		},
+		{
+			name:    "paid can't be paid first again",
+			before:  []Transaction{charge("t1", 100)},
+			trx:     charge("t2", 100),
+			wantErr: ErrTransitionNotAllowed,
+		},
+		{
+			name:   "declined charge keeps paid order",
+			before: []Transaction{charge("t1", 100)},
+			trx:    declined("t2"),
+			want:   PaidOrderState,
+		},
+		{
+			name:   "refunded is charged again",
+			before: []Transaction{charge("t1", 100), {ID: "r1", Type: RefundTransactionType, Amount: 100, Currency: "USD"}},
+			trx:    recurring("t2", 100),
+			want:   PaidOrderState,
+		},
+		{
+			name:    "refunded can't be paid first again",
+			before:  []Transaction{charge("t1", 100), {ID: "r1", Type: RefundTransactionType, Amount: 100, Currency: "USD"}},
+			trx:     charge("t2", 100),
+			wantErr: ErrTransitionNotAllowed,
+		},
+		{
+			name:   "declined charge keeps refunded order",
+			before: []Transaction{charge("t1", 100), {ID: "r1", Type: RefundTransactionType, Amount: 100, Currency: "USD"}},
+			trx:    declined("t2"),
+			want:   RefundedOrderState,
+		},
+		{
+			name:   "declined charge keeps voided order",
+			before: []Transaction{{ID: "a1", Type: AuthorizationTransactionType, Amount: 100, Currency: "USD"}, {ID: "v1", Type: VoidTransactionType}},
+			trx:    declined("t1"),
+			want:   VoidedOrderState,
+		},
+		{
+			name:   "declined charge keeps expired order",
+			before: []Transaction{{ID: "a1", Type: AuthorizationTransactionType, Amount: 100, Currency: "USD"}, {ID: "e1", Type: ExpirationTransactionType}},
+			trx:    declined("t1"),
+			want:   ExpiredOrderState,
+		},
+		{
+			name:    "pending can't be refunded",
+			trx:     Transaction{ID: "r1", Type: RefundTransactionType, Amount: 100, Currency: "USD"},
+			wantErr: ErrTransitionNotAllowed,
+		},
+		{
+			name:    "failed can't be refunded",
+			before:  []Transaction{declined("t1")},
+			trx:     Transaction{ID: "r1", Type: RefundTransactionType, Amount: 100, Currency: "USD"},
+			wantErr: ErrTransitionNotAllowed,
+		},
+		{
+			name:    "voided is final",
+			before:  []Transaction{{ID: "a1", Type: AuthorizationTransactionType, Amount: 100, Currency: "USD"}, {ID: "v1", Type: VoidTransactionType}},
+			trx:     charge("t1", 100),
+			wantErr: ErrTransitionNotAllowed,
+		},
+		{
+			name:    "expired is final",
+			before:  []Transaction{{ID: "a1", Type: AuthorizationTransactionType, Amount: 100, Currency: "USD"}, {ID: "e1", Type: ExpirationTransactionType}},
+			trx:     charge("t1", 100),
+			wantErr: ErrTransitionNotAllowed,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			order := orderWith(t, tt.before...)
+			state := order.State
+			count := len(order.Transactions)
+
+			trx := tt.trx
+			err := order.AddTransaction(&trx, time.Time{})
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("AddTransaction() error = %v, want %v", err, tt.wantErr)
+				}
+				if order.State != state || len(order.Transactions) != count {
+					t.Fatalf("refused transaction changed the order: state %s, %d transactions", order.State, len(order.Transactions))
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("AddTransaction() error = %v", err)
+			}
+
+			if order.State != tt.want {
+				t.Errorf("state = %s, want %s", order.State, tt.want)
+			}
+			last := order.History[len(order.History)-1]
+			if last.From != state || last.To != tt.want || last.TransactionID != trx.ID {
+				t.Errorf("last history event = %+v, want %s -> %s by %s", last, state, tt.want, trx.ID)
+			}
+		})
+	}
+}
+
+func refund(id string, amount int64, currency string) Transaction {
+	return Transaction{ID: id, Type: RefundTransactionType, Status: SucceededTransactionStatus, Amount: amount, Currency: currency}
+}
+
+func TestOrderRefund(t *testing.T) {
+	eur := recurring("t2", 300)
+	eur.Currency = "EUR"
+
+	tests := []struct {
+		name         string
+		before       []Transaction
+		refund       Transaction
+		want         OrderState
+		wantRefunded int64
+		wantErr      error
+	}{
+		{
+			name:         "partial",
+			before:       []Transaction{charge("t1", 1000)},
+			refund:       refund("r1", 400, "USD"),
+			want:         PartiallyRefundedOrderState,
+			wantRefunded: 400,
+		},
+		{
+			name:         "full",
+			before:       []Transaction{charge("t1", 1000)},
+			refund:       refund("r1", 1000, "USD"),
+			want:         RefundedOrderState,
+			wantRefunded: 1000,
+		},
+		{
+			name:         "rest after partial",
+			before:       []Transaction{charge("t1", 1000), refund("r1", 400, "USD")},
+			refund:       refund("r2", 600, "USD"),
+			want:         RefundedOrderState,
+			wantRefunded: 1000,
+		},
+		{
+			name:         "several charges",
+			before:       []Transaction{charge("t1", 1000), recurring("t2", 500)},
+			refund:       refund("r1", 1200, "USD"),
+			want:         PartiallyRefundedOrderState,
+			wantRefunded: 1200,
+		},
+		{
+			name:         "declined charge is not refundable",
+			before:       []Transaction{charge("t1", 1000), declined("t2")},
+			refund:       refund("r1", 1000, "USD"),
+			want:         RefundedOrderState,
+			wantRefunded: 1000,
+		},
+		{
+			name:         "other currency left to refund",
+			before:       []Transaction{charge("t1", 1000), eur},
+			refund:       refund("r1", 1000, "USD"),
+			want:         PartiallyRefundedOrderState,
+			wantRefunded: 1000,
+		},
+		{
+			name:    "more than captured",
+			before:  []Transaction{charge("t1", 1000)},
+			refund:  refund("r1", 1001, "USD"),
+			wantErr: ErrRefundExceedsCaptured,
+		},
+		{
+			name:    "more than left after partial",
+			before:  []Transaction{charge("t1", 1000), refund("r1", 400, "USD")},
+			refund:  refund("r2", 601, "USD"),
+			wantErr: ErrRefundExceedsCaptured,
+		},
+		{
+			name:    "currency not captured",
+			before:  []Transaction{charge("t1", 1000)},
+			refund:  refund("r1", 100, "EUR"),
+			wantErr: ErrRefundExceedsCaptured,
+		},
+		{
+			name:    "fully refunded",
+			before:  []Transaction{charge("t1", 1000), refund("r1", 1000, "USD")},
+			refund:  refund("r2", 1, "USD"),
+			wantErr: ErrTransitionNotAllowed,
+		},
+		{
+			name:   "zero amount",
+			before: []Transaction{charge("t1", 1000)},
+			refund: refund("r1", 0, "USD"),
+		},
+		{
+			name:   "negative amount",
+			before: []Transaction{charge("t1", 1000)},
+			refund: refund("r1", -100, "USD"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			order := orderWith(t, tt.before...)
+			refunded := order.Refunded[tt.refund.Currency]
+
+			trx := tt.refund
+			err := order.AddTransaction(&trx, time.Time{})
+			if tt.want == "" {
+				if err == nil {
+					t.Fatal("AddTransaction() error = nil, want refusal")
+				}
+				if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+					t.Fatalf("AddTransaction() error = %v, want %v", err, tt.wantErr)
+				}
+				if order.Refunded[tt.refund.Currency] != refunded {
+					t.Fatalf("refused refund changed refunded total to %d", order.Refunded[tt.refund.Currency])
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("AddTransaction() error = %v", err)
+			}
+
+			if order.State != tt.want {
+				t.Errorf("state = %s, want %s", order.State, tt.want)
+			}
+			if got := order.Refunded[tt.refund.Currency]; got != tt.wantRefunded {
+				t.Errorf("refunded = %d, want %d", got, tt.wantRefunded)
+			}
+		})
+	}
+}
+
+func authorization(id string, amount, expiresAt int64) Transaction {
+	return Transaction{ID: id, Type: AuthorizationTransactionType, Status: SucceededTransactionStatus, Amount: amount, Currency: "USD", ExpiresAt: expiresAt}
+}
+
+func TestOrderHold(t *testing.T) {
+	expiresAt := time.Date(2026, time.March, 15, 12, 0, 0, 0, time.UTC)
+	beforeExpiry := expiresAt.Add(-time.Minute)
+
+	tests := []struct {
+		name         string
+		before       []Transaction
+		trx          Transaction
+		now          time.Time
+		want         OrderState
+		wantAmount   int64
+		wantCaptured int64
+		wantErr      error
+	}{
+		{
+			name:       "authorize",
+			trx:        authorization("a1", 1000, expiresAt.Unix()),
+			now:        beforeExpiry,
+			want:       AuthorizedOrderState,
+			wantAmount: 1000,
+		},
+		{
+			name:         "capture full amount when omitted",
+			before:       []Transaction{authorization("a1", 1000, expiresAt.Unix())},
+			trx:          Transaction{ID: "c1", Type: CaptureTransactionType},
+			now:          beforeExpiry,
+			want:         PaidOrderState,
+			wantAmount:   1000,
+			wantCaptured: 1000,
+		},
+		{
+			name:         "capture partial amount",
+			before:       []Transaction{authorization("a1", 1000, expiresAt.Unix())},
+			trx:          Transaction{ID: "c1", Type: CaptureTransactionType, Amount: 600},
+			now:          beforeExpiry,
+			want:         PaidOrderState,
+			wantAmount:   600,
+			wantCaptured: 600,
+		},
+		{
+			name:         "capture at the expiry second",
+			before:       []Transaction{authorization("a1", 1000, expiresAt.Unix())},
+			trx:          Transaction{ID: "c1", Type: CaptureTransactionType},
+			now:          expiresAt,
+			want:         PaidOrderState,
+			wantAmount:   1000,
+			wantCaptured: 1000,
+		},
+		{
+			name:         "capture without expiry check",
+			before:       []Transaction{authorization("a1", 1000, expiresAt.Unix())},
+			trx:          Transaction{ID: "c1", Type: CaptureTransactionType},
+			want:         PaidOrderState,
+			wantAmount:   1000,
+			wantCaptured: 1000,
+		},
+		{
+			name:       "void releases the full hold",
+			before:     []Transaction{authorization("a1", 1000, expiresAt.Unix())},
+			trx:        Transaction{ID: "v1", Type: VoidTransactionType, Amount: 1},
+			now:        beforeExpiry,
+			want:       VoidedOrderState,
+			wantAmount: 1000,
+		},
+		{
+			name:       "expiration after the expiry",
+			before:     []Transaction{authorization("a1", 1000, expiresAt.Unix())},
+			trx:        Transaction{ID: "e1", Type: ExpirationTransactionType},
+			now:        expiresAt.Add(time.Minute),
+			want:       ExpiredOrderState,
+			wantAmount: 1000,
+		},
+		{
+			name:    "capture more than authorized",
+			before:  []Transaction{authorization("a1", 1000, expiresAt.Unix())},
+			trx:     Transaction{ID: "c1", Type: CaptureTransactionType, Amount: 1001},
+			now:     beforeExpiry,
+			wantErr: ErrCaptureExceedsAuthorized,
+		},
+		{
+			name:    "capture in other currency",
+			before:  []Transaction{authorization("a1", 1000, expiresAt.Unix())},
+			trx:     Transaction{ID: "c1", Type: CaptureTransactionType, Amount: 100, Currency: "EUR"},
+			now:     beforeExpiry,
+			wantErr: ErrCaptureExceedsAuthorized,
+		},
+		{
+			name:    "capture after the expiry",
+			before:  []Transaction{authorization("a1", 1000, expiresAt.Unix())},
+			trx:     Transaction{ID: "c1", Type: CaptureTransactionType},
+			now:     expiresAt.Add(time.Second),
+			wantErr: ErrAuthorizationExpired,
+		},
+		{
+			name:    "capture of other authorization",
+			before:  []Transaction{authorization("a1", 1000, expiresAt.Unix())},
+			trx:     Transaction{ID: "c1", Type: CaptureTransactionType, AuthorizationID: "a2"},
+			now:     beforeExpiry,
+			wantErr: ErrTransitionNotAllowed,
+		},
+		{
+			name:    "second capture",
+			before:  []Transaction{authorization("a1", 1000, expiresAt.Unix()), {ID: "c1", Type: CaptureTransactionType, Amount: 600}},
+			trx:     Transaction{ID: "c2", Type: CaptureTransactionType, Amount: 400},
+			now:     beforeExpiry,
+			wantErr: ErrTransitionNotAllowed,
+		},
+		{
+			name:    "capture without authorization",
+			before:  []Transaction{charge("t1", 1000)},
+			trx:     Transaction{ID: "c1", Type: CaptureTransactionType},
+			now:     beforeExpiry,
+			wantErr: ErrTransitionNotAllowed,
+		},
+		{
+			name:    "second authorization",
+			before:  []Transaction{authorization("a1", 1000, expiresAt.Unix())},
+			trx:     authorization("a2", 1000, expiresAt.Unix()),
+			now:     beforeExpiry,
+			wantErr: ErrTransitionNotAllowed,
+		},
+		{
+			name:    "charge over open authorization",
+			before:  []Transaction{authorization("a1", 1000, expiresAt.Unix())},
+			trx:     charge("t1", 1000),
+			now:     beforeExpiry,
+			wantErr: ErrTransitionNotAllowed,
+		},
+		{
+			name: "authorize zero amount",
+			trx:  authorization("a1", 0, expiresAt.Unix()),
+			now:  beforeExpiry,
+		},
+		{
+			name:   "capture negative amount",
+			before: []Transaction{authorization("a1", 1000, expiresAt.Unix())},
+			trx:    Transaction{ID: "c1", Type: CaptureTransactionType, Amount: -1},
+			now:    beforeExpiry,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			order := orderWith(t, tt.before...)
+			hold := order.Authorization
+
+			trx := tt.trx
+			err := order.AddTransaction(&trx, tt.now)
+			if tt.want == "" {
+				if err == nil {
+					t.Fatal("AddTransaction() error = nil, want refusal")
+				}
+				if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+					t.Fatalf("AddTransaction() error = %v, want %v", err, tt.wantErr)
+				}
+				if order.Authorization != hold {
+					t.Fatalf("refused transaction changed the authorization to %+v", order.Authorization)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("AddTransaction() error = %v", err)
+			}
+
+			if order.State != tt.want {
+				t.Errorf("state = %s, want %s", order.State, tt.want)
+			}
+			if trx.Amount != tt.wantAmount {
+				t.Errorf("amount = %d, want %d", trx.Amount, tt.wantAmount)
+			}
+			if got := order.Captured["USD"]; got != tt.wantCaptured {
+				t.Errorf("captured = %d, want %d", got, tt.wantCaptured)
+			}
+			if tt.want == AuthorizedOrderState {
+				if order.Authorization == nil || order.Authorization.TransactionID != trx.ID {
+					t.Errorf("authorization = %+v, want hold of %s", order.Authorization, trx.ID)
+				}
+			} else if order.Authorization != nil {
+				t.Errorf("authorization = %+v, want released", order.Authorization)
+			}
+			if trx.Type != AuthorizationTransactionType && trx.AuthorizationID != "a1" {
+				t.Errorf("authorization id = %q, want a1", trx.AuthorizationID)
+			}
+		})
+	}
+}
+
+func TestOrderUnmarshalLegacy(t *testing.T) {
+	tests := []struct {
+		name      string
+		data      string
+		want      OrderState
+		wantCount int
+	}{
+		{name: "single charge", data: `[{"id":"t1","type":"first","amount":1.5,"currency":"USD"}]`, want: PaidOrderState, wantCount: 1},
+		{name: "repeated first charges", data: `[{"id":"t1","type":"first","amount":1,"currency":"USD"},{"id":"t2","type":"first","amount":2,"currency":"USD"}]`, want: PaidOrderState, wantCount: 2},
+		{name: "empty list", data: `[]`, want: PendingOrderState},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var order Order
+			if err := json.Unmarshal([]byte(tt.data), &order); err != nil {
+				t.Fatalf("Unmarshal() error = %v", err)
+			}
+			if order.State != tt.want || len(order.Transactions) != tt.wantCount {
+				t.Errorf("order is %s with %d transactions, want %s with %d", order.State, len(order.Transactions), tt.want, tt.wantCount)
+			}
+		})
+	}
+}
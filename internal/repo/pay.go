@@ -3,34 +3,54 @@ package repo
 import (
 	"crypto/sha1"
 	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"net/http"
+	"time"
 )
 
 type PayRequest struct {
-	CardNumber string  `json:"card_number"`
-	ExpiredAt  string  `json:"expired_at"`
-	Cvv        string  `json:"cvv"`
-	Amount     float64 `json:"amount"`
-	Currency   string  `json:"currency"`
-	OrderID    string  `json:"order_id"`
+	CardNumber string      `json:"card_number"`
+	ExpiredAt  string      `json:"expired_at"`
+	Cvv        string      `json:"cvv"`
+	Amount     json.Number `json:"amount"`
+	Currency   string      `json:"currency"`
+	OrderID    string      `json:"order_id"`
+
+	// AmountMinor amount in minor units of the currency, set by Bind
+	AmountMinor int64 `json:"amount_minor"`
 }
 
 func (p *PayRequest) Bind(r *http.Request) error {
-	return nil
+	errs := ValidationErrors{}
+	errs.Add("card_number", ValidateCardNumber(p.CardNumber))
+	errs.Add("expired_at", ValidateExpiry(p.ExpiredAt, time.Now()))
+	errs.Add("cvv", ValidateCvv(p.Cvv, CardSchemeOf(p.CardNumber)))
+	errs.Add("currency", ValidateCurrency(p.Currency))
+	errs.Add("order_id", ValidateOrderID(p.OrderID))
+
+	amount, err := ValidateAmount(p.Amount, p.Currency)
+	errs.Add("amount", err)
+	p.AmountMinor = amount
+
+	return errs.Err()
 }
 
 func (p *PayRequest) GetRecurringToken() string {
-	s := fmt.Sprintf("%s_%s_%s_%f_%s", p.CardNumber, p.ExpiredAt, p.Cvv, p.Amount, p.OrderID)
+	s := fmt.Sprintf("%s_%s_%s_%d_%s_%s", p.CardNumber, p.ExpiredAt, p.Cvv, p.AmountMinor, p.Currency, p.OrderID)
 	h := sha1.New()
 	h.Write([]byte(s))
 
 	return hex.EncodeToString(h.Sum(nil))
 }
 
+// PayResponse token is returned only when the payment succeeded
 type PayResponse struct {
-	Token string `json:"token"`
-	Addr  string `json:"addr"`
+	TransactionID string             `json:"transaction_id"`
+	Status        TransactionStatus  `json:"status"`
+	Processor     *ProcessorResponse `json:"processor,omitempty"`
+	Token         string             `json:"token,omitempty"`
+	Addr          string             `json:"addr"`
 }
 
 func (rd *PayResponse) Render(w http.ResponseWriter, r *http.Request) error {
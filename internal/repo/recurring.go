@@ -1,20 +1,38 @@
 package repo
 
-import "net/http"
+import (
+	"encoding/json"
+	"net/http"
+)
 
 type RecurringRequest struct {
-	Token    string  `json:"token"`
-	Amount   float64 `json:"amount"`
-	Currency string  `json:"currency"`
-	OrderID  string  `json:"order_id"`
+	Token    string      `json:"token"`
+	Amount   json.Number `json:"amount"`
+	Currency string      `json:"currency"`
+	OrderID  string      `json:"order_id"`
+
+	// AmountMinor amount in minor units of the currency, set by Bind
+	AmountMinor int64 `json:"-"`
 }
 
 func (p *RecurringRequest) Bind(r *http.Request) error {
-	return nil
+	errs := ValidationErrors{}
+	errs.Add("token", ValidateToken(p.Token))
+	errs.Add("currency", ValidateCurrency(p.Currency))
+	errs.Add("order_id", ValidateOrderID(p.OrderID))
+
+	amount, err := ValidateAmount(p.Amount, p.Currency)
+	errs.Add("amount", err)
+	p.AmountMinor = amount
+
+	return errs.Err()
 }
 
 type RecurringResponse struct {
-	Addr string `json:"addr"`
+	TransactionID string             `json:"transaction_id"`
+	Status        TransactionStatus  `json:"status"`
+	Processor     *ProcessorResponse `json:"processor,omitempty"`
+	Addr          string             `json:"addr"`
 }
 
 func (rd *RecurringResponse) Render(w http.ResponseWriter, r *http.Request) error {
@@ -0,0 +1,38 @@
+package repo
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+type RefundRequest struct {
+	OrderID  string      `json:"order_id"`
+	Amount   json.Number `json:"amount"`
+	Currency string      `json:"currency"`
+
+	// AmountMinor amount in minor units of the currency, set by Bind
+	AmountMinor int64 `json:"-"`
+}
+
+func (p *RefundRequest) Bind(r *http.Request) error {
+	errs := ValidationErrors{}
+	errs.Add("currency", ValidateCurrency(p.Currency))
+	errs.Add("order_id", ValidateOrderID(p.OrderID))
+
+	amount, err := ValidateAmount(p.Amount, p.Currency)
+	errs.Add("amount", err)
+	p.AmountMinor = amount
+
+	return errs.Err()
+}
+
+type RefundResponse struct {
+	TransactionID string             `json:"transaction_id"`
+	Status        TransactionStatus  `json:"status"`
+	Processor     *ProcessorResponse `json:"processor,omitempty"`
+	Addr          string             `json:"addr"`
+}
+
+func (rd *RefundResponse) Render(w http.ResponseWriter, r *http.Request) error {
+	return nil
+}
@@ -0,0 +1,136 @@
+package repo
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/KushnerykPavel/raft-test-project/internal/storage"
+	"github.com/hashicorp/raft"
+	"io"
+	"time"
+)
+
+// snapshotVersion format of the snapshot stream
+const snapshotVersion = 1
+
+// snapshotChunk keys written by a restore in one store update
+const snapshotChunk = 1000
+
+// snapshotHeader first line of the snapshot stream
+type snapshotHeader struct {
+	Version int `json:"version"`
+}
+
+// snapshotEntry key of the store in the snapshot stream
+type snapshotEntry struct {
+	Key   string `json:"k"`
+	Value []byte `json:"v"`
+	// ExpiresAt unix nanoseconds, zero never expires
+	ExpiresAt int64 `json:"e,omitempty"`
+}
+
+// fsmSnapshot streams a consistent view of the store into the snapshot sink
+type fsmSnapshot struct {
+	snapshot storage.Snapshot
+}
+
+// Persist writes the header and every key of the store.
+// It runs concurrently with Apply, the store snapshot keeps its view.
+func (s *fsmSnapshot) Persist(sink raft.SnapshotSink) error {
+	err := s.persist(sink)
+	if err != nil {
+		_ = sink.Cancel()
+		return err
+	}
+	return sink.Close()
+}
+
+func (s *fsmSnapshot) persist(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	if err := encoder.Encode(snapshotHeader{Version: snapshotVersion}); err != nil {
+		return err
+	}
+
+	return s.snapshot.Entries(func(key string, value []byte, expiresAt time.Time) error {
+		entry := snapshotEntry{Key: key, Value: value}
+		if !expiresAt.IsZero() {
+			entry.ExpiresAt = expiresAt.UnixNano()
+		}
+		return encoder.Encode(entry)
+	})
+}
+
+// Release releases the store snapshot
+func (s *fsmSnapshot) Release() {
+	s.snapshot.Release()
+}
+
+// restore replaces the store with the keys of the snapshot stream.
+// A stream without header was written when snapshots were empty, the
+// store of a persistent engine already holds the state then and is kept.
+func (b *FSM) restore(r io.Reader) (int, error) {
+	decoder := json.NewDecoder(r)
+
+	var header snapshotHeader
+	if err := decoder.Decode(&header); err != nil {
+		if errors.Is(err, io.EOF) {
+			return 0, nil
+		}
+		return 0, fmt.Errorf("error decoding snapshot header: %w", err)
+	}
+	if header.Version != snapshotVersion {
+		return 0, fmt.Errorf("unknown snapshot version %d", header.Version)
+	}
+
+	if err := b.store.DropAll(); err != nil {
+		return 0, err
+	}
+
+	restored := 0
+	entries := make([]snapshotEntry, 0, snapshotChunk)
+	for {
+		var entry snapshotEntry
+		err := decoder.Decode(&entry)
+		if err != nil && !errors.Is(err, io.EOF) {
+			return restored, fmt.Errorf("error decoding snapshot entry: %w", err)
+		}
+		if err == nil {
+			entries = append(entries, entry)
+		}
+
+		if len(entries) == snapshotChunk || (errors.Is(err, io.EOF) && len(entries) > 0) {
+			if err := b.putEntries(entries); err != nil {
+				return restored, err
+			}
+			restored += len(entries)
+			entries = entries[:0]
+		}
+		if errors.Is(err, io.EOF) {
+			return restored, nil
+		}
+	}
+}
+
+// putEntries writes the entries in one store update, expired ones are skipped
+func (b *FSM) putEntries(entries []snapshotEntry) error {
+	now := time.Now()
+	return b.store.Update(func(w storage.Writer) error {
+		for _, entry := range entries {
+			if entry.ExpiresAt == 0 {
+				if err := w.Put(entry.Key, entry.Value); err != nil {
+					return err
+				}
+				continue
+			}
+
+			ttl := time.Unix(0, entry.ExpiresAt).Sub(now)
+			if ttl <= 0 {
+				continue
+			}
+			if err := w.PutWithTTL(entry.Key, entry.Value, ttl); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
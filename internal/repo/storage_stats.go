@@ -0,0 +1,32 @@
+package repo
+
+import (
+	"github.com/KushnerykPavel/raft-test-project/internal/storage"
+	"net/http"
+	"time"
+)
+
+// StorageGCRun outcome of a garbage collection of the node storage
+type StorageGCRun struct {
+	StartedAt time.Time `json:"started_at"`
+	Duration  string    `json:"duration"`
+	// Reclaimed value log files rewritten by badger, expired keys deleted by the other engines
+	Reclaimed int `json:"reclaimed"`
+	// LogReclaimed value log files of the badger raft log rewritten
+	LogReclaimed int    `json:"log_reclaimed,omitempty"`
+	Error        string `json:"error,omitempty"`
+}
+
+func (rd *StorageGCRun) Render(w http.ResponseWriter, r *http.Request) error {
+	return nil
+}
+
+type StorageResponse struct {
+	Size   *storage.Size `json:"size,omitempty"` // engines which report it
+	LastGC *StorageGCRun `json:"last_gc,omitempty"`
+	Addr   string        `json:"addr"`
+}
+
+func (rd *StorageResponse) Render(w http.ResponseWriter, r *http.Request) error {
+	return nil
+}
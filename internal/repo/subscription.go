@@ -0,0 +1,182 @@
+package repo
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+type SubscriptionStatus string
+
+var (
+	ActiveSubscriptionStatus    SubscriptionStatus = "active"
+	PastDueSubscriptionStatus   SubscriptionStatus = "past_due"
+	SuspendedSubscriptionStatus SubscriptionStatus = "suspended"
+	CanceledSubscriptionStatus  SubscriptionStatus = "canceled"
+	CompletedSubscriptionStatus SubscriptionStatus = "completed"
+)
+
+// ErrSubscriptionNotActive is returned by Apply for a command on subscription which is not scheduled
+var ErrSubscriptionNotActive = errors.New("subscription is not active")
+
+// minSubscriptionInterval the shortest interval between charges
+const minSubscriptionInterval = time.Minute
+
+// Subscription charges the recurring token into the order every interval.
+// Times are unix seconds set by the leader, so replicas never read their own clock.
+type Subscription struct {
+	ID         string             `json:"id"`
+	MerchantID string             `json:"merchant_id"`
+	Token      string             `json:"token"`
+	OrderID    string             `json:"order_id"`
+	Amount     int64              `json:"amount_minor"` // minor units of the currency
+	Currency   string             `json:"currency"`
+	Interval   string             `json:"interval"`
+	Status     SubscriptionStatus `json:"status"`
+
+	// NextRunAt time of the next charge, a retry time while the subscription is past due
+	NextRunAt int64 `json:"next_run_at"`
+	// CycleAt time the current billing cycle is due, the next cycle is counted from it
+	CycleAt int64 `json:"cycle_at"`
+	// EndAt no charges after this time, zero means no end
+	EndAt int64 `json:"end_at,omitempty"`
+	// Runs number of charges made
+	Runs int `json:"runs"`
+
+	// Failures consecutive failed charges of the current cycle
+	Failures      int    `json:"failures"`
+	LastFailure   string `json:"last_failure,omitempty"`
+	LastFailureAt int64  `json:"last_failure_at,omitempty"`
+
+	// Dunning retry policy of failed charges fixed when the subscription is created,
+	// nil for subscriptions created before the policy was stored with them
+	Dunning *DunningPolicy `json:"dunning,omitempty"`
+}
+
+// Scheduled reports whether the subscription is in the schedule
+func (s *Subscription) Scheduled() bool {
+	return s.Status == ActiveSubscriptionStatus || s.Status == PastDueSubscriptionStatus
+}
+
+// NextRun time of the run following the given one.
+// Interval is a duration like "720h" or one of @daily, @weekly, @monthly, @yearly.
+func NextRun(runAt time.Time, interval string) (time.Time, error) {
+	switch interval {
+	case "@daily":
+		return runAt.AddDate(0, 0, 1), nil
+	case "@weekly":
+		return runAt.AddDate(0, 0, 7), nil
+	case "@monthly":
+		return runAt.AddDate(0, 1, 0), nil
+	case "@yearly":
+		return runAt.AddDate(1, 0, 0), nil
+	}
+
+	if strings.HasPrefix(interval, "@") {
+		return time.Time{}, fmt.Errorf("unknown %q, use @daily, @weekly, @monthly or @yearly", interval)
+	}
+
+	d, err := time.ParseDuration(interval)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%q must be a duration or @daily, @weekly, @monthly, @yearly", interval)
+	}
+	if d < minSubscriptionInterval {
+		return time.Time{}, fmt.Errorf("must be at least %s", minSubscriptionInterval)
+	}
+	return runAt.Add(d), nil
+}
+
+// advance moves the subscription to the next cycle after successful charge of the run at runAt.
+// The subscription completes when the next cycle is after its end.
+func (s *Subscription) advance(runAt int64) error {
+	cycleAt := s.CycleAt
+	if cycleAt == 0 {
+		// subscriptions created before dunning have no cycle
+		cycleAt = runAt
+	}
+
+	next, err := NextRun(time.Unix(cycleAt, 0).UTC(), s.Interval)
+	if err != nil {
+		return err
+	}
+
+	s.Runs++
+	s.CycleAt = next.Unix()
+	s.NextRunAt = next.Unix()
+	s.Status = ActiveSubscriptionStatus
+	s.Failures = 0
+	if s.EndAt != 0 && s.NextRunAt > s.EndAt {
+		s.Status = CompletedSubscriptionStatus
+	}
+	return nil
+}
+
+// SubscriptionCharge is the value of the CHARGE_SUBSCRIPTION command
+type SubscriptionCharge struct {
+	SubscriptionKey string `json:"subscription_key"`
+	// RunAt the run the leader charges, the command is ignored
+	// when the subscription has already moved past it
+	RunAt int64 `json:"run_at"`
+	// Dunning retry policy of the leader applied when the charge fails and
+	// the subscription has no policy of its own
+	Dunning DunningPolicy `json:"dunning"`
+	// Processor response of the charge, nil when the leader didn't send it
+	// to the processor because the order would refuse it anyway
+	Processor *ProcessorResponse `json:"processor,omitempty"`
+}
+
+// ChargeTransactionID id of the transaction of the subscription run.
+// It is derived from the run, so the same run can't be charged twice.
+func ChargeTransactionID(subscriptionID string, runAt int64) string {
+	return fmt.Sprintf("%s-%d", subscriptionID, runAt)
+}
+
+type SubscriptionRequest struct {
+	Token    string      `json:"token"`
+	OrderID  string      `json:"order_id"`
+	Amount   json.Number `json:"amount"`
+	Currency string      `json:"currency"`
+	Interval string      `json:"interval"`
+	// StartAt time of the first charge, now when empty
+	StartAt *time.Time `json:"start_at"`
+	// EndAt no charges after this time
+	EndAt *time.Time `json:"end_at"`
+
+	// AmountMinor amount in minor units of the currency, set by Bind
+	AmountMinor int64 `json:"-"`
+}
+
+func (p *SubscriptionRequest) Bind(r *http.Request) error {
+	errs := ValidationErrors{}
+	errs.Add("token", ValidateToken(p.Token))
+	errs.Add("currency", ValidateCurrency(p.Currency))
+	errs.Add("order_id", ValidateOrderID(p.OrderID))
+
+	amount, err := ValidateAmount(p.Amount, p.Currency)
+	errs.Add("amount", err)
+	p.AmountMinor = amount
+
+	_, err = NextRun(time.Now(), p.Interval)
+	errs.Add("interval", err)
+
+	if p.StartAt != nil && p.EndAt != nil && p.EndAt.Before(*p.StartAt) {
+		errs.Add("end_at", errors.New("must be after start_at"))
+	}
+	if p.EndAt != nil && p.EndAt.Before(time.Now()) {
+		errs.Add("end_at", errors.New("must be in the future"))
+	}
+
+	return errs.Err()
+}
+
+type SubscriptionResponse struct {
+	*Subscription
+	Addr string `json:"addr"`
+}
+
+func (rd *SubscriptionResponse) Render(w http.ResponseWriter, r *http.Request) error {
+	return nil
+}
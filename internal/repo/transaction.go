@@ -1,15 +1,121 @@
 package repo
 
+import (
+	"encoding/json"
+	"time"
+)
+
 type TransactionType string
 
 var (
 	FirstTransactionType     TransactionType = "first"
 	RecurringTransactionType TransactionType = "recurring"
+	RefundTransactionType    TransactionType = "refund"
+
+	AuthorizationTransactionType TransactionType = "authorization"
+	CaptureTransactionType       TransactionType = "capture"
+	VoidTransactionType          TransactionType = "void"
+	ExpirationTransactionType    TransactionType = "expiration"
+)
+
+type TransactionStatus string
+
+var (
+	SucceededTransactionStatus TransactionStatus = "succeeded"
+	FailedTransactionStatus    TransactionStatus = "failed"
+	// PendingTransactionStatus sent to the processor, the outcome is not applied yet
+	PendingTransactionStatus TransactionStatus = "pending"
 )
 
 type Transaction struct {
-	ID       string          `json:"id"`
-	Type     TransactionType `json:"type"`
-	Amount   float64         `json:"amount"`
-	Currency string          `json:"currency"`
+	ID       string            `json:"id"`
+	OrderID  string            `json:"order_id,omitempty"`
+	Type     TransactionType   `json:"type"`
+	Status   TransactionStatus `json:"status,omitempty"`
+	Amount   int64             `json:"amount_minor"` // minor units of the currency
+	Currency string            `json:"currency"`
+
+	// AuthorizationID authorization transaction captured, voided or expired by this one
+	AuthorizationID string `json:"authorization_id,omitempty"`
+	// ExpiresAt unix time when the authorization is released if not captured
+	ExpiresAt int64 `json:"expires_at,omitempty"`
+
+	// Processor response of the acquirer, set by the leader before the transaction is applied
+	Processor *ProcessorResponse `json:"processor,omitempty"`
+
+	// CreatedAt unix time the leader appended the command of the transaction to the raft log
+	CreatedAt int64 `json:"created_at,omitempty"`
+	// Token recurring token of the card, transactions without a card take it from the order
+	Token string `json:"token,omitempty"`
+}
+
+// CreatedWithin reports whether the transaction was created in [from, to),
+// zero bounds are open. Transactions written before timestamps are never within a bound.
+func (t *Transaction) CreatedWithin(from, to time.Time) bool {
+	if !from.IsZero() && (t.CreatedAt == 0 || t.CreatedAt < from.Unix()) {
+		return false
+	}
+	if !to.IsZero() && (t.CreatedAt == 0 || t.CreatedAt >= to.Unix()) {
+		return false
+	}
+	return true
+}
+
+// ProcessorResponse outcome of the payment processor call
+type ProcessorResponse struct {
+	Approved  bool   `json:"approved"`
+	Reference string `json:"reference,omitempty"`
+	Code      string `json:"code"`
+	Message   string `json:"message,omitempty"`
+}
+
+// MarshalJSON adds decimal "amount" next to the minor units for readability
+func (t Transaction) MarshalJSON() ([]byte, error) {
+	type transaction Transaction
+	return json.Marshal(struct {
+		transaction
+		DecimalAmount string `json:"amount"`
+	}{
+		transaction:   transaction(t),
+		DecimalAmount: FormatAmount(t.Amount, t.Currency),
+	})
+}
+
+// UnmarshalJSON reads transaction. Transactions written before minor units
+// have only float "amount", it is converted with the currency exponent.
+func (t *Transaction) UnmarshalJSON(data []byte) error {
+	type transaction Transaction
+	var value struct {
+		transaction
+		AmountMinor   *int64      `json:"amount_minor"`
+		DecimalAmount json.Number `json:"amount"`
+	}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+
+	*t = Transaction(value.transaction)
+	amount, err := minorAmount(value.AmountMinor, value.DecimalAmount, t.Currency)
+	if err != nil {
+		return err
+	}
+	t.Amount = amount
+
+	return nil
+}
+
+// minorAmount picks amount of a record which may be written before minor units
+func minorAmount(minor *int64, legacy json.Number, currency string) (int64, error) {
+	if minor != nil {
+		return *minor, nil
+	}
+	if legacy == "" {
+		return 0, nil
+	}
+
+	amount, err := legacy.Float64()
+	if err != nil {
+		return 0, err
+	}
+	return legacyAmount(amount, currency), nil
 }
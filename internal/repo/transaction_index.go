@@ -0,0 +1,97 @@
+package repo
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/KushnerykPavel/raft-test-project/internal/storage"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// transactionIndexKeys keys of the transaction in the merchant indexes.
+// Values of the index keys are transaction ids, the transaction is read by its id key.
+func transactionIndexKeys(merchantID string, trx *Transaction) []string {
+	keys := []string{
+		TransactionIndexKey(TransactionsByCreatedPrefix(merchantID), trx.CreatedAt, trx.ID),
+		TransactionIndexKey(TransactionsByCurrencyPrefix(merchantID, trx.Currency), trx.CreatedAt, trx.ID),
+	}
+	if trx.Token != "" {
+		keys = append(keys, TransactionIndexKey(TransactionsByTokenPrefix(merchantID, trx.Token), trx.CreatedAt, trx.ID))
+	}
+	return keys
+}
+
+// indexTransaction adds the transaction to the merchant indexes within the changes
+func (b *FSM) indexTransaction(c *changes, orderKey string, trx *Transaction) {
+	for _, key := range transactionIndexKeys(MerchantOfKey(orderKey), trx) {
+		c.set(key, trx.ID)
+	}
+}
+
+// MigrateTransactionIndexes adds transactions applied before the indexes existed.
+// Like the other migrations it runs on every node over its own data on start,
+// after the orders are split into per transaction keys.
+func (b *FSM) MigrateTransactionIndexes() error {
+	missing := map[string]string{}
+
+	err := storage.View(b.store, func(r storage.Reader) error {
+		return r.Scan(merchantPrefix, "", func(key string, val []byte) error {
+			orderKey, _, ok := strings.Cut(key, "/trx/")
+			if !ok || !IsOrderKey(orderKey) {
+				return nil
+			}
+
+			var trx Transaction
+			if err := json.Unmarshal(val, &trx); err != nil {
+				return fmt.Errorf("error decoding transaction %s: %w", key, err)
+			}
+
+			for _, indexKey := range transactionIndexKeys(MerchantOfKey(orderKey), &trx) {
+				_, err := r.Get(indexKey)
+				if errors.Is(err, storage.ErrNotFound) {
+					missing[indexKey] = trx.ID
+					continue
+				}
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return err
+	}
+
+	if len(missing) == 0 {
+		return nil
+	}
+
+	values := make(map[string][]byte, len(missing))
+	for key, id := range missing {
+		value, err := json.Marshal(id)
+		if err != nil {
+			return err
+		}
+		values[key] = value
+	}
+
+	if err := b.putChunked(values); err != nil {
+		return err
+	}
+
+	log.Printf("indexed %d transactions", len(missing))
+	return nil
+}
+
+type TransactionsResponse struct {
+	Transactions []Transaction `json:"transactions"`
+	NextCursor   string        `json:"next_cursor,omitempty"`
+	Addr         string        `json:"addr"`
+}
+
+func (rd *TransactionsResponse) Render(w http.ResponseWriter, r *http.Request) error {
+	return nil
+}
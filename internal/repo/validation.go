@@ -0,0 +1,235 @@
+package repo
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ValidationErrors field level errors of the request, keyed by json field name
+type ValidationErrors map[string]string
+
+func (v ValidationErrors) Error() string {
+	fields := make([]string, 0, len(v))
+	for field := range v {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	messages := make([]string, 0, len(fields))
+	for _, field := range fields {
+		messages = append(messages, fmt.Sprintf("%s: %s", field, v[field]))
+	}
+	return "validation failed: " + strings.Join(messages, "; ")
+}
+
+// Add records error of the field, the first error of a field wins
+func (v ValidationErrors) Add(field string, err error) {
+	if err == nil {
+		return
+	}
+	if _, ok := v[field]; !ok {
+		v[field] = err.Error()
+	}
+}
+
+// Err returns nil when there are no errors
+func (v ValidationErrors) Err() error {
+	if len(v) == 0 {
+		return nil
+	}
+	return v
+}
+
+type CardScheme string
+
+var (
+	VisaCardScheme       CardScheme = "visa"
+	MastercardCardScheme CardScheme = "mastercard"
+	AmexCardScheme       CardScheme = "amex"
+	DiscoverCardScheme   CardScheme = "discover"
+	UnknownCardScheme    CardScheme = "unknown"
+)
+
+var (
+	orderIDPattern = regexp.MustCompile(`^[A-Za-z0-9_.:-]{1,64}$`)
+	tokenPattern   = regexp.MustCompile(`^[0-9a-f]{40}$`)
+	nodeIDPattern  = regexp.MustCompile(`^[A-Za-z0-9_.-]{1,64}$`)
+	digitsPattern  = regexp.MustCompile(`^[0-9]+$`)
+)
+
+// CardSchemeOf detects scheme by the card number prefix
+func CardSchemeOf(number string) CardScheme {
+	switch {
+	case strings.HasPrefix(number, "34"), strings.HasPrefix(number, "37"):
+		return AmexCardScheme
+	case strings.HasPrefix(number, "4"):
+		return VisaCardScheme
+	case strings.HasPrefix(number, "6011"), strings.HasPrefix(number, "65"):
+		return DiscoverCardScheme
+	}
+
+	if len(number) >= 2 {
+		prefix, _ := strconv.Atoi(number[:2])
+		if prefix >= 51 && prefix <= 55 {
+			return MastercardCardScheme
+		}
+	}
+	if len(number) >= 4 {
+		prefix, _ := strconv.Atoi(number[:4])
+		if prefix >= 2221 && prefix <= 2720 {
+			return MastercardCardScheme
+		}
+	}
+
+	return UnknownCardScheme
+}
+
+// ValidateCardNumber checks length and Luhn checksum of the card number
+func ValidateCardNumber(number string) error {
+	if !digitsPattern.MatchString(number) {
+		return fmt.Errorf("must contain only digits")
+	}
+	if len(number) < 12 || len(number) > 19 {
+		return fmt.Errorf("must be 12 to 19 digits long")
+	}
+
+	var sum int
+	double := false
+	for i := len(number) - 1; i >= 0; i-- {
+		digit := int(number[i] - '0')
+		if double {
+			digit *= 2
+			if digit > 9 {
+				digit -= 9
+			}
+		}
+		sum += digit
+		double = !double
+	}
+
+	if sum%10 != 0 {
+		return fmt.Errorf("failed Luhn check")
+	}
+	return nil
+}
+
+// ValidateExpiry checks MM/YY or MM/YYYY expiry, the card is valid through the end of the month
+func ValidateExpiry(expiredAt string, now time.Time) error {
+	month, year, ok := strings.Cut(expiredAt, "/")
+	if !ok || len(month) != 2 || (len(year) != 2 && len(year) != 4) ||
+		!digitsPattern.MatchString(month) || !digitsPattern.MatchString(year) {
+		return fmt.Errorf("must be in MM/YY format")
+	}
+
+	m, _ := strconv.Atoi(month)
+	y, _ := strconv.Atoi(year)
+	if m < 1 || m > 12 {
+		return fmt.Errorf("month must be between 01 and 12")
+	}
+	if len(year) == 2 {
+		y += 2000
+	}
+
+	// first moment after the expiry month
+	expiry := time.Date(y, time.Month(m)+1, 1, 0, 0, 0, 0, time.UTC)
+	if !now.Before(expiry) {
+		return fmt.Errorf("card is expired")
+	}
+	return nil
+}
+
+// ValidateCvv checks CVV length required by the card scheme
+func ValidateCvv(cvv string, scheme CardScheme) error {
+	length := 3
+	if scheme == AmexCardScheme {
+		length = 4
+	}
+
+	if len(cvv) != length || !digitsPattern.MatchString(cvv) {
+		return fmt.Errorf("must be %d digits for %s card", length, scheme)
+	}
+	return nil
+}
+
+// ValidateCurrency checks the currency is ISO 4217 code
+func ValidateCurrency(currency string) error {
+	_, err := CurrencyExponent(currency)
+	return err
+}
+
+// ValidateAmount parses positive amount in minor units of the currency.
+// Currency errors are reported on the currency field, so they are skipped here.
+func ValidateAmount(amount json.Number, currency string) (int64, error) {
+	if ValidateCurrency(currency) != nil {
+		return 0, nil
+	}
+
+	minor, err := ParseAmount(amount, currency)
+	if err != nil {
+		return 0, err
+	}
+	if minor <= 0 {
+		return 0, fmt.Errorf("must be positive")
+	}
+	return minor, nil
+}
+
+// ValidateOrderID order id is used in storage keys, so it is limited to a safe alphabet
+func ValidateOrderID(orderID string) error {
+	if !orderIDPattern.MatchString(orderID) {
+		return fmt.Errorf("must be 1 to 64 characters of letters, digits, '_', '.', ':' or '-'")
+	}
+	return nil
+}
+
+// ValidateToken checks the recurring token format
+func ValidateToken(token string) error {
+	if !tokenPattern.MatchString(token) {
+		return fmt.Errorf("must be 40 hex characters")
+	}
+	return nil
+}
+
+// ValidateNodeID checks raft server id
+func ValidateNodeID(nodeID string) error {
+	if !nodeIDPattern.MatchString(nodeID) {
+		return fmt.Errorf("must be 1 to 64 characters of letters, digits, '_', '.' or '-'")
+	}
+	return nil
+}
+
+// ValidateAddress checks host:port address
+func ValidateAddress(address string) error {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return fmt.Errorf("must be host:port")
+	}
+	if host == "" {
+		return fmt.Errorf("host must not be empty")
+	}
+
+	p, err := strconv.Atoi(port)
+	if err != nil || p < 1 || p > 65535 {
+		return fmt.Errorf("port must be between 1 and 65535")
+	}
+	return nil
+}
+
+// ValidateHTTPAddress checks http(s) base url of a node
+func ValidateHTTPAddress(address string) error {
+	u, err := url.Parse(address)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("must be http or https url")
+	}
+	if u.Path != "" && u.Path != "/" {
+		return fmt.Errorf("must not have a path")
+	}
+	return nil
+}
@@ -0,0 +1,139 @@
+package repo
+
+import (
+	"testing"
+	"time"
+)
+
+func TestValidateCardNumber(t *testing.T) {
+	tests := []struct {
+		name    string
+		number  string
+		wantErr string
+	}{
+		{name: "visa", number: "4111111111111111"},
+		{name: "visa 13 digits", number: "4222222222222"},
+		{name: "mastercard", number: "5555555555554444"},
+		{name: "mastercard 2-series", number: "2223003122003222"},
+		{name: "amex", number: "378282246310005"},
+		{name: "discover", number: "6011111111111117"},
+		{name: "19 digits", number: "6011000000000000001"},
+		{name: "luhn failure", number: "4111111111111112", wantErr: "failed Luhn check"},
+		{name: "transposed digits", number: "4111111111111121", wantErr: "failed Luhn check"},
+		{name: "spaces", number: "4111 1111 1111 1111", wantErr: "must contain only digits"},
+		{name: "letters", number: "41111111111111aa", wantErr: "must contain only digits"},
+		{name: "empty", number: "", wantErr: "must contain only digits"},
+		{name: "too short", number: "42424242424", wantErr: "must be 12 to 19 digits long"},
+		{name: "too long", number: "41111111111111111111", wantErr: "must be 12 to 19 digits long"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateCardNumber(tt.number)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("ValidateCardNumber(%q) error = %v", tt.number, err)
+				}
+				return
+			}
+			if err == nil || err.Error() != tt.wantErr {
+				t.Fatalf("ValidateCardNumber(%q) error = %v, want %q", tt.number, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateExpiry(t *testing.T) {
+	now := time.Date(2026, time.March, 15, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name      string
+		expiredAt string
+		now       time.Time
+		wantErr   string
+	}{
+		{name: "future", expiredAt: "12/30", now: now},
+		{name: "four digit year", expiredAt: "12/2030", now: now},
+		{name: "current month", expiredAt: "03/26", now: now},
+		{name: "last moment of the month", expiredAt: "03/26", now: time.Date(2026, time.March, 31, 23, 59, 59, 0, time.UTC)},
+		{name: "december rolls over the year", expiredAt: "12/26", now: time.Date(2026, time.December, 31, 23, 0, 0, 0, time.UTC)},
+		{name: "month after expiry", expiredAt: "03/26", now: time.Date(2026, time.April, 1, 0, 0, 0, 0, time.UTC), wantErr: "card is expired"},
+		{name: "previous month", expiredAt: "02/26", now: now, wantErr: "card is expired"},
+		{name: "previous year", expiredAt: "12/25", now: now, wantErr: "card is expired"},
+		{name: "month zero", expiredAt: "00/30", now: now, wantErr: "month must be between 01 and 12"},
+		{name: "month thirteen", expiredAt: "13/30", now: now, wantErr: "month must be between 01 and 12"},
+		{name: "no separator", expiredAt: "1230", now: now, wantErr: "must be in MM/YY format"},
+		{name: "single digit month", expiredAt: "1/30", now: now, wantErr: "must be in MM/YY format"},
+		{name: "three digit year", expiredAt: "12/030", now: now, wantErr: "must be in MM/YY format"},
+		{name: "letters", expiredAt: "ab/cd", now: now, wantErr: "must be in MM/YY format"},
+		{name: "empty", expiredAt: "", now: now, wantErr: "must be in MM/YY format"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateExpiry(tt.expiredAt, tt.now)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("ValidateExpiry(%q) error = %v", tt.expiredAt, err)
+				}
+				return
+			}
+			if err == nil || err.Error() != tt.wantErr {
+				t.Fatalf("ValidateExpiry(%q) error = %v, want %q", tt.expiredAt, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateCvv(t *testing.T) {
+	tests := []struct {
+		name    string
+		cvv     string
+		scheme  CardScheme
+		wantErr bool
+	}{
+		{name: "visa", cvv: "123", scheme: VisaCardScheme},
+		{name: "mastercard", cvv: "000", scheme: MastercardCardScheme},
+		{name: "unknown scheme", cvv: "123", scheme: UnknownCardScheme},
+		{name: "amex", cvv: "1234", scheme: AmexCardScheme},
+		{name: "amex three digits", cvv: "123", scheme: AmexCardScheme, wantErr: true},
+		{name: "visa four digits", cvv: "1234", scheme: VisaCardScheme, wantErr: true},
+		{name: "letters", cvv: "12a", scheme: VisaCardScheme, wantErr: true},
+		{name: "empty", cvv: "", scheme: VisaCardScheme, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := ValidateCvv(tt.cvv, tt.scheme); (err != nil) != tt.wantErr {
+				t.Fatalf("ValidateCvv(%q, %s) error = %v, wantErr %v", tt.cvv, tt.scheme, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCardSchemeOf(t *testing.T) {
+	tests := []struct {
+		number string
+		want   CardScheme
+	}{
+		{number: "4111111111111111", want: VisaCardScheme},
+		{number: "5105105105105100", want: MastercardCardScheme},
+		{number: "2221000000000009", want: MastercardCardScheme},
+		{number: "2720990000000007", want: MastercardCardScheme},
+		{number: "2721000000000004", want: UnknownCardScheme},
+		{number: "340000000000009", want: AmexCardScheme},
+		{number: "378282246310005", want: AmexCardScheme},
+		{number: "6011111111111117", want: DiscoverCardScheme},
+		{number: "6500000000000002", want: DiscoverCardScheme},
+		{number: "3530111333300000", want: UnknownCardScheme},
+		{number: "", want: UnknownCardScheme},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.number, func(t *testing.T) {
+			if got := CardSchemeOf(tt.number); got != tt.want {
+				t.Errorf("CardSchemeOf(%q) = %s, want %s", tt.number, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,153 @@
+package repo
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+type EventType string
+
+var (
+	PaymentCreatedEventType    EventType = "payment.created"
+	RecurringChargedEventType  EventType = "recurring.charged"
+	PaymentRefundedEventType   EventType = "payment.refunded"
+	PaymentAuthorizedEventType EventType = "payment.authorized"
+	PaymentCapturedEventType   EventType = "payment.captured"
+	PaymentVoidedEventType     EventType = "payment.voided"
+	PaymentExpiredEventType    EventType = "payment.expired"
+)
+
+// eventTypes event of the transaction type
+var eventTypes = map[TransactionType]EventType{
+	FirstTransactionType:         PaymentCreatedEventType,
+	RecurringTransactionType:     RecurringChargedEventType,
+	RefundTransactionType:        PaymentRefundedEventType,
+	AuthorizationTransactionType: PaymentAuthorizedEventType,
+	CaptureTransactionType:       PaymentCapturedEventType,
+	VoidTransactionType:          PaymentVoidedEventType,
+	ExpirationTransactionType:    PaymentExpiredEventType,
+}
+
+// Event domain event written into the outbox by the same apply as the change it describes.
+// The id is the transaction id, so a receiver can drop redelivered events.
+type Event struct {
+	ID          string      `json:"id"`
+	Type        EventType   `json:"type"`
+	Sequence    uint64      `json:"sequence"`
+	MerchantID  string      `json:"merchant_id"`
+	OrderID     string      `json:"order_id"`
+	OrderState  OrderState  `json:"order_state"`
+	Transaction Transaction `json:"transaction"`
+}
+
+// OutboxEntry event waiting for delivery
+type OutboxEntry struct {
+	Event         Event `json:"event"`
+	Attempts      int   `json:"attempts"`
+	NextAttemptAt int64 `json:"next_attempt_at"`
+}
+
+// WebhookEndpoint where events of the merchant are delivered.
+// Every delivery is signed with the secret.
+type WebhookEndpoint struct {
+	URL    string `json:"url"`
+	Secret string `json:"secret"`
+}
+
+// NewWebhookSecret generates random signing secret
+func NewWebhookSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return "whsec_" + hex.EncodeToString(b), nil
+}
+
+// SignWebhook hex encoded HMAC-SHA256 of "<timestamp>.<body>" with the secret
+func SignWebhook(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	_, _ = fmt.Fprintf(mac, "%d.", timestamp)
+	_, _ = mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+type DeliveryStatus string
+
+var (
+	DeliveredDeliveryStatus DeliveryStatus = "delivered"
+	RetryingDeliveryStatus  DeliveryStatus = "retrying"
+	FailedDeliveryStatus    DeliveryStatus = "failed"
+)
+
+// WebhookAttempt is the value of the WEBHOOK_ATTEMPT command, the leader
+// reports the outcome of a delivery. Retrying keeps the event in the outbox
+// until NextAttemptAt, delivered and failed remove it.
+type WebhookAttempt struct {
+	OutboxKey     string         `json:"outbox_key"`
+	Attempt       int            `json:"attempt"`
+	URL           string         `json:"url"`
+	Status        DeliveryStatus `json:"status"`
+	StatusCode    int            `json:"status_code,omitempty"`
+	Error         string         `json:"error,omitempty"`
+	AttemptedAt   int64          `json:"attempted_at"`
+	NextAttemptAt int64          `json:"next_attempt_at,omitempty"`
+}
+
+// WebhookDelivery record of the delivery log
+type WebhookDelivery struct {
+	EventID       string         `json:"event_id"`
+	EventType     EventType      `json:"event_type"`
+	Sequence      uint64         `json:"sequence"`
+	Attempt       int            `json:"attempt"`
+	URL           string         `json:"url"`
+	Status        DeliveryStatus `json:"status"`
+	StatusCode    int            `json:"status_code,omitempty"`
+	Error         string         `json:"error,omitempty"`
+	AttemptedAt   int64          `json:"attempted_at"`
+	NextAttemptAt int64          `json:"next_attempt_at,omitempty"`
+}
+
+type WebhookRequest struct {
+	URL string `json:"url"`
+}
+
+func (wr *WebhookRequest) Bind(r *http.Request) error {
+	errs := ValidationErrors{}
+	u, err := url.Parse(wr.URL)
+	switch {
+	case err != nil:
+		errs.Add("url", errors.New("must be a valid url"))
+	case u.Scheme != "http" && u.Scheme != "https":
+		errs.Add("url", errors.New("must be http or https url"))
+	case u.Host == "":
+		errs.Add("url", errors.New("host must not be empty"))
+	}
+
+	return errs.Err()
+}
+
+// WebhookResponse secret is returned only when the endpoint is set
+type WebhookResponse struct {
+	URL    string `json:"url"`
+	Secret string `json:"secret,omitempty"`
+	Addr   string `json:"addr"`
+}
+
+func (rd *WebhookResponse) Render(w http.ResponseWriter, r *http.Request) error {
+	return nil
+}
+
+type DeliveriesResponse struct {
+	Deliveries []WebhookDelivery `json:"deliveries"`
+	Addr       string            `json:"addr"`
+}
+
+func (rd *DeliveriesResponse) Render(w http.ResponseWriter, r *http.Request) error {
+	return nil
+}
@@ -0,0 +1,30 @@
+package server
+
+import (
+	"crypto/subtle"
+	"errors"
+	"github.com/KushnerykPavel/raft-test-project/internal/server/store_router"
+	"github.com/go-chi/render"
+	"net/http"
+)
+
+const adminTokenHeader = "X-Admin-Token"
+
+// requireAdmin lets through only requests with the admin token in X-Admin-Token header
+func requireAdmin(token string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			given := r.Header.Get(adminTokenHeader)
+			if given == "" {
+				render.Render(w, r, store_router.ErrUnauthorized(errors.New("admin token is required")))
+				return
+			}
+			if token == "" || subtle.ConstantTimeCompare([]byte(given), []byte(token)) != 1 {
+				render.Render(w, r, store_router.ErrUnauthorized(errors.New("invalid admin token")))
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
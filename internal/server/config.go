@@ -0,0 +1,25 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// Settings reports the configuration the node runs with
+type Settings interface {
+	Effective() map[string]interface{}
+}
+
+type configHandler struct {
+	settings Settings
+}
+
+// Config returns the effective configuration of the node,
+// reloaded options included
+func (h configHandler) Config(w http.ResponseWriter, r *http.Request) {
+	response, _ := json.Marshal(h.settings.Effective())
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(response)
+}
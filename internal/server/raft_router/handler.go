@@ -1,13 +1,45 @@
 package raft_router
 
-import "github.com/hashicorp/raft"
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/KushnerykPavel/raft-test-project/internal/replication"
+	"github.com/KushnerykPavel/raft-test-project/internal/repo"
+	"github.com/hashicorp/raft"
+	"time"
+)
+
+// applyTimeout how long a command waits to be enqueued by raft
+const applyTimeout = time.Second
 
 type Handler struct {
-	raft *raft.Raft
+	raft        *raft.Raft
+	replication *replication.Transport
 }
 
-func New(raft *raft.Raft) *Handler {
+func New(raft *raft.Raft, replication *replication.Transport) *Handler {
 	return &Handler{
-		raft: raft,
+		raft:        raft,
+		replication: replication,
+	}
+}
+
+// apply replicates the command of the cluster membership, like the node records
+func (h *Handler) apply(operation, key string, value any) error {
+	payload, err := json.Marshal(repo.CommandPayload{Operation: operation, Key: key, Value: value})
+	if err != nil {
+		return fmt.Errorf("error preparing saving data payload: %s", err.Error())
+	}
+
+	applyFuture := h.raft.Apply(payload, applyTimeout)
+	if err := applyFuture.Error(); err != nil {
+		return fmt.Errorf("error persisting data in raft cluster: %s", err.Error())
+	}
+
+	response, ok := applyFuture.Response().(*repo.ApplyResponse)
+	if !ok {
+		return errors.New("error response is not match apply response")
 	}
+	return response.Error
 }
@@ -3,6 +3,7 @@ package raft_router
 import (
 	"errors"
 	"fmt"
+	"github.com/KushnerykPavel/raft-test-project/internal/repo"
 	"github.com/go-chi/render"
 	"github.com/hashicorp/raft"
 	"net/http"
@@ -12,9 +13,10 @@ type ErrResponse struct {
 	Err            error `json:"-"` // low-level runtime error
 	HTTPStatusCode int   `json:"-"` // http response status code
 
-	StatusText string `json:"status"`          // user-level status message
-	AppCode    int64  `json:"code,omitempty"`  // application-specific error code
-	ErrorText  string `json:"error,omitempty"` // application-level error message, for debugging
+	StatusText string            `json:"status"`           // user-level status message
+	AppCode    int64             `json:"code,omitempty"`   // application-specific error code
+	ErrorText  string            `json:"error,omitempty"`  // application-level error message, for debugging
+	Fields     map[string]string `json:"fields,omitempty"` // field-level validation errors
 }
 
 func (e *ErrResponse) Render(w http.ResponseWriter, r *http.Request) error {
@@ -23,21 +25,34 @@ func (e *ErrResponse) Render(w http.ResponseWriter, r *http.Request) error {
 }
 
 func ErrInvalidRequest(err error) render.Renderer {
+	var fields repo.ValidationErrors
+	errors.As(err, &fields)
+
 	return &ErrResponse{
 		Err:            err,
 		HTTPStatusCode: 400,
 		StatusText:     "Invalid request.",
 		ErrorText:      err.Error(),
+		Fields:         fields,
 	}
 }
 
 type requestJoin struct {
 	NodeID      string `json:"node_id"`
 	RaftAddress string `json:"raft_address"`
+	// HTTPAddress optional base url of the node, registered for the discovery of the cluster
+	HTTPAddress string `json:"http_address"`
 }
 
 func (j *requestJoin) Bind(r *http.Request) error {
-	return nil
+	errs := repo.ValidationErrors{}
+	errs.Add("node_id", repo.ValidateNodeID(j.NodeID))
+	errs.Add("raft_address", repo.ValidateAddress(j.RaftAddress))
+	if j.HTTPAddress != "" {
+		errs.Add("http_address", repo.ValidateHTTPAddress(j.HTTPAddress))
+	}
+
+	return errs.Err()
 }
 
 type responseJoin struct {
@@ -79,6 +94,14 @@ func (h *Handler) JoinRaft(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if data.HTTPAddress != "" {
+		node := repo.Node{ID: nodeID, RaftAddress: raftAddr, HTTPAddress: data.HTTPAddress}
+		if err := h.apply("SET", repo.NodeKey(nodeID), node); err != nil {
+			render.Render(w, r, ErrInvalidRequest(fmt.Errorf("error registering node: %s", err.Error())))
+			return
+		}
+	}
+
 	response := &responseJoin{
 		Message: fmt.Sprintf("node %s at %s joined successfully", nodeID, raftAddr),
 		Data:    h.raft.Stats(),
@@ -3,6 +3,7 @@ package raft_router
 import (
 	"errors"
 	"fmt"
+	"github.com/KushnerykPavel/raft-test-project/internal/repo"
 	"github.com/go-chi/render"
 	"github.com/hashicorp/raft"
 	"net/http"
@@ -13,7 +14,10 @@ type requestRemove struct {
 }
 
 func (j *requestRemove) Bind(r *http.Request) error {
-	return nil
+	errs := repo.ValidationErrors{}
+	errs.Add("node_id", repo.ValidateNodeID(j.NodeID))
+
+	return errs.Err()
 }
 
 type responseRemove struct {
@@ -52,6 +56,11 @@ func (h *Handler) RemoveRaft(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if err := h.apply("DELETE", repo.NodeKey(nodeID), nil); err != nil {
+		render.Render(w, r, ErrInvalidRequest(fmt.Errorf("error deleting node %s: %s", nodeID, err.Error())))
+		return
+	}
+
 	response := &responseRemove{
 		Message: fmt.Sprintf("node %s removed successfully", nodeID),
 		Data:    h.raft.Stats(),
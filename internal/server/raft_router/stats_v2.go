@@ -0,0 +1,124 @@
+package raft_router
+
+import (
+	"fmt"
+	"github.com/go-chi/render"
+	"github.com/hashicorp/raft"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+type statsLeader struct {
+	ID      string `json:"id"`
+	Address string `json:"address"`
+}
+
+type statsServer struct {
+	ID       string `json:"id"`
+	Address  string `json:"address"`
+	Suffrage string `json:"suffrage"`
+}
+
+// statsFollower replication of the follower, reported by the leader
+type statsFollower struct {
+	ID          string     `json:"id"`
+	Address     string     `json:"address"`
+	MatchIndex  uint64     `json:"match_index"`
+	Lag         uint64     `json:"lag"` // entries of the leader log not replicated yet
+	LastContact *time.Time `json:"last_contact,omitempty"`
+}
+
+type responseStatsV2 struct {
+	State             string          `json:"state"`
+	Leader            *statsLeader    `json:"leader,omitempty"`
+	Term              uint64          `json:"term"`
+	LastLogIndex      uint64          `json:"last_log_index"`
+	LastLogTerm       uint64          `json:"last_log_term"`
+	CommitIndex       uint64          `json:"commit_index"`
+	AppliedIndex      uint64          `json:"applied_index"`
+	FSMPending        uint64          `json:"fsm_pending"`
+	LastSnapshotIndex uint64          `json:"last_snapshot_index"`
+	LastSnapshotTerm  uint64          `json:"last_snapshot_term"`
+	LastContact       *time.Time      `json:"last_contact,omitempty"` // followers only
+	NumPeers          int             `json:"num_peers"`
+	Configuration     []statsServer   `json:"configuration"`         // latest servers of the cluster
+	Replication       []statsFollower `json:"replication,omitempty"` // leader only
+}
+
+func (s *responseStatsV2) Render(w http.ResponseWriter, r *http.Request) error {
+	return nil
+}
+
+// StatsRaftV2 returns the raft stats with typed fields, the latest configuration
+// and, on the leader, the replication lag of every follower
+func (h *Handler) StatsRaftV2(w http.ResponseWriter, r *http.Request) {
+	stats := h.raft.Stats()
+	uintStat := func(key string) uint64 {
+		value, _ := strconv.ParseUint(stats[key], 10, 64)
+		return value
+	}
+
+	response := &responseStatsV2{
+		State:             h.raft.State().String(),
+		Term:              uintStat("term"),
+		LastLogIndex:      uintStat("last_log_index"),
+		LastLogTerm:       uintStat("last_log_term"),
+		CommitIndex:       uintStat("commit_index"),
+		AppliedIndex:      uintStat("applied_index"),
+		FSMPending:        uintStat("fsm_pending"),
+		LastSnapshotIndex: uintStat("last_snapshot_index"),
+		LastSnapshotTerm:  uintStat("last_snapshot_term"),
+	}
+	response.NumPeers, _ = strconv.Atoi(stats["num_peers"])
+
+	if addr, id := h.raft.LeaderWithID(); id != "" {
+		response.Leader = &statsLeader{ID: string(id), Address: string(addr)}
+	}
+	if h.raft.State() != raft.Leader {
+		if lastContact := h.raft.LastContact(); !lastContact.IsZero() {
+			response.LastContact = &lastContact
+		}
+	}
+
+	configFuture := h.raft.GetConfiguration()
+	if err := configFuture.Error(); err != nil {
+		render.Render(w, r, ErrInvalidRequest(fmt.Errorf("failed to get raft configuration: %s", err.Error())))
+		return
+	}
+
+	response.Configuration = make([]statsServer, 0, len(configFuture.Configuration().Servers))
+	for _, server := range configFuture.Configuration().Servers {
+		response.Configuration = append(response.Configuration, statsServer{
+			ID:       string(server.ID),
+			Address:  string(server.Address),
+			Suffrage: server.Suffrage.String(),
+		})
+
+		if response.State == raft.Leader.String() && (response.Leader == nil || string(server.ID) != response.Leader.ID) {
+			response.Replication = append(response.Replication, h.followerStats(server, response.Term, response.LastLogIndex))
+		}
+	}
+
+	render.Status(r, http.StatusOK)
+	render.Render(w, r, response)
+}
+
+// followerStats replication of the follower in the term of the leader. A follower
+// nothing was replicated to in the term lags by the whole log.
+func (h *Handler) followerStats(server raft.Server, term, lastLogIndex uint64) statsFollower {
+	follower := statsFollower{
+		ID:      string(server.ID),
+		Address: string(server.Address),
+		Lag:     lastLogIndex,
+	}
+
+	progress, ok := h.replication.Progress(server.ID)
+	if !ok || progress.Term != term {
+		return follower
+	}
+	follower.MatchIndex = progress.MatchIndex
+	follower.Lag = lastLogIndex - min(progress.MatchIndex, lastLogIndex)
+	follower.LastContact = &progress.LastContact
+	return follower
+}
@@ -1,9 +1,13 @@
 package server
 
 import (
+	"context"
+	"github.com/KushnerykPavel/raft-test-project/internal/processor"
+	"github.com/KushnerykPavel/raft-test-project/internal/replication"
+	"github.com/KushnerykPavel/raft-test-project/internal/repo"
 	"github.com/KushnerykPavel/raft-test-project/internal/server/raft_router"
 	"github.com/KushnerykPavel/raft-test-project/internal/server/store_router"
-	"github.com/dgraph-io/badger/v2"
+	"github.com/KushnerykPavel/raft-test-project/internal/storage"
 	"github.com/go-chi/chi/v5"
 	"github.com/hashicorp/raft"
 	"net/http"
@@ -11,40 +15,93 @@ import (
 	"time"
 )
 
+// Config HTTP server settings
+type Config struct {
+	ReadTimeout  time.Duration
+	WriteTimeout time.Duration
+	Settings     Settings
+	// AdminToken credential of the /admin endpoints
+	AdminToken string
+}
+
 type Srv struct {
 	listenAddress string
+	conf          Config
 	raft          *raft.Raft
 	router        *chi.Mux
+	store         *store_router.Handler
 }
 
-// Start start the server
+// Start start the server and background jobs of the leader
 func (s Srv) Start() error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go s.store.SweepAuthorizations(ctx)
+	go s.store.RunScheduler(ctx)
+	go s.store.RunWebhooks(ctx)
+	go s.store.RunBatcher(ctx)
+	go s.store.RunStorageGC(ctx)
+	go s.store.RunNodeRegistration(ctx)
+	go s.store.RunFlatKeysMigration(ctx)
+
 	server := &http.Server{
 		Addr:         s.listenAddress,
-		ReadTimeout:  3 * time.Second,
-		WriteTimeout: 3 * time.Second,
+		ReadTimeout:  s.conf.ReadTimeout,
+		WriteTimeout: s.conf.WriteTimeout,
 		Handler:      s.router,
 	}
 	return server.ListenAndServe()
 }
 
-func New(listenAddr string, badgerDB *badger.DB, r *raft.Raft) *Srv {
+func New(listenAddr string, fsm *repo.FSM, store storage.Store, r *raft.Raft, replication *replication.Transport, proc processor.Processor, conf Config, storeConf store_router.Config) *Srv {
 	router := chi.NewRouter()
 	router.Mount("/debug/pprof", http.DefaultServeMux)
 
-	raftRouter := raft_router.New(r)
+	raftRouter := raft_router.New(r, replication)
 	router.Get("/raft/stats", raftRouter.StatsRaft)
+	router.Get("/v2/raft/stats", raftRouter.StatsRaftV2)
 	router.Post("/raft/join", raftRouter.JoinRaft)
 	router.Post("/raft/remove", raftRouter.RemoveRaft)
 
-	storeRouter := store_router.New(r, badgerDB, listenAddr)
-	router.Post("/api/pay", storeRouter.Pay)
-	router.Post("/api/recurring", storeRouter.Recurring)
-	router.Get("/api/status/{order_id}", storeRouter.Status)
+	storeRouter := store_router.New(r, fsm, store, proc, listenAddr, storeConf)
+	router.Get("/v2/cluster/nodes", storeRouter.ClusterNodes)
+	router.Get("/healthz", storeRouter.Healthz)
+	router.Get("/readyz", storeRouter.Readyz)
+	router.Route("/admin", func(r chi.Router) {
+		r.Use(requireAdmin(conf.AdminToken))
+		r.Post("/merchants", storeRouter.CreateMerchant)
+		r.Get("/config", configHandler{settings: conf.Settings}.Config)
+		r.Get("/storage", storeRouter.StorageStats)
+		r.Post("/storage/gc", storeRouter.StorageGC)
+		r.Get("/intents", storeRouter.Intents)
+	})
+	router.Route("/api", func(r chi.Router) {
+		r.Use(storeRouter.Authenticate)
+		r.With(storeRouter.Idempotency).Post("/pay", storeRouter.Pay)
+		r.With(storeRouter.Idempotency).Post("/recurring", storeRouter.Recurring)
+		r.With(storeRouter.Idempotency).Post("/refund", storeRouter.Refund)
+		r.With(storeRouter.Idempotency).Post("/authorize", storeRouter.Authorize)
+		r.With(storeRouter.Idempotency).Post("/capture", storeRouter.Capture)
+		r.With(storeRouter.Idempotency).Post("/void", storeRouter.Void)
+		r.With(storeRouter.Idempotency).Post("/subscriptions", storeRouter.CreateSubscription)
+		r.Get("/subscriptions/{subscription_id}", storeRouter.GetSubscription)
+		r.Get("/subscriptions/{subscription_id}/dunning", storeRouter.SubscriptionDunning)
+		r.Put("/webhooks", storeRouter.SetWebhook)
+		r.Get("/webhooks", storeRouter.GetWebhook)
+		r.Delete("/webhooks", storeRouter.DeleteWebhook)
+		r.Get("/webhooks/deliveries", storeRouter.WebhookDeliveries)
+		r.Get("/events", storeRouter.Events)
+		r.Get("/transactions", storeRouter.ListTransactions)
+		r.Delete("/subscriptions/{subscription_id}", storeRouter.CancelSubscription)
+		r.Get("/status/{order_id}", storeRouter.Status)
+	})
 
 	return &Srv{
 		listenAddress: listenAddr,
+		conf:          conf,
 		raft:          r,
 		router:        router,
+		store:         storeRouter,
 	}
 }
@@ -0,0 +1,78 @@
+package store_router
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/KushnerykPavel/raft-test-project/internal/repo"
+	"github.com/KushnerykPavel/raft-test-project/internal/storage"
+	"github.com/go-chi/render"
+	"net/http"
+	"strings"
+)
+
+type contextKey string
+
+const merchantContextKey contextKey = "merchant"
+
+const apiKeyHeader = "X-API-Key"
+
+func ErrUnauthorized(err error) render.Renderer {
+	return &ErrResponse{
+		Err:            err,
+		HTTPStatusCode: http.StatusUnauthorized,
+		StatusText:     "Unauthorized.",
+		ErrorText:      err.Error(),
+	}
+}
+
+// apiKeyFromRequest reads api key from X-API-Key or Authorization: Bearer headers
+func apiKeyFromRequest(r *http.Request) string {
+	if key := r.Header.Get(apiKeyHeader); key != "" {
+		return key
+	}
+
+	auth := r.Header.Get("Authorization")
+	if strings.HasPrefix(auth, "Bearer ") {
+		return strings.TrimSpace(strings.TrimPrefix(auth, "Bearer "))
+	}
+
+	return ""
+}
+
+// Authenticate resolves the merchant by api key and stores it in request context.
+// Every handler behind it works only with the keys of that merchant.
+func (h *Handler) Authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		apiKey := apiKeyFromRequest(r)
+		if apiKey == "" {
+			render.Render(w, r, ErrUnauthorized(errors.New("api key is required")))
+			return
+		}
+
+		var merchantID string
+		if err := h.get(repo.APIKeyKey(apiKey), &merchantID); err != nil {
+			if errors.Is(err, storage.ErrNotFound) {
+				render.Render(w, r, ErrUnauthorized(errors.New("unknown api key")))
+				return
+			}
+			render.Render(w, r, ErrInvalidRequest(fmt.Errorf("error getting api key from storage: %s", err.Error())))
+			return
+		}
+
+		var merchant repo.Merchant
+		if err := h.get(repo.MerchantKey(merchantID), &merchant); err != nil {
+			render.Render(w, r, ErrUnauthorized(fmt.Errorf("merchant %s is not available: %s", merchantID, err.Error())))
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), merchantContextKey, &merchant)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// merchantFromContext returns merchant resolved by Authenticate
+func merchantFromContext(ctx context.Context) *repo.Merchant {
+	merchant, _ := ctx.Value(merchantContextKey).(*repo.Merchant)
+	return merchant
+}
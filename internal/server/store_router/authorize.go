@@ -0,0 +1,123 @@
+package store_router
+
+import (
+	"errors"
+	"github.com/KushnerykPavel/raft-test-project/internal/repo"
+	"github.com/go-chi/render"
+	"github.com/google/uuid"
+	"github.com/hashicorp/raft"
+	"net/http"
+	"time"
+)
+
+// Authorize places a hold of the amount on the order.
+// The hold has to be captured or voided before it expires.
+func (h *Handler) Authorize(w http.ResponseWriter, r *http.Request) {
+	data := &repo.PayRequest{}
+	if err := render.Bind(r, data); err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+
+	if h.raft.State() != raft.Leader {
+		render.Render(w, r, ErrInvalidRequest(errors.New("node is not leader")))
+		return
+	}
+
+	merchant := merchantFromContext(r.Context())
+
+	transaction := &repo.Transaction{
+		ID:        uuid.New().String(),
+		OrderID:   data.OrderID,
+		Type:      repo.AuthorizationTransactionType,
+		Status:    repo.SucceededTransactionStatus,
+		Amount:    data.AmountMinor,
+		Currency:  data.Currency,
+		ExpiresAt: time.Now().Add(h.config.AuthorizationTTL).Unix(),
+		Token:     data.GetRecurringToken(),
+	}
+
+	applied, err := h.processTransaction(r.Context(), repo.OrderKey(merchant.ID, data.OrderID), transaction, cardOf(data))
+	if err != nil {
+		render.Render(w, r, ErrApply(err))
+		return
+	}
+
+	response := &repo.AuthorizeResponse{
+		TransactionID: applied.ID,
+		Status:        applied.Status,
+		Processor:     applied.Processor,
+		Addr:          h.addr,
+	}
+
+	if applied.Status == repo.SucceededTransactionStatus {
+		if h.storeCard(merchant.ID, applied, data) {
+			response.Token = applied.Token
+		}
+		response.ExpiresAt = applied.ExpiresAt
+	}
+
+	render.Status(r, transactionStatusCode(applied))
+	render.Render(w, r, response)
+}
+
+// Capture captures full or partial amount of the open authorization
+func (h *Handler) Capture(w http.ResponseWriter, r *http.Request) {
+	data := &repo.CaptureRequest{}
+	if err := render.Bind(r, data); err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+
+	h.releaseHold(w, r, data.OrderID, repo.CaptureTransactionType, data.AmountMinor, data.Currency)
+}
+
+// Void releases the open authorization without capturing it
+func (h *Handler) Void(w http.ResponseWriter, r *http.Request) {
+	data := &repo.VoidRequest{}
+	if err := render.Bind(r, data); err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+
+	h.releaseHold(w, r, data.OrderID, repo.VoidTransactionType, 0, "")
+}
+
+// releaseHold applies capture or void of the order authorization.
+// Empty amount and currency are taken from the authorization by the FSM.
+func (h *Handler) releaseHold(w http.ResponseWriter, r *http.Request, orderID string, trxType repo.TransactionType, amount int64, currency string) {
+	if h.raft.State() != raft.Leader {
+		render.Render(w, r, ErrInvalidRequest(errors.New("node is not leader")))
+		return
+	}
+
+	merchant := merchantFromContext(r.Context())
+
+	transaction := &repo.Transaction{
+		ID:       uuid.New().String(),
+		OrderID:  orderID,
+		Type:     trxType,
+		Status:   repo.SucceededTransactionStatus,
+		Amount:   amount,
+		Currency: currency,
+	}
+
+	applied, err := h.processTransaction(r.Context(), repo.OrderKey(merchant.ID, orderID), transaction, nil)
+	if err != nil {
+		render.Render(w, r, ErrApply(err))
+		return
+	}
+
+	response := &repo.HoldResponse{
+		TransactionID: applied.ID,
+		Status:        applied.Status,
+		Processor:     applied.Processor,
+		Amount:        repo.FormatAmount(applied.Amount, applied.Currency),
+		AmountMinor:   applied.Amount,
+		Currency:      applied.Currency,
+		Addr:          h.addr,
+	}
+
+	render.Status(r, transactionStatusCode(applied))
+	render.Render(w, r, response)
+}
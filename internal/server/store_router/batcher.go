@@ -0,0 +1,145 @@
+package store_router
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/KushnerykPavel/raft-test-project/internal/repo"
+	"github.com/hashicorp/raft"
+	"time"
+)
+
+// applyRequest command waiting for its batch
+type applyRequest struct {
+	payload repo.CommandPayload
+	result  chan applyResult
+}
+
+// applyResult outcome of one command of the batch
+type applyResult struct {
+	data any
+	err  error
+}
+
+// applyBatched hands the command to the batcher and waits for its own result.
+// The command fails when the batcher doesn't take it within the apply timeout.
+func (h *Handler) applyBatched(payload repo.CommandPayload) (any, error) {
+	req := &applyRequest{
+		payload: payload,
+		result:  make(chan applyResult, 1),
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), applyTimeout)
+	defer cancel()
+
+	select {
+	case h.batches <- req:
+	case <-ctx.Done():
+		return nil, fmt.Errorf("error persisting data in raft cluster: %w", raft.ErrEnqueueTimeout)
+	}
+
+	result := <-req.result
+	return result.data, result.err
+}
+
+// RunBatcher coalesces commands applied concurrently into one raft log entry,
+// so the log is written and replicated once for all of them. Commands coming while
+// a batch is applied queue up for the next one. Each command of the batch succeeds
+// or fails on its own.
+func (h *Handler) RunBatcher(ctx context.Context) {
+	for {
+		var first *applyRequest
+		select {
+		case <-ctx.Done():
+			return
+		case first = <-h.batches:
+		}
+
+		h.applyRequests(h.collectBatch(first))
+	}
+}
+
+// collectBatch takes the commands queued behind the first one, waiting for
+// more up to the linger while the batch is not full
+func (h *Handler) collectBatch(first *applyRequest) []*applyRequest {
+	batch := []*applyRequest{first}
+
+	var linger <-chan time.Time
+	if h.config.ApplyBatchLinger > 0 {
+		timer := time.NewTimer(h.config.ApplyBatchLinger)
+		defer timer.Stop()
+		linger = timer.C
+	}
+
+	for len(batch) < h.config.ApplyBatchSize {
+		select {
+		case req := <-h.batches:
+			batch = append(batch, req)
+			continue
+		default:
+		}
+
+		if linger == nil {
+			break
+		}
+		select {
+		case req := <-h.batches:
+			batch = append(batch, req)
+			continue
+		case <-linger:
+		}
+		break
+	}
+
+	return batch
+}
+
+// applyRequests replicates the batch and fans the responses back to the callers.
+// A single command is applied as is, so the log stays readable by older nodes.
+func (h *Handler) applyRequests(batch []*applyRequest) {
+	if len(batch) == 1 {
+		data, err := h.applyPayload(batch[0].payload)
+		batch[0].result <- applyResult{data: data, err: err}
+		return
+	}
+
+	responses, err := h.applyBatch(batch)
+	for i, req := range batch {
+		if err != nil {
+			req.result <- applyResult{err: err}
+			continue
+		}
+		req.result <- applyResult{data: responses[i].Data, err: responses[i].Error}
+	}
+}
+
+// applyBatch replicates the commands as one log entry
+func (h *Handler) applyBatch(batch []*applyRequest) ([]*repo.ApplyResponse, error) {
+	commands := make([]repo.CommandPayload, len(batch))
+	for i, req := range batch {
+		commands[i] = req.payload
+	}
+
+	raftPayload, err := json.Marshal(repo.NewBatchPayload(commands))
+	if err != nil {
+		return nil, fmt.Errorf("error preparing saving data payload: %s", err.Error())
+	}
+
+	applyFuture := h.raft.Apply(raftPayload, applyTimeout)
+	if err := applyFuture.Error(); err != nil {
+		return nil, fmt.Errorf("error persisting data in raft cluster: %s", err.Error())
+	}
+
+	switch response := applyFuture.Response().(type) {
+	case *repo.BatchResponse:
+		if len(response.Responses) != len(batch) {
+			return nil, errors.New("error batch response does not match the commands")
+		}
+		return response.Responses, nil
+	case *repo.ApplyResponse:
+		// the batch as a whole was rejected
+		return nil, response.Error
+	}
+	return nil, errors.New("error response is not match batch response")
+}
@@ -0,0 +1,45 @@
+package store_router
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCollectBatch(t *testing.T) {
+	tests := []struct {
+		name      string
+		size      int
+		linger    time.Duration
+		queued    int
+		wantBatch int
+	}{
+		{name: "only the first", size: 4, queued: 0, wantBatch: 1},
+		{name: "queued behind the first", size: 4, queued: 2, wantBatch: 3},
+		{name: "full batch leaves the rest queued", size: 4, queued: 6, wantBatch: 4},
+		{name: "linger waits for no more than queued", size: 4, linger: 10 * time.Millisecond, queued: 1, wantBatch: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &Handler{
+				config:  Config{ApplyBatchSize: tt.size, ApplyBatchLinger: tt.linger},
+				batches: make(chan *applyRequest, tt.queued),
+			}
+			for i := 0; i < tt.queued; i++ {
+				h.batches <- &applyRequest{}
+			}
+
+			first := &applyRequest{}
+			batch := h.collectBatch(first)
+			if len(batch) != tt.wantBatch {
+				t.Fatalf("batch of %d commands, want %d", len(batch), tt.wantBatch)
+			}
+			if batch[0] != first {
+				t.Errorf("batch doesn't start with the first command")
+			}
+			if left := tt.queued - (tt.wantBatch - 1); len(h.batches) != left {
+				t.Errorf("%d commands left queued, want %d", len(h.batches), left)
+			}
+		})
+	}
+}
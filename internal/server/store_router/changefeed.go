@@ -0,0 +1,55 @@
+package store_router
+
+import (
+	"github.com/KushnerykPavel/raft-test-project/internal/repo"
+	"sync"
+)
+
+// changeBuffer changes a subscriber may fall behind before it is dropped
+const changeBuffer = 1024
+
+// changeFeed fans out changes applied by the FSM to the subscribers of this node
+type changeFeed struct {
+	mu          sync.Mutex
+	subscribers map[chan *repo.Change]struct{}
+}
+
+func newChangeFeed() *changeFeed {
+	return &changeFeed{subscribers: map[chan *repo.Change]struct{}{}}
+}
+
+// publish is the FSM apply hook. A subscriber which can't keep up is dropped
+// by closing its channel, so the FSM is never blocked by a slow reader.
+func (f *changeFeed) publish(change *repo.Change) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for ch := range f.subscribers {
+		select {
+		case ch <- change:
+		default:
+			delete(f.subscribers, ch)
+			close(ch)
+		}
+	}
+}
+
+func (f *changeFeed) subscribe() chan *repo.Change {
+	ch := make(chan *repo.Change, changeBuffer)
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.subscribers[ch] = struct{}{}
+
+	return ch
+}
+
+func (f *changeFeed) unsubscribe(ch chan *repo.Change) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, ok := f.subscribers[ch]; ok {
+		delete(f.subscribers, ch)
+		close(ch)
+	}
+}
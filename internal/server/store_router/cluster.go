@@ -0,0 +1,98 @@
+package store_router
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/KushnerykPavel/raft-test-project/internal/repo"
+	"github.com/KushnerykPavel/raft-test-project/internal/storage"
+	"github.com/go-chi/render"
+	"github.com/hashicorp/raft"
+	"log"
+	"net/http"
+	"time"
+)
+
+// nodeRegistrationInterval how often the leader checks its own node record
+const nodeRegistrationInterval = 5 * time.Second
+
+// RunNodeRegistration keeps the addresses of the leader in the node records,
+// the followers are registered by the leader when they join
+func (h *Handler) RunNodeRegistration(ctx context.Context) {
+	ticker := time.NewTicker(nodeRegistrationInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if h.raft.State() != raft.Leader || h.config.HTTPAddress == "" {
+			continue
+		}
+
+		if err := h.registerNode(); err != nil {
+			log.Printf("error registering node: %s", err.Error())
+		}
+	}
+}
+
+// registerNode writes the node record of this node when it is missing or stale
+func (h *Handler) registerNode() error {
+	node := repo.Node{
+		ID:          h.config.NodeID,
+		RaftAddress: h.config.RaftAddress,
+		HTTPAddress: h.config.HTTPAddress,
+	}
+
+	var stored repo.Node
+	err := h.get(repo.NodeKey(node.ID), &stored)
+	if err == nil && stored == node {
+		return nil
+	}
+	if err != nil && !errors.Is(err, storage.ErrNotFound) {
+		return err
+	}
+	return h.applyRaft("SET", repo.NodeKey(node.ID), node)
+}
+
+// ClusterNodes returns the servers of the latest raft configuration
+// with their HTTP addresses, any node answers it
+func (h *Handler) ClusterNodes(w http.ResponseWriter, r *http.Request) {
+	configFuture := h.raft.GetConfiguration()
+	if err := configFuture.Error(); err != nil {
+		render.Render(w, r, ErrInvalidRequest(fmt.Errorf("failed to get raft configuration: %s", err.Error())))
+		return
+	}
+
+	_, leaderID := h.raft.LeaderWithID()
+	response := &repo.ClusterResponse{
+		LeaderID: string(leaderID),
+		Nodes:    make([]repo.ClusterNode, 0, len(configFuture.Configuration().Servers)),
+		Addr:     h.addr,
+	}
+	for _, server := range configFuture.Configuration().Servers {
+		node := repo.ClusterNode{
+			ID:          string(server.ID),
+			RaftAddress: string(server.Address),
+			Suffrage:    server.Suffrage.String(),
+			Leader:      server.ID == leaderID,
+		}
+
+		var stored repo.Node
+		err := h.get(repo.NodeKey(node.ID), &stored)
+		switch {
+		case err == nil:
+			node.HTTPAddress = stored.HTTPAddress
+		case !errors.Is(err, storage.ErrNotFound):
+			render.Render(w, r, ErrInvalidRequest(fmt.Errorf("error getting node %s: %s", node.ID, err.Error())))
+			return
+		}
+		response.Nodes = append(response.Nodes, node)
+	}
+
+	render.Status(r, http.StatusOK)
+	render.Render(w, r, response)
+}
@@ -0,0 +1,179 @@
+package store_router
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/KushnerykPavel/raft-test-project/internal/repo"
+	"github.com/KushnerykPavel/raft-test-project/internal/storage"
+	"github.com/go-chi/render"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// changesPage changes read from the changelog in one store snapshot
+	changesPage = 500
+	// eventsKeepAlive how often an idle server-sent events stream gets a comment line
+	eventsKeepAlive = 15 * time.Second
+)
+
+// Events streams commands applied by this node which changed keys of the merchant.
+// The stream is newline delimited JSON, or server-sent events when the client accepts
+// text/event-stream. from_index (or Last-Event-ID) replays the changelog starting at the
+// index before switching to live changes. Commands applied in one batch share the index,
+// server-sent event ids are "index.offset". The stream ends when the client falls behind,
+// it resumes from the last event it got.
+func (h *Handler) Events(w http.ResponseWriter, r *http.Request) {
+	merchant := merchantFromContext(r.Context())
+	sse := strings.Contains(r.Header.Get("Accept"), "text/event-stream")
+
+	var (
+		fromIndex  uint64
+		fromOffset int
+		replay     bool
+		err        error
+	)
+	if value := r.URL.Query().Get("from_index"); value != "" {
+		fromIndex, err = strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			render.Render(w, r, ErrInvalidRequest(repo.ValidationErrors{"from_index": "must be a raft index"}))
+			return
+		}
+		replay = true
+	} else if value := r.Header.Get("Last-Event-ID"); value != "" {
+		fromIndex, fromOffset, err = parseEventID(value)
+		if err != nil {
+			render.Render(w, r, ErrInvalidRequest(errors.New("Last-Event-ID must be a raft index")))
+			return
+		}
+		replay = true
+	}
+
+	// subscribe before reading the changelog, so nothing applied in between is missed
+	changes := h.feed.subscribe()
+	defer h.feed.unsubscribe(changes)
+
+	// the stream outlives the server write timeout
+	rc := http.NewResponseController(w)
+	_ = rc.SetWriteDeadline(time.Time{})
+
+	if sse {
+		w.Header().Set("Content-Type", "text/event-stream")
+	} else {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+	}
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+
+	write := func(change *repo.Change) error {
+		if change.MerchantID() != merchant.ID {
+			return nil
+		}
+
+		data, err := json.Marshal(change)
+		if err != nil {
+			return err
+		}
+		if sse {
+			_, err = fmt.Fprintf(w, "id: %d.%d\nevent: %s\ndata: %s\n\n", change.Index, change.Offset, strings.ToLower(change.Operation), data)
+		} else {
+			_, err = fmt.Fprintf(w, "%s\n", data)
+		}
+		return err
+	}
+
+	var (
+		lastIndex  uint64
+		lastOffset int
+	)
+	if replay {
+		for {
+			page, err := h.readChanges(fromIndex, fromOffset, changesPage)
+			if err != nil {
+				return
+			}
+			for _, change := range page {
+				if err := write(change); err != nil {
+					return
+				}
+				lastIndex, lastOffset = change.Index, change.Offset
+			}
+			if len(page) < changesPage {
+				break
+			}
+			fromIndex, fromOffset = lastIndex, lastOffset+1
+		}
+	}
+	_ = rc.Flush()
+
+	keepAlive := time.NewTicker(eventsKeepAlive)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-keepAlive.C:
+			if sse {
+				if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+					return
+				}
+				_ = rc.Flush()
+			}
+		case change, ok := <-changes:
+			if !ok {
+				return
+			}
+			if !change.After(lastIndex, lastOffset) {
+				continue
+			}
+			if err := write(change); err != nil {
+				return
+			}
+			lastIndex, lastOffset = change.Index, change.Offset
+			_ = rc.Flush()
+		}
+	}
+}
+
+// parseEventID returns the position right after the event id. Ids of streams
+// written before batching carry only the index.
+func parseEventID(id string) (uint64, int, error) {
+	indexValue, offsetValue, batched := strings.Cut(id, ".")
+	index, err := strconv.ParseUint(indexValue, 10, 64)
+	if err != nil {
+		return 0, 0, err
+	}
+	if !batched {
+		return index + 1, 0, nil
+	}
+
+	offset, err := strconv.Atoi(offsetValue)
+	if err != nil || offset < 0 {
+		return 0, 0, errors.New("invalid event offset")
+	}
+	return index, offset + 1, nil
+}
+
+// readChanges reads at most limit changes of the changelog starting at the index and offset
+func (h *Handler) readChanges(fromIndex uint64, fromOffset, limit int) ([]*repo.Change, error) {
+	changes := make([]*repo.Change, 0, limit)
+
+	err := h.store.Scan(repo.ChangelogPrefix, repo.ChangelogKey(fromIndex, fromOffset), func(_ string, val []byte) error {
+		if len(changes) == limit {
+			return storage.ErrStop
+		}
+
+		var change repo.Change
+		if err := json.Unmarshal(val, &change); err != nil {
+			return err
+		}
+		changes = append(changes, &change)
+		return nil
+	})
+
+	return changes, err
+}
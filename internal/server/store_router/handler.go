@@ -1,20 +1,131 @@
 package store_router
 
 import (
-	"github.com/dgraph-io/badger/v2"
+	"encoding/json"
+	"github.com/KushnerykPavel/raft-test-project/internal/processor"
+	"github.com/KushnerykPavel/raft-test-project/internal/repo"
+	"github.com/KushnerykPavel/raft-test-project/internal/storage"
 	"github.com/hashicorp/raft"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
+// applyTimeout how long a command waits to be enqueued by raft
+const applyTimeout = time.Second
+
+// Config store router settings
+type Config struct {
+	// NodeID raft server id of the node
+	NodeID string
+	// RaftAddress address of the raft transport of the node
+	RaftAddress string
+	// HTTPAddress base url other nodes and the frontend reach the node at
+	HTTPAddress string
+
+	// DefaultMerchant merchant the orders and tokens written before merchants are moved under,
+	// empty leaves them where they are
+	DefaultMerchant string
+
+	// IdempotencyRetention how long responses of requests with
+	// Idempotency-Key header are kept for replay.
+	IdempotencyRetention time.Duration
+
+	// AuthorizationTTL how long an authorization holds funds before it expires
+	AuthorizationTTL time.Duration
+	// AuthorizationSweepInterval how often the leader looks for expired authorizations
+	AuthorizationSweepInterval time.Duration
+
+	// SchedulerInterval how often the leader looks for due subscription charges
+	SchedulerInterval time.Duration
+
+	// DunningRetrySchedule delays before retries of a failed subscription charge
+	DunningRetrySchedule []time.Duration
+	// DunningMaxFailures failed charges in a row before the subscription is suspended
+	DunningMaxFailures int
+
+	// ProcessorTimeout how long the leader waits for the payment processor
+	ProcessorTimeout time.Duration
+
+	// WebhookInterval how often the leader looks for events to deliver
+	WebhookInterval time.Duration
+	// WebhookTimeout how long the leader waits for the merchant endpoint
+	WebhookTimeout time.Duration
+	// WebhookBackoff delay after the first failed delivery, doubled after every next one
+	WebhookBackoff time.Duration
+	// WebhookMaxAttempts delivery attempts before the event is dropped
+	WebhookMaxAttempts int
+
+	// ApplyBatchSize most commands coalesced into one raft log entry, 1 turns batching off
+	ApplyBatchSize int
+	// ApplyBatchLinger how long a batch waits for more commands before it is applied
+	ApplyBatchLinger time.Duration
+
+	// StorageGCInterval how often the node collects garbage of its storage
+	StorageGCInterval time.Duration
+	// StorageGCDiscardRatio share of stale data which makes a badger value log file rewritten
+	StorageGCDiscardRatio float64
+	// LogCollector raft log store collected together with the storage, nil when it needs no collection
+	LogCollector storage.Collector
+
+	// ReadyMaxLastContact longest time since the follower heard from the leader for the node to be ready
+	ReadyMaxLastContact time.Duration
+	// ReadyMaxApplyLag most committed entries not yet applied by the FSM for the node to be ready
+	ReadyMaxApplyLag uint64
+}
+
 type Handler struct {
-	raft *raft.Raft
-	db   *badger.DB
-	addr string
+	raft      *raft.Raft
+	store     storage.Store
+	processor processor.Processor
+	addr      string
+	config    Config
+
+	// orderLocks serialize processor calls of the same order
+	orderLocks [orderLockStripes]sync.Mutex
+	// feed changes applied by the FSM of this node
+	feed *changeFeed
+	// batches commands waiting to be coalesced into a log entry
+	batches chan *applyRequest
+	// gcMu lets one storage garbage collection run at a time
+	gcMu sync.Mutex
+	// lastGC outcome of the last storage garbage collection
+	lastGC atomic.Pointer[repo.StorageGCRun]
+}
+
+func New(raft *raft.Raft, fsm *repo.FSM, store storage.Store, proc processor.Processor, addr string, config Config) *Handler {
+	h := &Handler{
+		raft:      raft,
+		store:     store,
+		processor: proc,
+		addr:      addr,
+		config:    config,
+		feed:      newChangeFeed(),
+		batches:   make(chan *applyRequest, max(config.ApplyBatchSize, 1)),
+	}
+	fsm.OnApply(h.feed.publish)
+
+	return h
 }
 
-func New(raft *raft.Raft, db *badger.DB, addr string) *Handler {
-	return &Handler{
-		raft: raft,
-		db:   db,
-		addr: addr,
+// get reads json value stored under the key into v.
+// It returns storage.ErrNotFound when the key does not exist.
+func (h *Handler) get(key string, v any) error {
+	value, err := h.store.Get(key)
+	if err != nil {
+		return err
 	}
+	return json.Unmarshal(value, v)
+}
+
+// getOrder reads the order with its transactions and history.
+// It returns storage.ErrNotFound when the order does not exist.
+func (h *Handler) getOrder(key string) (*repo.Order, error) {
+	var order *repo.Order
+	err := storage.View(h.store, func(r storage.Reader) error {
+		var err error
+		order, err = repo.LoadOrder(r, key)
+		return err
+	})
+	return order, err
 }
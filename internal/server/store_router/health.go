@@ -0,0 +1,81 @@
+package store_router
+
+import (
+	"errors"
+	"fmt"
+	"github.com/KushnerykPavel/raft-test-project/internal/repo"
+	"github.com/KushnerykPavel/raft-test-project/internal/storage"
+	"github.com/go-chi/render"
+	"github.com/hashicorp/raft"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Healthz answers 200 while the process serves requests and its storage answers reads.
+// The check reads the probe key, which is never written, so the node state stays
+// the one raft replicated.
+func (h *Handler) Healthz(w http.ResponseWriter, r *http.Request) {
+	response := &repo.HealthResponse{
+		Ready:  true,
+		Reason: repo.HealthOK,
+		Addr:   h.addr,
+	}
+
+	_, err := h.store.Get(repo.HealthProbeKey)
+	if err != nil && !errors.Is(err, storage.ErrNotFound) {
+		response.Ready = false
+		response.Reason = repo.HealthStorageUnreadable
+		response.Error = err.Error()
+	}
+
+	renderHealth(w, r, response)
+}
+
+// Readyz answers 200 when the node knows the leader, a follower heard from it
+// recently and the FSM is not far behind the commit index.
+func (h *Handler) Readyz(w http.ResponseWriter, r *http.Request) {
+	stats := h.raft.Stats()
+	commitIndex, _ := strconv.ParseUint(stats["commit_index"], 10, 64)
+	_, leaderID := h.raft.LeaderWithID()
+
+	response := &repo.HealthResponse{
+		Ready:        true,
+		Reason:       repo.HealthOK,
+		Addr:         h.addr,
+		State:        h.raft.State().String(),
+		LeaderID:     string(leaderID),
+		AppliedIndex: h.raft.AppliedIndex(),
+		CommitIndex:  commitIndex,
+	}
+
+	var sinceContact time.Duration
+	if h.raft.State() != raft.Leader {
+		sinceContact = time.Since(h.raft.LastContact())
+		response.LastContact = sinceContact.String()
+	}
+
+	switch {
+	case leaderID == "":
+		response.Reason = repo.HealthNoLeader
+		response.Error = "no known leader"
+	case sinceContact > h.config.ReadyMaxLastContact:
+		response.Reason = repo.HealthLeaderContactStale
+		response.Error = fmt.Sprintf("last contact with the leader %s ago, at most %s", sinceContact, h.config.ReadyMaxLastContact)
+	case commitIndex > response.AppliedIndex && commitIndex-response.AppliedIndex > h.config.ReadyMaxApplyLag:
+		response.Reason = repo.HealthApplyLagging
+		response.Error = fmt.Sprintf("%d committed entries not applied, at most %d", commitIndex-response.AppliedIndex, h.config.ReadyMaxApplyLag)
+	}
+	response.Ready = response.Reason == repo.HealthOK
+
+	renderHealth(w, r, response)
+}
+
+func renderHealth(w http.ResponseWriter, r *http.Request, response *repo.HealthResponse) {
+	if response.Ready {
+		render.Status(r, http.StatusOK)
+	} else {
+		render.Status(r, http.StatusServiceUnavailable)
+	}
+	render.Render(w, r, response)
+}
@@ -0,0 +1,167 @@
+package store_router
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/KushnerykPavel/raft-test-project/internal/repo"
+	"github.com/KushnerykPavel/raft-test-project/internal/storage"
+	"github.com/go-chi/render"
+	"io"
+	"log"
+	"net/http"
+	"time"
+)
+
+const (
+	idempotencyKeyHeader      = "Idempotency-Key"
+	idempotencyReplayedHeader = "Idempotent-Replayed"
+
+	maxIdempotencyKeyLength = 255
+)
+
+func ErrConflict(err error) render.Renderer {
+	return &ErrResponse{
+		Err:            err,
+		HTTPStatusCode: http.StatusConflict,
+		StatusText:     "Conflict.",
+		ErrorText:      err.Error(),
+	}
+}
+
+// responseRecorder holds the response written by the handler
+// until it is stored for the idempotency key
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (rr *responseRecorder) WriteHeader(status int) {
+	if rr.status == 0 {
+		rr.status = status
+	}
+}
+
+func (rr *responseRecorder) Write(b []byte) (int, error) {
+	if rr.status == 0 {
+		rr.status = http.StatusOK
+	}
+	return rr.body.Write(b)
+}
+
+// flush writes the held response to the client
+func (rr *responseRecorder) flush() {
+	rr.ResponseWriter.WriteHeader(rr.status)
+	_, _ = rr.ResponseWriter.Write(rr.body.Bytes())
+}
+
+// processorCalledContextKey flags in the request context that the processor was called
+const processorCalledContextKey contextKey = "processor_called"
+
+// markProcessorCalled records that the request has sent a transaction to the processor
+func markProcessorCalled(ctx context.Context) {
+	if called, ok := ctx.Value(processorCalledContextKey).(*bool); ok {
+		*called = true
+	}
+}
+
+// Idempotency replays the stored response for a retried request with the same
+// Idempotency-Key header. The key reused with another request body is rejected
+// with 409. The key is reserved by a pending record applied through raft before
+// the request is executed and the response is stored before it is sent, so a
+// request is never executed twice for the key. A failed request that never
+// reached the processor releases the key and may be retried, any other
+// response is stored.
+func (h *Handler) Idempotency(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		idempotencyKey := r.Header.Get(idempotencyKeyHeader)
+		if idempotencyKey == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if len(idempotencyKey) > maxIdempotencyKeyLength {
+			render.Render(w, r, ErrInvalidRequest(fmt.Errorf("%s must be at most %d characters", idempotencyKeyHeader, maxIdempotencyKeyLength)))
+			return
+		}
+
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			render.Render(w, r, ErrInvalidRequest(fmt.Errorf("error reading request body: %s", err.Error())))
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		merchant := merchantFromContext(r.Context())
+		key := repo.IdempotencyKey(merchant.ID, idempotencyKey)
+		requestHash := repo.HashRequest(r.Method, r.URL.Path, body)
+
+		var record repo.IdempotencyRecord
+		err = h.get(key, &record)
+		if err != nil && !errors.Is(err, storage.ErrNotFound) {
+			render.Render(w, r, ErrInvalidRequest(fmt.Errorf("error getting key %s from storage: %s", idempotencyKey, err.Error())))
+			return
+		}
+
+		if err == nil && !record.Expired(time.Now()) {
+			if record.RequestHash != requestHash {
+				render.Render(w, r, ErrConflict(fmt.Errorf("%s %s was used with another request", idempotencyKeyHeader, idempotencyKey)))
+				return
+			}
+			if record.Pending() {
+				render.Render(w, r, ErrConflict(fmt.Errorf("request with %s %s is in progress", idempotencyKeyHeader, idempotencyKey)))
+				return
+			}
+
+			w.Header().Set("Content-Type", record.ContentType)
+			w.Header().Set(idempotencyReplayedHeader, "true")
+			w.WriteHeader(record.StatusCode)
+			_, _ = w.Write(record.Body)
+			return
+		}
+
+		record = repo.IdempotencyRecord{
+			Key:         idempotencyKey,
+			RequestHash: requestHash,
+			ExpiresAt:   time.Now().Add(h.config.IdempotencyRetention).Unix(),
+		}
+		// the key is reserved by the log, a concurrent request on any node is refused
+		if err := h.applyRaft("SET_IDEMPOTENCY", key, record); err != nil {
+			if errors.Is(err, repo.ErrIdempotencyKeyInUse) {
+				err = fmt.Errorf("request with %s %s is in progress: %w", idempotencyKeyHeader, idempotencyKey, err)
+			} else {
+				err = fmt.Errorf("error reserving %s %s: %w", idempotencyKeyHeader, idempotencyKey, err)
+			}
+			render.Render(w, r, ErrApply(err))
+			return
+		}
+
+		called := new(bool)
+		r = r.WithContext(context.WithValue(r.Context(), processorCalledContextKey, called))
+		recorder := &responseRecorder{ResponseWriter: w}
+		next.ServeHTTP(recorder, r)
+
+		// a failed request is released only when the processor was never called,
+		// otherwise money may have moved and the response is replayed like a success
+		if (recorder.status < 200 || recorder.status >= 300) && !*called {
+			if err := h.applyRaft("DELETE", key, nil); err != nil {
+				log.Printf("error releasing %s %s: %s", idempotencyKeyHeader, idempotencyKey, err.Error())
+			}
+			recorder.flush()
+			return
+		}
+
+		record.StatusCode = recorder.status
+		record.ContentType = recorder.Header().Get("Content-Type")
+		record.Body = recorder.body.Bytes()
+
+		// the request is executed and its response is sent anyway,
+		// a key left pending is never executed again
+		if err := h.applyRaft("SET_IDEMPOTENCY", key, record); err != nil {
+			log.Printf("request is executed, error storing its response for %s %s: %s", idempotencyKeyHeader, idempotencyKey, err.Error())
+		}
+		recorder.flush()
+	})
+}
@@ -0,0 +1,30 @@
+package store_router
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/KushnerykPavel/raft-test-project/internal/repo"
+	"github.com/go-chi/render"
+	"net/http"
+)
+
+// Intents returns the transactions sent to the processor whose outcome is not applied yet.
+// Those past their lock are to be reconciled with the processor by the transaction id.
+func (h *Handler) Intents(w http.ResponseWriter, r *http.Request) {
+	intents := make([]repo.Intent, 0)
+	err := h.store.Scan(repo.IntentPrefix, "", func(_ string, val []byte) error {
+		var intent repo.Intent
+		if err := json.Unmarshal(val, &intent); err != nil {
+			return err
+		}
+		intents = append(intents, intent)
+		return nil
+	})
+	if err != nil {
+		render.Render(w, r, ErrInvalidRequest(fmt.Errorf("error reading intents: %s", err.Error())))
+		return
+	}
+
+	render.Status(r, http.StatusOK)
+	render.Render(w, r, &repo.IntentsResponse{Intents: intents, Addr: h.addr})
+}
@@ -0,0 +1,55 @@
+package store_router
+
+import (
+	"errors"
+	"fmt"
+	"github.com/KushnerykPavel/raft-test-project/internal/repo"
+	"github.com/go-chi/render"
+	"github.com/google/uuid"
+	"github.com/hashicorp/raft"
+	"net/http"
+)
+
+// CreateMerchant registers a new merchant and returns its api key.
+// The api key is shown only once, the cluster stores just its hash.
+func (h *Handler) CreateMerchant(w http.ResponseWriter, r *http.Request) {
+	data := &repo.MerchantRequest{}
+	if err := render.Bind(r, data); err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+
+	if h.raft.State() != raft.Leader {
+		render.Render(w, r, ErrInvalidRequest(errors.New("node is not leader")))
+		return
+	}
+
+	apiKey, err := repo.NewAPIKey()
+	if err != nil {
+		render.Render(w, r, ErrInvalidRequest(fmt.Errorf("error generating api key: %s", err.Error())))
+		return
+	}
+
+	record := &repo.MerchantRecord{
+		Merchant: repo.Merchant{
+			ID:   uuid.New().String(),
+			Name: data.Name,
+		},
+		APIKeyHash: repo.HashAPIKey(apiKey),
+	}
+
+	if err := h.applyRaft("SET_MERCHANT", repo.MerchantKey(record.Merchant.ID), record); err != nil {
+		render.Render(w, r, ErrInvalidRequest(fmt.Errorf("applyRaft error: %s", err.Error())))
+		return
+	}
+
+	response := &repo.MerchantResponse{
+		ID:     record.Merchant.ID,
+		Name:   record.Merchant.Name,
+		APIKey: apiKey,
+		Addr:   h.addr,
+	}
+
+	render.Status(r, http.StatusCreated)
+	render.Render(w, r, response)
+}
@@ -0,0 +1,70 @@
+package store_router
+
+import (
+	"context"
+	"errors"
+	"github.com/KushnerykPavel/raft-test-project/internal/repo"
+	"github.com/hashicorp/raft"
+	"log"
+	"time"
+)
+
+// flatKeysMigrationInterval how often a node checks whether it leads the cluster to run the migration
+const flatKeysMigrationInterval = 5 * time.Second
+
+// RunFlatKeysMigration moves orders and recurring tokens written before merchants
+// under the default merchant. The leader applies the migration through raft chunk by
+// chunk, a node runs it once it leads and stops when every flat key is examined.
+func (h *Handler) RunFlatKeysMigration(ctx context.Context) {
+	if h.config.DefaultMerchant == "" {
+		return
+	}
+
+	ticker := time.NewTicker(flatKeysMigrationInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if h.raft.State() != raft.Leader {
+			continue
+		}
+
+		moved, err := h.migrateFlatKeys()
+		if err != nil {
+			log.Printf("error migrating flat keys under merchant %s: %s", h.config.DefaultMerchant, err.Error())
+			continue
+		}
+		if moved > 0 {
+			log.Printf("migrated %d flat keys under merchant %s", moved, h.config.DefaultMerchant)
+		}
+		return
+	}
+}
+
+// migrateFlatKeys applies migration commands until every flat key is examined
+func (h *Handler) migrateFlatKeys() (int, error) {
+	migration := &repo.FlatKeysMigration{MerchantID: h.config.DefaultMerchant}
+
+	moved := 0
+	for {
+		data, err := h.apply("MIGRATE_FLAT_KEYS", "", migration)
+		if err != nil {
+			return moved, err
+		}
+		applied, ok := data.(*repo.FlatKeysMigration)
+		if !ok {
+			return moved, errors.New("error flat keys migration response")
+		}
+
+		moved += applied.Moved
+		if applied.Next == "" {
+			return moved, nil
+		}
+		migration.After = applied.Next
+	}
+}
@@ -9,16 +9,16 @@ import (
 	"github.com/google/uuid"
 	"github.com/hashicorp/raft"
 	"net/http"
-	"time"
 )
 
 type ErrResponse struct {
 	Err            error `json:"-"` // low-level runtime error
 	HTTPStatusCode int   `json:"-"` // http response status code
 
-	StatusText string `json:"status"`          // user-level status message
-	AppCode    int64  `json:"code,omitempty"`  // application-specific error code
-	ErrorText  string `json:"error,omitempty"` // application-level error message, for debugging
+	StatusText string            `json:"status"`           // user-level status message
+	AppCode    int64             `json:"code,omitempty"`   // application-specific error code
+	ErrorText  string            `json:"error,omitempty"`  // application-level error message, for debugging
+	Fields     map[string]string `json:"fields,omitempty"` // field-level validation errors
 }
 
 func (e *ErrResponse) Render(w http.ResponseWriter, r *http.Request) error {
@@ -27,37 +27,85 @@ func (e *ErrResponse) Render(w http.ResponseWriter, r *http.Request) error {
 }
 
 func ErrInvalidRequest(err error) render.Renderer {
+	var fields repo.ValidationErrors
+	errors.As(err, &fields)
+
 	return &ErrResponse{
 		Err:            err,
 		HTTPStatusCode: 400,
 		StatusText:     "Invalid request.",
 		ErrorText:      err.Error(),
+		Fields:         fields,
 	}
 }
 
 func (h *Handler) applyRaft(operation, key string, value any) error {
+	_, err := h.apply(operation, key, value)
+	return err
+}
+
+// applyTransaction adds the transaction to the order and returns it as applied by the FSM
+func (h *Handler) applyTransaction(orderKey string, transaction *repo.Transaction) (*repo.Transaction, error) {
+	data, err := h.apply("SET_TRANSACTIONS", orderKey, transaction)
+	if err != nil {
+		return nil, err
+	}
+
+	applied, ok := data.(*repo.Transaction)
+	if !ok {
+		return nil, errors.New("error response is not a transaction")
+	}
+
+	return applied, nil
+}
+
+// apply replicates the command and returns data of the FSM response.
+// Concurrent commands are coalesced into one log entry when batching is on.
+func (h *Handler) apply(operation, key string, value any) (any, error) {
 	payload := repo.CommandPayload{
 		Operation: operation,
 		Key:       key,
 		Value:     value,
 	}
 
+	if h.config.ApplyBatchSize > 1 {
+		return h.applyBatched(payload)
+	}
+	return h.applyPayload(payload)
+}
+
+// applyPayload replicates the command as a log entry of its own
+func (h *Handler) applyPayload(payload repo.CommandPayload) (any, error) {
 	raftPayload, err := json.Marshal(payload)
 	if err != nil {
-		return fmt.Errorf("error preparing saving data payload: %s", err.Error())
+		return nil, fmt.Errorf("error preparing saving data payload: %s", err.Error())
 	}
 
-	applyFuture := h.raft.Apply(raftPayload, 500*time.Microsecond)
+	applyFuture := h.raft.Apply(raftPayload, applyTimeout)
 	if err := applyFuture.Error(); err != nil {
-		return fmt.Errorf("error persisting data in raft cluster: %s", err.Error())
+		return nil, fmt.Errorf("error persisting data in raft cluster: %s", err.Error())
 	}
 
-	_, ok := applyFuture.Response().(*repo.ApplyResponse)
+	response, ok := applyFuture.Response().(*repo.ApplyResponse)
 	if !ok {
-		return errors.New("error response is not match apply response")
+		return nil, errors.New("error response is not match apply response")
 	}
 
-	return nil
+	return response.Data, response.Error
+}
+
+// ErrApply maps error of applyRaft to the response
+func ErrApply(err error) render.Renderer {
+	if errors.Is(err, repo.ErrTransitionNotAllowed) ||
+		errors.Is(err, repo.ErrRefundExceedsCaptured) ||
+		errors.Is(err, repo.ErrCaptureExceedsAuthorized) ||
+		errors.Is(err, repo.ErrAuthorizationExpired) ||
+		errors.Is(err, repo.ErrSubscriptionNotActive) ||
+		errors.Is(err, repo.ErrTransactionInProgress) ||
+		errors.Is(err, repo.ErrIdempotencyKeyInUse) {
+		return ErrConflict(err)
+	}
+	return ErrInvalidRequest(fmt.Errorf("applyRaft error: %s", err.Error()))
 }
 
 func (h *Handler) Pay(w http.ResponseWriter, r *http.Request) {
@@ -72,27 +120,36 @@ func (h *Handler) Pay(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	merchant := merchantFromContext(r.Context())
+
 	transaction := &repo.Transaction{
 		ID:       uuid.New().String(),
+		OrderID:  data.OrderID,
 		Type:     repo.FirstTransactionType,
-		Amount:   data.Amount,
+		Status:   repo.SucceededTransactionStatus,
+		Amount:   data.AmountMinor,
 		Currency: data.Currency,
+		Token:    data.GetRecurringToken(),
 	}
 
-	token := data.GetRecurringToken()
-
-	if err := h.applyRaft("SET", token, data); err != nil {
-		render.Render(w, r, ErrInvalidRequest(fmt.Errorf("applyRaft error: %s", err.Error())))
+	applied, err := h.processTransaction(r.Context(), repo.OrderKey(merchant.ID, data.OrderID), transaction, cardOf(data))
+	if err != nil {
+		render.Render(w, r, ErrApply(err))
 		return
 	}
 
-	if err := h.applyRaft("SET_TRANSACTIONS", data.OrderID, transaction); err != nil {
-		render.Render(w, r, ErrInvalidRequest(fmt.Errorf("applyRaft error: %s", err.Error())))
-		return
+	response := &repo.PayResponse{
+		TransactionID: applied.ID,
+		Status:        applied.Status,
+		Processor:     applied.Processor,
+		Addr:          h.addr,
 	}
 
-	response := &repo.PayResponse{Token: token, Addr: h.addr}
+	// the card is kept for recurring payments only when it was approved
+	if applied.Status == repo.SucceededTransactionStatus && h.storeCard(merchant.ID, applied, data) {
+		response.Token = applied.Token
+	}
 
-	render.Status(r, http.StatusCreated)
+	render.Status(r, transactionStatusCode(applied))
 	render.Render(w, r, response)
 }
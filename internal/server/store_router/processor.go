@@ -0,0 +1,267 @@
+package store_router
+
+import (
+	"context"
+	"errors"
+	"github.com/KushnerykPavel/raft-test-project/internal/processor"
+	"github.com/KushnerykPavel/raft-test-project/internal/repo"
+	"github.com/KushnerykPavel/raft-test-project/internal/storage"
+	"hash/fnv"
+	"log"
+	"net/http"
+	"time"
+)
+
+const (
+	// orderLockStripes number of mutexes the order keys are spread over
+	orderLockStripes = 256
+
+	// outcomeAttempts applies of the processor outcome before it is left for reconciliation
+	outcomeAttempts = 3
+	// outcomeRetryDelay wait between the applies of the processor outcome
+	outcomeRetryDelay = 100 * time.Millisecond
+)
+
+// lockOrder serializes processor calls of the order on this node, so two
+// concurrent refunds can't both be sent to the processor before either is applied.
+func (h *Handler) lockOrder(orderKey string) func() {
+	hash := fnv.New32a()
+	_, _ = hash.Write([]byte(orderKey))
+
+	mu := &h.orderLocks[hash.Sum32()%orderLockStripes]
+	mu.Lock()
+	return mu.Unlock
+}
+
+// refusedError the order of this node refused the transaction, unlike errors of reading it
+type refusedError struct {
+	err error
+}
+
+func (e *refusedError) Error() string { return e.err.Error() }
+
+func (e *refusedError) Unwrap() error { return e.err }
+
+// isRefused reports whether the order refused the transaction
+func isRefused(err error) bool {
+	var refused *refusedError
+	return errors.As(err, &refused)
+}
+
+// previewTransaction checks the transaction against the current order of this node.
+// Amount, currency and authorization taken from the order by the FSM are filled
+// into the transaction. It returns the order with the transaction added.
+// A transaction the order refuses is reported by refusedError.
+func (h *Handler) previewTransaction(orderKey string, trx *repo.Transaction) (*repo.Order, error) {
+	order, err := h.getOrder(orderKey)
+	if errors.Is(err, storage.ErrNotFound) {
+		order, err = repo.NewOrder(trx.OrderID), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	preview := *trx
+	if err := order.AddTransaction(&preview, time.Now()); err != nil {
+		return nil, &refusedError{err: err}
+	}
+
+	trx.Amount = preview.Amount
+	trx.Currency = preview.Currency
+	trx.AuthorizationID = preview.AuthorizationID
+
+	return order, nil
+}
+
+// runProcessor sends the transaction to the processor and records the outcome on it.
+// A declined, failed or timed out call marks the transaction failed.
+func (h *Handler) runProcessor(ctx context.Context, order *repo.Order, trx *repo.Transaction, card *processor.Card) {
+	markProcessorCalled(ctx)
+	ctx, cancel := context.WithTimeout(ctx, h.config.ProcessorTimeout)
+	defer cancel()
+
+	req := &processor.Request{
+		Reference: trx.ID,
+		Card:      card,
+		Amount:    trx.Amount,
+		Currency:  trx.Currency,
+	}
+
+	switch trx.Type {
+	case repo.CaptureTransactionType, repo.VoidTransactionType, repo.ExpirationTransactionType:
+		if authorization := order.Transaction(trx.AuthorizationID); authorization != nil && authorization.Processor != nil {
+			req.OriginalReference = authorization.Processor.Reference
+		}
+	case repo.RefundTransactionType:
+		if charge := order.LastCharge(trx.Currency); charge != nil && charge.Processor != nil {
+			req.OriginalReference = charge.Processor.Reference
+		}
+	}
+
+	var (
+		resp *processor.Response
+		err  error
+	)
+	switch trx.Type {
+	case repo.FirstTransactionType, repo.RecurringTransactionType:
+		// sale is authorization captured right away
+		resp, err = h.processor.Authorize(ctx, req)
+		if err == nil && resp.Approved {
+			req.OriginalReference = resp.Reference
+			resp, err = h.processor.Capture(ctx, req)
+		}
+	case repo.AuthorizationTransactionType:
+		resp, err = h.processor.Authorize(ctx, req)
+	case repo.CaptureTransactionType:
+		resp, err = h.processor.Capture(ctx, req)
+	case repo.VoidTransactionType, repo.ExpirationTransactionType:
+		resp, err = h.processor.Void(ctx, req)
+	case repo.RefundTransactionType:
+		resp, err = h.processor.Refund(ctx, req)
+	default:
+		return
+	}
+
+	switch {
+	case errors.Is(err, processor.ErrTimeout) || errors.Is(err, context.DeadlineExceeded):
+		trx.Processor = &repo.ProcessorResponse{Code: "timeout", Message: err.Error()}
+	case err != nil:
+		trx.Processor = &repo.ProcessorResponse{Code: "error", Message: err.Error()}
+	default:
+		trx.Processor = &repo.ProcessorResponse{
+			Approved:  resp.Approved,
+			Reference: resp.Reference,
+			Code:      resp.Code,
+			Message:   resp.Message,
+		}
+	}
+
+	if trx.Processor.Approved {
+		trx.Status = repo.SucceededTransactionStatus
+		return
+	}
+
+	trx.Status = repo.FailedTransactionStatus
+	log.Printf("processor %s transaction %s of order %s failed: %s %s",
+		trx.Type, trx.ID, trx.OrderID, trx.Processor.Code, trx.Processor.Message)
+}
+
+// intentLock how long an intent holds the order for the outcome of its processor call
+func (h *Handler) intentLock() time.Duration {
+	return h.config.ProcessorTimeout + outcomeAttempts*(applyTimeout+outcomeRetryDelay)
+}
+
+// applyIntent records the transaction as pending before it is sent to the processor.
+// Amount, currency and authorization taken from the order by the FSM are filled into
+// the transaction. It returns the transaction as applied before when it is not pending.
+func (h *Handler) applyIntent(orderKey string, trx *repo.Transaction) (*repo.Transaction, error) {
+	data, err := h.apply("SET_INTENT", orderKey, &repo.Intent{
+		Transaction: *trx,
+		LockedUntil: time.Now().Add(h.intentLock()).Unix(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	intent, ok := data.(*repo.Transaction)
+	if !ok {
+		return nil, errors.New("error response is not a transaction")
+	}
+	if intent.Status == repo.PendingTransactionStatus {
+		trx.Amount = intent.Amount
+		trx.Currency = intent.Currency
+		trx.AuthorizationID = intent.AuthorizationID
+		trx.CreatedAt = intent.CreatedAt
+	}
+	return intent, nil
+}
+
+// applyOutcome applies the transaction with the processor outcome. An outcome which
+// can't be applied is kept on the intent for reconciliation and the transaction is
+// returned pending, the processor may have moved the money.
+func (h *Handler) applyOutcome(orderKey string, trx *repo.Transaction) *repo.Transaction {
+	var err error
+	for attempt := 1; attempt <= outcomeAttempts; attempt++ {
+		var applied *repo.Transaction
+		applied, err = h.applyTransaction(orderKey, trx)
+		if err == nil {
+			return applied
+		}
+		log.Printf("error applying outcome of transaction %s of order %s, attempt %d: %s",
+			trx.ID, trx.OrderID, attempt, err.Error())
+		time.Sleep(outcomeRetryDelay)
+	}
+
+	if err := h.applyRaft("SET_INTENT_OUTCOME", orderKey, trx); err != nil {
+		log.Printf("error keeping outcome of transaction %s of order %s on its intent: %s",
+			trx.ID, trx.OrderID, err.Error())
+	}
+	log.Printf("transaction %s of order %s is left for reconciliation: %s", trx.ID, trx.OrderID, err.Error())
+
+	pending := *trx
+	pending.Status = repo.PendingTransactionStatus
+	return &pending
+}
+
+// processTransaction records the intent of the transaction, runs it through
+// the processor and applies it with the outcome. Errors are returned only
+// before the processor is called.
+func (h *Handler) processTransaction(ctx context.Context, orderKey string, trx *repo.Transaction, card *processor.Card) (*repo.Transaction, error) {
+	unlock := h.lockOrder(orderKey)
+	defer unlock()
+
+	order, err := h.previewTransaction(orderKey, trx)
+	if err != nil {
+		return nil, err
+	}
+
+	intent, err := h.applyIntent(orderKey, trx)
+	if err != nil {
+		return nil, err
+	}
+	if intent.Status != repo.PendingTransactionStatus {
+		// applied before, e.g. expiration repeated by a new leader
+		return intent, nil
+	}
+
+	h.runProcessor(ctx, order, trx, card)
+
+	return h.applyOutcome(orderKey, trx), nil
+}
+
+// storeCard keeps the card of the approved transaction under its recurring token.
+// The transaction is applied already, so a failed write leaves the payment
+// without a token instead of failing it. It reports whether the card is stored.
+func (h *Handler) storeCard(merchantID string, trx *repo.Transaction, data *repo.PayRequest) bool {
+	for attempt := 1; attempt <= outcomeAttempts; attempt++ {
+		err := h.applyRaft("SET", repo.TokenKey(merchantID, trx.Token), data)
+		if err == nil {
+			return true
+		}
+		log.Printf("error storing card of transaction %s of order %s, attempt %d: %s",
+			trx.ID, trx.OrderID, attempt, err.Error())
+		time.Sleep(outcomeRetryDelay)
+	}
+	return false
+}
+
+// cardOf card data of the pay request
+func cardOf(data *repo.PayRequest) *processor.Card {
+	return &processor.Card{
+		Number:    data.CardNumber,
+		ExpiredAt: data.ExpiredAt,
+		Cvv:       data.Cvv,
+	}
+}
+
+// transactionStatusCode 402 Payment Required when the processor didn't approve the transaction,
+// 202 Accepted when its outcome is left for reconciliation
+func transactionStatusCode(trx *repo.Transaction) int {
+	switch trx.Status {
+	case repo.FailedTransactionStatus:
+		return http.StatusPaymentRequired
+	case repo.PendingTransactionStatus:
+		return http.StatusAccepted
+	}
+	return http.StatusCreated
+}
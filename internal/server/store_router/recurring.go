@@ -4,7 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"github.com/KushnerykPavel/raft-test-project/internal/repo"
-	"github.com/dgraph-io/badger/v2"
+	"github.com/KushnerykPavel/raft-test-project/internal/storage"
 	"github.com/go-chi/render"
 	"github.com/google/uuid"
 	"github.com/hashicorp/raft"
@@ -23,16 +23,11 @@ func (h *Handler) Recurring(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var keyByte = []byte(data.Token)
+	merchant := merchantFromContext(r.Context())
 
-	txn := h.db.NewTransaction(false)
-	defer func() {
-		_ = txn.Commit()
-	}()
-
-	_, err := txn.Get(keyByte)
-	if err != nil {
-		if errors.Is(err, badger.ErrKeyNotFound) {
+	card := &repo.PayRequest{}
+	if err := h.get(repo.TokenKey(merchant.ID, data.Token), card); err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
 			render.Render(w, r, ErrInvalidRequest(fmt.Errorf("key %s does not exists", data.Token)))
 			return
 		}
@@ -42,18 +37,27 @@ func (h *Handler) Recurring(w http.ResponseWriter, r *http.Request) {
 
 	transaction := &repo.Transaction{
 		ID:       uuid.New().String(),
+		OrderID:  data.OrderID,
 		Type:     repo.RecurringTransactionType,
-		Amount:   data.Amount,
+		Status:   repo.SucceededTransactionStatus,
+		Amount:   data.AmountMinor,
 		Currency: data.Currency,
+		Token:    data.Token,
 	}
 
-	if err := h.applyRaft("SET_TRANSACTIONS", data.OrderID, transaction); err != nil {
-		render.Render(w, r, ErrInvalidRequest(fmt.Errorf("applyRaft error: %s", err.Error())))
+	applied, err := h.processTransaction(r.Context(), repo.OrderKey(merchant.ID, data.OrderID), transaction, cardOf(card))
+	if err != nil {
+		render.Render(w, r, ErrApply(err))
 		return
 	}
 
-	response := &repo.RecurringResponse{Addr: h.addr}
+	response := &repo.RecurringResponse{
+		TransactionID: applied.ID,
+		Status:        applied.Status,
+		Processor:     applied.Processor,
+		Addr:          h.addr,
+	}
 
-	render.Status(r, http.StatusCreated)
+	render.Status(r, transactionStatusCode(applied))
 	render.Render(w, r, response)
 }
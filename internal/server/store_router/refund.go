@@ -0,0 +1,53 @@
+package store_router
+
+import (
+	"errors"
+	"github.com/KushnerykPavel/raft-test-project/internal/repo"
+	"github.com/go-chi/render"
+	"github.com/google/uuid"
+	"github.com/hashicorp/raft"
+	"net/http"
+)
+
+// Refund reverses full or partial amount of the order.
+// The refunded total is checked against captured amount inside the FSM,
+// so concurrent refunds of the same order can't over-refund it.
+func (h *Handler) Refund(w http.ResponseWriter, r *http.Request) {
+	data := &repo.RefundRequest{}
+	if err := render.Bind(r, data); err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+
+	if h.raft.State() != raft.Leader {
+		render.Render(w, r, ErrInvalidRequest(errors.New("node is not leader")))
+		return
+	}
+
+	merchant := merchantFromContext(r.Context())
+
+	transaction := &repo.Transaction{
+		ID:       uuid.New().String(),
+		OrderID:  data.OrderID,
+		Type:     repo.RefundTransactionType,
+		Status:   repo.SucceededTransactionStatus,
+		Amount:   data.AmountMinor,
+		Currency: data.Currency,
+	}
+
+	applied, err := h.processTransaction(r.Context(), repo.OrderKey(merchant.ID, data.OrderID), transaction, nil)
+	if err != nil {
+		render.Render(w, r, ErrApply(err))
+		return
+	}
+
+	response := &repo.RefundResponse{
+		TransactionID: applied.ID,
+		Status:        applied.Status,
+		Processor:     applied.Processor,
+		Addr:          h.addr,
+	}
+
+	render.Status(r, transactionStatusCode(applied))
+	render.Render(w, r, response)
+}
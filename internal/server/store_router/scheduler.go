@@ -0,0 +1,171 @@
+package store_router
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/KushnerykPavel/raft-test-project/internal/repo"
+	"github.com/KushnerykPavel/raft-test-project/internal/storage"
+	"github.com/hashicorp/raft"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RunScheduler charges subscriptions which are due.
+// It is started on every node, but does the work only while the node is the leader.
+// After a failover the new leader may repeat a run the previous one has already
+// committed, the FSM skips such a charge since the subscription moved past the run.
+func (h *Handler) RunScheduler(ctx context.Context) {
+	ticker := time.NewTicker(h.config.SchedulerInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if h.raft.State() != raft.Leader {
+			continue
+		}
+
+		if err := h.chargeDueSubscriptions(time.Now()); err != nil {
+			log.Printf("error charging subscriptions: %s", err.Error())
+		}
+	}
+}
+
+// dueRun subscription run found in the schedule
+type dueRun struct {
+	subscriptionKey string
+	runAt           int64
+}
+
+// chargeDueSubscriptions applies a charge for every run scheduled not later than now
+func (h *Handler) chargeDueSubscriptions(now time.Time) error {
+	var due []dueRun
+
+	err := h.store.Scan(repo.SchedulePrefix, "", func(key string, val []byte) error {
+		key = strings.TrimPrefix(key, repo.SchedulePrefix)
+		runAt, err := strconv.ParseInt(key[:strings.Index(key, "/")], 10, 64)
+		if err != nil {
+			return err
+		}
+
+		// the schedule is ordered by the run time
+		if runAt > now.Unix() {
+			return storage.ErrStop
+		}
+
+		var subscriptionKey string
+		if err := json.Unmarshal(val, &subscriptionKey); err != nil {
+			return err
+		}
+
+		due = append(due, dueRun{subscriptionKey: subscriptionKey, runAt: runAt})
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	policy := h.dunningPolicy()
+
+	for _, run := range due {
+		charge := &repo.SubscriptionCharge{
+			SubscriptionKey: run.subscriptionKey,
+			RunAt:           run.runAt,
+			Dunning:         policy,
+		}
+
+		subscription, err := h.chargeRun(charge)
+		if err != nil {
+			log.Printf("error charging run %d of subscription %s: %s", run.runAt, run.subscriptionKey, err.Error())
+			continue
+		}
+
+		switch subscription.Status {
+		case repo.PastDueSubscriptionStatus:
+			log.Printf("run %d of subscription %s failed %d times, retry at %d",
+				run.runAt, subscription.ID, subscription.Failures, subscription.NextRunAt)
+		case repo.SuspendedSubscriptionStatus:
+			log.Printf("subscription %s suspended after %d failures", subscription.ID, subscription.Failures)
+		default:
+			log.Printf("charged run %d of subscription %s", run.runAt, subscription.ID)
+		}
+	}
+
+	return nil
+}
+
+// dunningPolicy retry policy of failed charges configured on this node
+func (h *Handler) dunningPolicy() repo.DunningPolicy {
+	return repo.NewDunningPolicy(h.config.DunningRetrySchedule, h.config.DunningMaxFailures)
+}
+
+// chargeRun sends the subscription run to the processor and applies the charge with its response.
+// A run the order would refuse is applied without the processor call, the FSM records the refusal.
+// The run is not applied when the order or the card can't be read, the next tick retries it.
+// The intent of the charge is applied before the processor call, a run retried after
+// the call is sent again with the same transaction id, which the processor deduplicates.
+func (h *Handler) chargeRun(charge *repo.SubscriptionCharge) (*repo.Subscription, error) {
+	var subscription repo.Subscription
+	if err := h.get(charge.SubscriptionKey, &subscription); err != nil {
+		return nil, err
+	}
+
+	orderKey := repo.OrderKey(subscription.MerchantID, subscription.OrderID)
+
+	unlock := h.lockOrder(orderKey)
+	defer unlock()
+
+	trx := &repo.Transaction{
+		ID:       repo.ChargeTransactionID(subscription.ID, charge.RunAt),
+		OrderID:  subscription.OrderID,
+		Type:     repo.RecurringTransactionType,
+		Status:   repo.SucceededTransactionStatus,
+		Amount:   subscription.Amount,
+		Currency: subscription.Currency,
+		Token:    subscription.Token,
+	}
+
+	if subscription.Scheduled() && subscription.NextRunAt == charge.RunAt {
+		order, err := h.previewTransaction(orderKey, trx)
+		switch {
+		case isRefused(err):
+			// applied without processor response, the FSM records the refusal of the order
+		case err != nil:
+			return nil, err
+		default:
+			card := &repo.PayRequest{}
+			err := h.get(repo.TokenKey(subscription.MerchantID, subscription.Token), card)
+			switch {
+			case errors.Is(err, storage.ErrNotFound):
+				charge.Processor = &repo.ProcessorResponse{Code: "token_not_found", Message: "card of the token is not stored"}
+			case err != nil:
+				return nil, err
+			default:
+				intent, err := h.applyIntent(orderKey, trx)
+				if err != nil {
+					return nil, err
+				}
+				if intent.Status == repo.PendingTransactionStatus {
+					h.runProcessor(context.Background(), order, trx, cardOf(card))
+					charge.Processor = trx.Processor
+				}
+			}
+		}
+	}
+
+	charged, err := h.applySubscription("CHARGE_SUBSCRIPTION", charge.SubscriptionKey, charge)
+	if err != nil && charge.Processor != nil {
+		if err := h.applyRaft("SET_INTENT_OUTCOME", orderKey, trx); err != nil {
+			log.Printf("error keeping outcome of transaction %s of order %s on its intent: %s",
+				trx.ID, trx.OrderID, err.Error())
+		}
+	}
+	return charged, err
+}
@@ -1,33 +1,71 @@
 package store_router
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/KushnerykPavel/raft-test-project/internal/repo"
-	"github.com/dgraph-io/badger/v2"
+	"github.com/KushnerykPavel/raft-test-project/internal/storage"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/render"
 	"net/http"
+	"strconv"
+	"time"
 )
 
+const (
+	// maxStatusWait the longest a status request may wait for a change
+	maxStatusWait = time.Minute
+	// statusWriteSlack time left to write the response after the wait is over
+	statusWriteSlack = 3 * time.Second
+)
+
+// Status returns the order. With wait the request blocks until the order
+// is changed by a command past after_index or the wait is over, whichever
+// comes first. The response index is the after_index of the next watch.
+// from and to (RFC 3339) limit the transactions to the ones created in the range.
 func (h *Handler) Status(w http.ResponseWriter, r *http.Request) {
 	orderID := chi.URLParam(r, "order_id")
-	if orderID == "" {
-		render.Render(w, r, ErrInvalidRequest(errors.New("order_id must not be empty")))
+	errs := repo.ValidationErrors{}
+	errs.Add("order_id", repo.ValidateOrderID(orderID))
+
+	var (
+		wait       time.Duration
+		afterIndex uint64
+		err        error
+	)
+	if value := r.URL.Query().Get("wait"); value != "" {
+		wait, err = time.ParseDuration(value)
+		if err != nil || wait < 0 || wait > maxStatusWait {
+			errs.Add("wait", fmt.Errorf("must be a duration up to %s", maxStatusWait))
+		}
+	}
+	if value := r.URL.Query().Get("after_index"); value != "" {
+		afterIndex, err = strconv.ParseUint(value, 10, 64)
+		errs.Add("after_index", err)
+	}
+	from, to := parseTimeRange(r, errs)
+
+	if err := errs.Err(); err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
 		return
 	}
 
-	var keyByte = []byte(orderID)
+	merchant := merchantFromContext(r.Context())
 
-	txn := h.db.NewTransaction(false)
-	defer func() {
-		_ = txn.Commit()
-	}()
+	var changes chan *repo.Change
+	if wait > 0 {
+		// subscribe before reading the order, so a change in between is not missed
+		changes = h.feed.subscribe()
+		defer h.feed.unsubscribe(changes)
 
-	item, err := txn.Get(keyByte)
+		_ = http.NewResponseController(w).SetWriteDeadline(time.Now().Add(wait + statusWriteSlack))
+	}
+
+	data, err := h.watchOrder(r.Context(), repo.OrderKey(merchant.ID, orderID), wait, afterIndex, changes)
 	if err != nil {
-		if errors.Is(err, badger.ErrKeyNotFound) {
+		if errors.Is(err, storage.ErrNotFound) {
 			render.Render(w, r, ErrInvalidRequest(fmt.Errorf("key %s does not exists", orderID)))
 			return
 		}
@@ -35,31 +73,20 @@ func (h *Handler) Status(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var value = make([]byte, 0)
-	err = item.Value(func(val []byte) error {
-		value = append(value, val...)
-		return nil
-	})
-
-	if err != nil {
-		render.Render(w, r, ErrInvalidRequest(fmt.Errorf("error appending byte value of key %s from storage: %s", orderID, err.Error())))
-		return
-	}
-
-	var data []repo.Transaction
-	if value != nil && len(value) > 0 {
-		err = json.Unmarshal(value, &data)
-	}
-
-	if err != nil {
-		render.Render(w, r, ErrInvalidRequest(fmt.Errorf("error unmarshal data to interface: %s", err.Error())))
-		return
+	transactions := make([]repo.Transaction, 0, len(data.Transactions))
+	for _, trx := range data.Transactions {
+		if trx.CreatedWithin(from, to) {
+			transactions = append(transactions, trx)
+		}
 	}
 
 	response, _ := json.Marshal(map[string]interface{}{
 		"addr":         h.addr,
 		"order_id":     orderID,
-		"transactions": data,
+		"index":        data.Index,
+		"state":        data.State,
+		"history":      data.History,
+		"transactions": transactions,
 	})
 
 	w.Header().Set("Content-Type", "application/json")
@@ -67,3 +94,71 @@ func (h *Handler) Status(w http.ResponseWriter, r *http.Request) {
 
 	w.Write(response)
 }
+
+// watchOrder reads the order once it is changed past the index.
+// The order is read as is when the wait is over.
+func (h *Handler) watchOrder(ctx context.Context, key string, wait time.Duration, afterIndex uint64, changes <-chan *repo.Change) (*repo.Order, error) {
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+
+	for {
+		order, err := h.getOrder(key)
+		if err != nil && !errors.Is(err, storage.ErrNotFound) {
+			return nil, err
+		}
+		if wait == 0 || (err == nil && order.Index > afterIndex) {
+			return order, err
+		}
+
+		if !h.waitChange(ctx, timer.C, key, afterIndex, changes) {
+			// the wait is over, the order is read once more as is
+			wait = 0
+		}
+	}
+}
+
+// waitChange waits for a command past the index which changed the key.
+// It returns false when the wait is over.
+func (h *Handler) waitChange(ctx context.Context, timeout <-chan time.Time, key string, afterIndex uint64, changes <-chan *repo.Change) bool {
+	for {
+		select {
+		case <-ctx.Done():
+			return false
+		case <-timeout:
+			return false
+		case change, ok := <-changes:
+			if !ok {
+				// dropped by the feed for falling behind
+				return false
+			}
+			if change.Changed(key) && change.Index > afterIndex && change.Error == "" {
+				return true
+			}
+		}
+	}
+}
+
+// parseTimeRange reads RFC 3339 from and to query parameters, missing ones are zero
+func parseTimeRange(r *http.Request, errs repo.ValidationErrors) (from, to time.Time) {
+	for _, param := range []struct {
+		name  string
+		value *time.Time
+	}{{"from", &from}, {"to", &to}} {
+		value := r.URL.Query().Get(param.name)
+		if value == "" {
+			continue
+		}
+
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			errs.Add(param.name, errors.New("must be RFC 3339 time"))
+			continue
+		}
+		*param.value = t
+	}
+
+	if !from.IsZero() && !to.IsZero() && !from.Before(to) {
+		errs.Add("to", errors.New("must be after from"))
+	}
+	return from, to
+}
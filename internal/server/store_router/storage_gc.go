@@ -0,0 +1,114 @@
+package store_router
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/KushnerykPavel/raft-test-project/internal/repo"
+	"github.com/KushnerykPavel/raft-test-project/internal/storage"
+	"github.com/go-chi/render"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// RunStorageGC reclaims space of the node storage. Every node runs it over its own
+// data, the leader too: the storage is not replicated, only the log is.
+func (h *Handler) RunStorageGC(ctx context.Context) {
+	ticker := time.NewTicker(h.config.StorageGCInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		run, ok := h.collectGarbage(h.config.StorageGCDiscardRatio)
+		if !ok {
+			// an on-demand collection is running
+			continue
+		}
+		if run.Error != "" {
+			log.Printf("error collecting storage garbage: %s", run.Error)
+		}
+	}
+}
+
+// collectGarbage runs one collection. It returns false when another one is running.
+func (h *Handler) collectGarbage(discardRatio float64) (*repo.StorageGCRun, bool) {
+	collector, ok := h.store.(storage.Collector)
+	if !ok {
+		return &repo.StorageGCRun{Error: "storage engine has no garbage collection"}, true
+	}
+
+	if !h.gcMu.TryLock() {
+		return nil, false
+	}
+	defer h.gcMu.Unlock()
+
+	run := &repo.StorageGCRun{StartedAt: time.Now().UTC()}
+	reclaimed, err := collector.CollectGarbage(discardRatio)
+	run.Reclaimed = reclaimed
+	if err != nil {
+		run.Error = err.Error()
+	}
+
+	if h.config.LogCollector != nil && err == nil {
+		run.LogReclaimed, err = h.config.LogCollector.CollectGarbage(discardRatio)
+		if err != nil {
+			run.Error = fmt.Sprintf("raft log: %s", err)
+		}
+	}
+	run.Duration = time.Since(run.StartedAt).String()
+
+	h.lastGC.Store(run)
+	return run, true
+}
+
+// StorageGC collects garbage of the node storage right away.
+// discard_ratio overrides the configured one for this run.
+func (h *Handler) StorageGC(w http.ResponseWriter, r *http.Request) {
+	discardRatio := h.config.StorageGCDiscardRatio
+	if value := r.URL.Query().Get("discard_ratio"); value != "" {
+		ratio, err := strconv.ParseFloat(value, 64)
+		if err != nil || ratio <= 0 || ratio >= 1 {
+			render.Render(w, r, ErrInvalidRequest(repo.ValidationErrors{"discard_ratio": "must be between 0 and 1"}))
+			return
+		}
+		discardRatio = ratio
+	}
+
+	// rewriting value log files may outlive the server write timeout
+	_ = http.NewResponseController(w).SetWriteDeadline(time.Time{})
+
+	run, ok := h.collectGarbage(discardRatio)
+	if !ok {
+		render.Render(w, r, ErrConflict(errors.New("storage garbage collection is already running")))
+		return
+	}
+	if run.Error != "" {
+		render.Render(w, r, ErrInvalidRequest(fmt.Errorf("error collecting storage garbage: %s", run.Error)))
+		return
+	}
+
+	render.Status(r, http.StatusOK)
+	render.Render(w, r, run)
+}
+
+// StorageStats returns size of the node storage and the last garbage collection
+func (h *Handler) StorageStats(w http.ResponseWriter, r *http.Request) {
+	response := &repo.StorageResponse{
+		LastGC: h.lastGC.Load(),
+		Addr:   h.addr,
+	}
+	if sizer, ok := h.store.(storage.Sizer); ok {
+		size := sizer.Size()
+		response.Size = &size
+	}
+
+	render.Status(r, http.StatusOK)
+	render.Render(w, r, response)
+}
@@ -0,0 +1,174 @@
+package store_router
+
+import (
+	"errors"
+	"fmt"
+	"github.com/KushnerykPavel/raft-test-project/internal/repo"
+	"github.com/KushnerykPavel/raft-test-project/internal/storage"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+	"github.com/google/uuid"
+	"github.com/hashicorp/raft"
+	"net/http"
+	"time"
+)
+
+func ErrNotFound(err error) render.Renderer {
+	return &ErrResponse{
+		Err:            err,
+		HTTPStatusCode: http.StatusNotFound,
+		StatusText:     "Not found.",
+		ErrorText:      err.Error(),
+	}
+}
+
+// applySubscription applies subscription command and returns the subscription from the FSM
+func (h *Handler) applySubscription(operation, key string, value any) (*repo.Subscription, error) {
+	data, err := h.apply(operation, key, value)
+	if err != nil {
+		return nil, err
+	}
+
+	subscription, ok := data.(*repo.Subscription)
+	if !ok {
+		return nil, errors.New("error response is not a subscription")
+	}
+
+	return subscription, nil
+}
+
+// CreateSubscription schedules recurring charges of the token
+func (h *Handler) CreateSubscription(w http.ResponseWriter, r *http.Request) {
+	data := &repo.SubscriptionRequest{}
+	if err := render.Bind(r, data); err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+
+	if h.raft.State() != raft.Leader {
+		render.Render(w, r, ErrInvalidRequest(errors.New("node is not leader")))
+		return
+	}
+
+	merchant := merchantFromContext(r.Context())
+
+	var card repo.PayRequest
+	if err := h.get(repo.TokenKey(merchant.ID, data.Token), &card); err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			render.Render(w, r, ErrInvalidRequest(fmt.Errorf("key %s does not exists", data.Token)))
+			return
+		}
+		render.Render(w, r, ErrInvalidRequest(fmt.Errorf("error getting key %s from storage: %s", data.Token, err.Error())))
+		return
+	}
+
+	startAt := time.Now()
+	if data.StartAt != nil && data.StartAt.After(startAt) {
+		startAt = *data.StartAt
+	}
+
+	dunning := h.dunningPolicy()
+	subscription := &repo.Subscription{
+		ID:         uuid.New().String(),
+		MerchantID: merchant.ID,
+		Token:      data.Token,
+		OrderID:    data.OrderID,
+		Amount:     data.AmountMinor,
+		Currency:   data.Currency,
+		Interval:   data.Interval,
+		Status:     repo.ActiveSubscriptionStatus,
+		NextRunAt:  startAt.Unix(),
+		CycleAt:    startAt.Unix(),
+		Dunning:    &dunning,
+	}
+	if data.EndAt != nil {
+		subscription.EndAt = data.EndAt.Unix()
+	}
+
+	key := repo.SubscriptionKey(merchant.ID, subscription.ID)
+	subscription, err := h.applySubscription("SET_SUBSCRIPTION", key, subscription)
+	if err != nil {
+		render.Render(w, r, ErrApply(err))
+		return
+	}
+
+	render.Status(r, http.StatusCreated)
+	render.Render(w, r, &repo.SubscriptionResponse{Subscription: subscription, Addr: h.addr})
+}
+
+// GetSubscription returns the subscription of the merchant
+func (h *Handler) GetSubscription(w http.ResponseWriter, r *http.Request) {
+	subscriptionID := chi.URLParam(r, "subscription_id")
+	merchant := merchantFromContext(r.Context())
+
+	var subscription repo.Subscription
+	if err := h.get(repo.SubscriptionKey(merchant.ID, subscriptionID), &subscription); err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			render.Render(w, r, ErrNotFound(fmt.Errorf("subscription %s does not exists", subscriptionID)))
+			return
+		}
+		render.Render(w, r, ErrInvalidRequest(fmt.Errorf("error getting subscription %s from storage: %s", subscriptionID, err.Error())))
+		return
+	}
+
+	render.Status(r, http.StatusOK)
+	render.Render(w, r, &repo.SubscriptionResponse{Subscription: &subscription, Addr: h.addr})
+}
+
+// SubscriptionDunning returns retry state of failed charges of the subscription
+func (h *Handler) SubscriptionDunning(w http.ResponseWriter, r *http.Request) {
+	subscriptionID := chi.URLParam(r, "subscription_id")
+	merchant := merchantFromContext(r.Context())
+
+	var subscription repo.Subscription
+	if err := h.get(repo.SubscriptionKey(merchant.ID, subscriptionID), &subscription); err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			render.Render(w, r, ErrNotFound(fmt.Errorf("subscription %s does not exists", subscriptionID)))
+			return
+		}
+		render.Render(w, r, ErrInvalidRequest(fmt.Errorf("error getting subscription %s from storage: %s", subscriptionID, err.Error())))
+		return
+	}
+
+	response := &repo.DunningResponse{
+		SubscriptionID: subscription.ID,
+		Status:         subscription.Status,
+		Failures:       subscription.Failures,
+		MaxFailures:    subscription.DunningPolicy(h.dunningPolicy()).MaxFailures,
+		LastFailure:    subscription.LastFailure,
+		LastFailureAt:  subscription.LastFailureAt,
+		Addr:           h.addr,
+	}
+	if subscription.Status == repo.PastDueSubscriptionStatus {
+		response.NextRetryAt = subscription.NextRunAt
+	}
+
+	render.Status(r, http.StatusOK)
+	render.Render(w, r, response)
+}
+
+// CancelSubscription stops further charges of the subscription
+func (h *Handler) CancelSubscription(w http.ResponseWriter, r *http.Request) {
+	subscriptionID := chi.URLParam(r, "subscription_id")
+
+	if h.raft.State() != raft.Leader {
+		render.Render(w, r, ErrInvalidRequest(errors.New("node is not leader")))
+		return
+	}
+
+	merchant := merchantFromContext(r.Context())
+	key := repo.SubscriptionKey(merchant.ID, subscriptionID)
+
+	subscription, err := h.applySubscription("CANCEL_SUBSCRIPTION", key, nil)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			render.Render(w, r, ErrNotFound(fmt.Errorf("subscription %s does not exists", subscriptionID)))
+			return
+		}
+		render.Render(w, r, ErrApply(err))
+		return
+	}
+
+	render.Status(r, http.StatusOK)
+	render.Render(w, r, &repo.SubscriptionResponse{Subscription: subscription, Addr: h.addr})
+}
@@ -0,0 +1,73 @@
+package store_router
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/KushnerykPavel/raft-test-project/internal/repo"
+	"github.com/hashicorp/raft"
+	"log"
+	"time"
+)
+
+// SweepAuthorizations expires authorizations which were not captured in time.
+// It is started on every node, but does the work only while the node is the leader.
+func (h *Handler) SweepAuthorizations(ctx context.Context) {
+	ticker := time.NewTicker(h.config.AuthorizationSweepInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if h.raft.State() != raft.Leader {
+			continue
+		}
+
+		if err := h.expireAuthorizations(time.Now()); err != nil {
+			log.Printf("error sweeping authorizations: %s", err.Error())
+		}
+	}
+}
+
+// expireAuthorizations applies expiration of every authorization due at now.
+// The expiration id is derived from the authorization, so a sweep repeated
+// by a new leader is deduplicated by the FSM.
+func (h *Handler) expireAuthorizations(now time.Time) error {
+	var due []repo.AuthorizationIndex
+
+	err := h.store.Scan(repo.AuthorizationIndexPrefix, "", func(_ string, val []byte) error {
+		var index repo.AuthorizationIndex
+		if err := json.Unmarshal(val, &index); err != nil {
+			return err
+		}
+
+		if index.ExpiresAt <= now.Unix() {
+			due = append(due, index)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, index := range due {
+		transaction := &repo.Transaction{
+			ID:              index.TransactionID + "-expiration",
+			OrderID:         index.OrderID,
+			Type:            repo.ExpirationTransactionType,
+			Status:          repo.SucceededTransactionStatus,
+			AuthorizationID: index.TransactionID,
+		}
+
+		if _, err := h.processTransaction(context.Background(), index.OrderKey, transaction, nil); err != nil {
+			log.Printf("error expiring authorization %s of order %s: %s", index.TransactionID, index.OrderID, err.Error())
+			continue
+		}
+		log.Printf("authorization %s of order %s expired", index.TransactionID, index.OrderID)
+	}
+
+	return nil
+}
@@ -0,0 +1,165 @@
+package store_router
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/KushnerykPavel/raft-test-project/internal/repo"
+	"github.com/KushnerykPavel/raft-test-project/internal/storage"
+	"github.com/go-chi/render"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultTransactionsLimit = 50
+	maxTransactionsLimit     = 500
+)
+
+// transactionsQuery filters of the transaction list
+type transactionsQuery struct {
+	token    string
+	currency string
+	trxType  repo.TransactionType
+	status   repo.TransactionStatus
+	from, to time.Time
+	limit    int
+	cursor   string
+}
+
+// ListTransactions returns transactions of the merchant ordered by the creation time.
+// The index is picked by the filters: token, then currency, then all transactions of
+// the merchant. next_cursor is passed as cursor to read the next page.
+func (h *Handler) ListTransactions(w http.ResponseWriter, r *http.Request) {
+	merchant := merchantFromContext(r.Context())
+
+	query, err := parseTransactionsQuery(r)
+	if err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+
+	prefix := repo.TransactionsByCreatedPrefix(merchant.ID)
+	switch {
+	case query.token != "":
+		prefix = repo.TransactionsByTokenPrefix(merchant.ID, query.token)
+	case query.currency != "":
+		prefix = repo.TransactionsByCurrencyPrefix(merchant.ID, query.currency)
+	}
+
+	start := prefix
+	if !query.from.IsZero() {
+		start = fmt.Sprintf("%s%020d", prefix, query.from.Unix())
+	}
+	if query.cursor != "" {
+		if !strings.HasPrefix(query.cursor, prefix) {
+			render.Render(w, r, ErrInvalidRequest(repo.ValidationErrors{"cursor": "does not belong to the filters"}))
+			return
+		}
+		// the page starts right after the last key of the previous one
+		start = query.cursor + "\x00"
+	}
+
+	var end string
+	if !query.to.IsZero() {
+		end = fmt.Sprintf("%s%020d", prefix, query.to.Unix())
+	}
+
+	response := &repo.TransactionsResponse{Transactions: make([]repo.Transaction, 0), Addr: h.addr}
+
+	err = storage.View(h.store, func(reader storage.Reader) error {
+		var lastKey string
+		return reader.Scan(prefix, start, func(key string, val []byte) error {
+			if end != "" && key >= end {
+				return storage.ErrStop
+			}
+			if len(response.Transactions) == query.limit {
+				response.NextCursor = base64.RawURLEncoding.EncodeToString([]byte(lastKey))
+				return storage.ErrStop
+			}
+			lastKey = key
+
+			var id string
+			if err := json.Unmarshal(val, &id); err != nil {
+				return err
+			}
+
+			value, err := reader.Get(id)
+			if err != nil {
+				return fmt.Errorf("error getting transaction %s: %w", id, err)
+			}
+			var trx repo.Transaction
+			if err := json.Unmarshal(value, &trx); err != nil {
+				return err
+			}
+
+			if query.match(&trx) {
+				response.Transactions = append(response.Transactions, trx)
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		render.Render(w, r, ErrInvalidRequest(fmt.Errorf("error reading transactions: %s", err.Error())))
+		return
+	}
+
+	render.Status(r, http.StatusOK)
+	render.Render(w, r, response)
+}
+
+// match applies the filters not covered by the index
+func (q *transactionsQuery) match(trx *repo.Transaction) bool {
+	if q.currency != "" && trx.Currency != q.currency {
+		return false
+	}
+	if q.trxType != "" && trx.Type != q.trxType {
+		return false
+	}
+	if q.status != "" && trx.Status != q.status {
+		return false
+	}
+	return true
+}
+
+func parseTransactionsQuery(r *http.Request) (*transactionsQuery, error) {
+	values := r.URL.Query()
+	errs := repo.ValidationErrors{}
+
+	query := &transactionsQuery{
+		token:    values.Get("token"),
+		currency: values.Get("currency"),
+		trxType:  repo.TransactionType(values.Get("type")),
+		status:   repo.TransactionStatus(values.Get("status")),
+		limit:    defaultTransactionsLimit,
+	}
+
+	if query.token != "" {
+		errs.Add("token", repo.ValidateToken(query.token))
+	}
+	if query.currency != "" {
+		errs.Add("currency", repo.ValidateCurrency(query.currency))
+	}
+	query.from, query.to = parseTimeRange(r, errs)
+
+	if value := values.Get("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 1 || limit > maxTransactionsLimit {
+			errs.Add("limit", fmt.Errorf("must be between 1 and %d", maxTransactionsLimit))
+		}
+		query.limit = limit
+	}
+
+	if value := values.Get("cursor"); value != "" {
+		cursor, err := base64.RawURLEncoding.DecodeString(value)
+		if err != nil {
+			errs.Add("cursor", errors.New("is malformed"))
+		}
+		query.cursor = string(cursor)
+	}
+
+	return query, errs.Err()
+}
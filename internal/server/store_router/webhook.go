@@ -0,0 +1,109 @@
+package store_router
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/KushnerykPavel/raft-test-project/internal/repo"
+	"github.com/KushnerykPavel/raft-test-project/internal/storage"
+	"github.com/go-chi/render"
+	"github.com/hashicorp/raft"
+	"net/http"
+)
+
+// maxDeliveries number of the latest delivery attempts returned by the delivery log
+const maxDeliveries = 100
+
+// SetWebhook sets the endpoint events of the merchant are delivered to.
+// A new signing secret is generated every time, it is shown only in this response.
+func (h *Handler) SetWebhook(w http.ResponseWriter, r *http.Request) {
+	data := &repo.WebhookRequest{}
+	if err := render.Bind(r, data); err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+
+	if h.raft.State() != raft.Leader {
+		render.Render(w, r, ErrInvalidRequest(errors.New("node is not leader")))
+		return
+	}
+
+	merchant := merchantFromContext(r.Context())
+
+	secret, err := repo.NewWebhookSecret()
+	if err != nil {
+		render.Render(w, r, ErrInvalidRequest(fmt.Errorf("error generating webhook secret: %s", err.Error())))
+		return
+	}
+
+	endpoint := &repo.WebhookEndpoint{URL: data.URL, Secret: secret}
+	if err := h.applyRaft("SET_WEBHOOK", repo.WebhookKey(merchant.ID), endpoint); err != nil {
+		render.Render(w, r, ErrApply(err))
+		return
+	}
+
+	render.Status(r, http.StatusOK)
+	render.Render(w, r, &repo.WebhookResponse{URL: endpoint.URL, Secret: endpoint.Secret, Addr: h.addr})
+}
+
+// GetWebhook returns the endpoint of the merchant without the secret
+func (h *Handler) GetWebhook(w http.ResponseWriter, r *http.Request) {
+	merchant := merchantFromContext(r.Context())
+
+	var endpoint repo.WebhookEndpoint
+	if err := h.get(repo.WebhookKey(merchant.ID), &endpoint); err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			render.Render(w, r, ErrNotFound(errors.New("webhook endpoint is not set")))
+			return
+		}
+		render.Render(w, r, ErrInvalidRequest(fmt.Errorf("error getting webhook endpoint from storage: %s", err.Error())))
+		return
+	}
+
+	render.Status(r, http.StatusOK)
+	render.Render(w, r, &repo.WebhookResponse{URL: endpoint.URL, Addr: h.addr})
+}
+
+// DeleteWebhook stops writing events of the merchant into the outbox.
+// Events already in the outbox fail on the next attempt.
+func (h *Handler) DeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	if h.raft.State() != raft.Leader {
+		render.Render(w, r, ErrInvalidRequest(errors.New("node is not leader")))
+		return
+	}
+
+	merchant := merchantFromContext(r.Context())
+
+	if err := h.applyRaft("DELETE", repo.WebhookKey(merchant.ID), nil); err != nil {
+		render.Render(w, r, ErrApply(err))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// WebhookDeliveries returns the latest delivery attempts of the merchant, newest first
+func (h *Handler) WebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	merchant := merchantFromContext(r.Context())
+
+	deliveries := make([]repo.WebhookDelivery, 0)
+	err := h.store.ScanReverse(repo.DeliveryPrefix(merchant.ID), "", func(_ string, val []byte) error {
+		if len(deliveries) == maxDeliveries {
+			return storage.ErrStop
+		}
+
+		var delivery repo.WebhookDelivery
+		if err := json.Unmarshal(val, &delivery); err != nil {
+			return err
+		}
+		deliveries = append(deliveries, delivery)
+		return nil
+	})
+	if err != nil {
+		render.Render(w, r, ErrInvalidRequest(fmt.Errorf("error reading delivery log: %s", err.Error())))
+		return
+	}
+
+	render.Status(r, http.StatusOK)
+	render.Render(w, r, &repo.DeliveriesResponse{Deliveries: deliveries, Addr: h.addr})
+}
@@ -0,0 +1,179 @@
+package store_router
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/KushnerykPavel/raft-test-project/internal/repo"
+	"github.com/KushnerykPavel/raft-test-project/internal/storage"
+	"github.com/hashicorp/raft"
+	"io"
+	"log"
+	"net/http"
+	"time"
+)
+
+const (
+	// maxWebhookBackoff the longest delay between delivery attempts
+	maxWebhookBackoff = time.Hour
+	// webhookBatchSize events delivered per tick at most
+	webhookBatchSize = 100
+)
+
+// Headers of the webhook request. The signature header is
+// "t=<unix time>,v1=<hex HMAC-SHA256 of '<unix time>.<body>'>".
+const (
+	webhookIDHeader        = "Webhook-Id"
+	webhookEventHeader     = "Webhook-Event"
+	webhookSignatureHeader = "Webhook-Signature"
+)
+
+// RunWebhooks delivers events of the outbox to the merchant endpoints.
+// It is started on every node, but does the work only while the node is the leader.
+// Delivery is at least once: a new leader repeats the attempt the previous one
+// made but didn't manage to record.
+func (h *Handler) RunWebhooks(ctx context.Context) {
+	ticker := time.NewTicker(h.config.WebhookInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if h.raft.State() != raft.Leader {
+			continue
+		}
+
+		if err := h.deliverEvents(ctx, time.Now()); err != nil {
+			log.Printf("error delivering webhooks: %s", err.Error())
+		}
+	}
+}
+
+// outboxItem event of the outbox with its key
+type outboxItem struct {
+	key   string
+	entry repo.OutboxEntry
+}
+
+// deliverEvents makes an attempt for every event due at now
+func (h *Handler) deliverEvents(ctx context.Context, now time.Time) error {
+	var due []outboxItem
+
+	err := h.store.Scan(repo.OutboxPrefix, "", func(key string, val []byte) error {
+		if len(due) == webhookBatchSize {
+			return storage.ErrStop
+		}
+
+		var entry repo.OutboxEntry
+		if err := json.Unmarshal(val, &entry); err != nil {
+			return err
+		}
+
+		if entry.NextAttemptAt <= now.Unix() {
+			due = append(due, outboxItem{key: key, entry: entry})
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, item := range due {
+		attempt := h.deliverEvent(ctx, &item.entry)
+		attempt.OutboxKey = item.key
+
+		if err := h.applyRaft("WEBHOOK_ATTEMPT", item.key, attempt); err != nil {
+			log.Printf("error recording delivery of event %s: %s", item.entry.Event.ID, err.Error())
+			continue
+		}
+		log.Printf("webhook %s of event %s attempt %d: %s", item.entry.Event.Type, item.entry.Event.ID, attempt.Attempt, attempt.Status)
+	}
+
+	return nil
+}
+
+// deliverEvent posts the event to the merchant endpoint and returns the outcome
+func (h *Handler) deliverEvent(ctx context.Context, entry *repo.OutboxEntry) *repo.WebhookAttempt {
+	now := time.Now()
+	attempt := &repo.WebhookAttempt{
+		Attempt:     entry.Attempts + 1,
+		AttemptedAt: now.Unix(),
+	}
+
+	statusCode, err := h.postEvent(ctx, attempt, &entry.Event, now)
+	attempt.StatusCode = statusCode
+
+	switch {
+	case err == nil:
+		attempt.Status = repo.DeliveredDeliveryStatus
+	case errors.Is(err, storage.ErrNotFound):
+		attempt.Status = repo.FailedDeliveryStatus
+		attempt.Error = "webhook endpoint is not set"
+	case attempt.Attempt >= h.config.WebhookMaxAttempts:
+		attempt.Status = repo.FailedDeliveryStatus
+		attempt.Error = err.Error()
+	default:
+		attempt.Status = repo.RetryingDeliveryStatus
+		attempt.Error = err.Error()
+		attempt.NextAttemptAt = now.Add(h.webhookBackoff(attempt.Attempt)).Unix()
+	}
+
+	return attempt
+}
+
+// postEvent sends the signed event, any status but 2xx is an error
+func (h *Handler) postEvent(ctx context.Context, attempt *repo.WebhookAttempt, event *repo.Event, now time.Time) (int, error) {
+	var endpoint repo.WebhookEndpoint
+	if err := h.get(repo.WebhookKey(event.MerchantID), &endpoint); err != nil {
+		return 0, err
+	}
+	attempt.URL = endpoint.URL
+
+	body, err := json.Marshal(event)
+	if err != nil {
+		return 0, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, h.config.WebhookTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(webhookIDHeader, event.ID)
+	req.Header.Set(webhookEventHeader, string(event.Type))
+	req.Header.Set(webhookSignatureHeader,
+		fmt.Sprintf("t=%d,v1=%s", now.Unix(), repo.SignWebhook(endpoint.Secret, now.Unix(), body)))
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("endpoint responded with %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+// webhookBackoff delay after the failed attempt, doubled with every attempt
+func (h *Handler) webhookBackoff(attempt int) time.Duration {
+	backoff := h.config.WebhookBackoff
+	for i := 1; i < attempt && backoff < maxWebhookBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > maxWebhookBackoff {
+		return maxWebhookBackoff
+	}
+	return backoff
+}
@@ -0,0 +1,214 @@
+package storage
+
+import (
+	"errors"
+	"github.com/dgraph-io/badger/v2"
+	"io/fs"
+	"path/filepath"
+	"time"
+)
+
+// Badger store on badger, expiration of keys is done by badger itself
+type Badger struct {
+	db  *badger.DB
+	dir string
+}
+
+// OpenBadger opens badger database in the directory
+func OpenBadger(dir string) (*Badger, error) {
+	db, err := badger.Open(badger.DefaultOptions(dir))
+	if err != nil {
+		return nil, err
+	}
+	return &Badger{db: db, dir: dir}, nil
+}
+
+func (s *Badger) Get(key string) ([]byte, error) {
+	var value []byte
+	err := s.db.View(func(txn *badger.Txn) error {
+		var err error
+		value, err = badgerReader{txn: txn}.Get(key)
+		return err
+	})
+	return value, err
+}
+
+func (s *Badger) Scan(prefix, start string, fn ScanFunc) error {
+	return s.db.View(func(txn *badger.Txn) error {
+		return badgerReader{txn: txn}.Scan(prefix, start, fn)
+	})
+}
+
+func (s *Badger) ScanReverse(prefix, start string, fn ScanFunc) error {
+	return s.db.View(func(txn *badger.Txn) error {
+		return badgerReader{txn: txn}.ScanReverse(prefix, start, fn)
+	})
+}
+
+func (s *Badger) Put(key string, value []byte) error {
+	return s.Update(func(w Writer) error {
+		return w.Put(key, value)
+	})
+}
+
+func (s *Badger) PutWithTTL(key string, value []byte, ttl time.Duration) error {
+	return s.Update(func(w Writer) error {
+		return w.PutWithTTL(key, value, ttl)
+	})
+}
+
+func (s *Badger) Delete(key string) error {
+	return s.Update(func(w Writer) error {
+		return w.Delete(key)
+	})
+}
+
+func (s *Badger) Update(fn func(w Writer) error) error {
+	return s.db.Update(func(txn *badger.Txn) error {
+		return fn(badgerWriter{txn: txn})
+	})
+}
+
+func (s *Badger) Snapshot() (Snapshot, error) {
+	return badgerReader{txn: s.db.NewTransaction(false)}, nil
+}
+
+func (s *Badger) DropAll() error {
+	return s.db.DropAll()
+}
+
+// CollectGarbage rewrites value log files until none has enough stale data
+func (s *Badger) CollectGarbage(discardRatio float64) (int, error) {
+	rewritten := 0
+	for {
+		err := s.db.RunValueLogGC(discardRatio)
+		if errors.Is(err, badger.ErrNoRewrite) {
+			return rewritten, nil
+		}
+		if err != nil {
+			return rewritten, err
+		}
+		rewritten++
+	}
+}
+
+// Size of the LSM tree and the value log files. Badger refreshes its own
+// numbers once a minute, the files are read instead. Subdirectories, such as
+// the badger raft log in the volume directory, are not counted.
+func (s *Badger) Size() Size {
+	var size Size
+	_ = filepath.WalkDir(s.dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if d.IsDir() {
+			if path != s.dir {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+
+		switch filepath.Ext(path) {
+		case ".sst":
+			size.LSM += info.Size()
+		case ".vlog":
+			size.ValueLog += info.Size()
+		}
+		return nil
+	})
+	return size
+}
+
+func (s *Badger) Close() error {
+	return s.db.Close()
+}
+
+// badgerReader reads keys in a badger transaction
+type badgerReader struct {
+	txn *badger.Txn
+}
+
+func (r badgerReader) Get(key string) ([]byte, error) {
+	item, err := r.txn.Get([]byte(key))
+	if errors.Is(err, badger.ErrKeyNotFound) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return item.ValueCopy(nil)
+}
+
+func (r badgerReader) Scan(prefix, start string, fn ScanFunc) error {
+	return r.scan(prefix, start, false, fn)
+}
+
+func (r badgerReader) ScanReverse(prefix, start string, fn ScanFunc) error {
+	return r.scan(prefix, start, true, fn)
+}
+
+func (r badgerReader) scan(prefix, start string, reverse bool, fn ScanFunc) error {
+	opts := badger.DefaultIteratorOptions
+	opts.Reverse = reverse
+	it := r.txn.NewIterator(opts)
+	defer it.Close()
+
+	for it.Seek([]byte(scanStart(prefix, start, reverse))); it.ValidForPrefix([]byte(prefix)); it.Next() {
+		key := string(it.Item().Key())
+		err := it.Item().Value(func(val []byte) error {
+			return fn(key, val)
+		})
+		if err != nil {
+			return stopped(err)
+		}
+	}
+	return nil
+}
+
+func (r badgerReader) Entries(fn EntryFunc) error {
+	it := r.txn.NewIterator(badger.DefaultIteratorOptions)
+	defer it.Close()
+
+	for it.Rewind(); it.Valid(); it.Next() {
+		item := it.Item()
+		key := string(item.Key())
+
+		var expiresAt time.Time
+		if item.ExpiresAt() != 0 {
+			expiresAt = time.Unix(int64(item.ExpiresAt()), 0)
+		}
+		err := item.Value(func(val []byte) error {
+			return fn(key, val, expiresAt)
+		})
+		if err != nil {
+			return stopped(err)
+		}
+	}
+	return nil
+}
+
+// Release discards the read transaction
+func (r badgerReader) Release() {
+	r.txn.Discard()
+}
+
+// badgerWriter writes keys in a badger transaction
+type badgerWriter struct {
+	txn *badger.Txn
+}
+
+func (w badgerWriter) Put(key string, value []byte) error {
+	return w.txn.Set([]byte(key), value)
+}
+
+func (w badgerWriter) PutWithTTL(key string, value []byte, ttl time.Duration) error {
+	return w.txn.SetEntry(badger.NewEntry([]byte(key), value).WithTTL(ttl))
+}
+
+func (w badgerWriter) Delete(key string) error {
+	return w.txn.Delete([]byte(key))
+}
@@ -0,0 +1,257 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/binary"
+	"github.com/boltdb/bolt"
+	"path/filepath"
+	"time"
+)
+
+// boltFile name of the database file in the directory
+const boltFile = "fsm.db"
+
+// boltBucket bucket of all the keys
+var boltBucket = []byte("fsm")
+
+// Bolt store on BoltDB. Bolt has no expiration, values are prefixed with
+// their expiration time and expired keys are skipped by reads.
+type Bolt struct {
+	db *bolt.DB
+}
+
+// OpenBolt opens bolt database in the directory
+func OpenBolt(dir string) (*Bolt, error) {
+	db, err := bolt.Open(filepath.Join(dir, boltFile), 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(boltBucket)
+		return err
+	})
+	if err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+
+	return &Bolt{db: db}, nil
+}
+
+func (s *Bolt) Get(key string) ([]byte, error) {
+	var value []byte
+	err := s.db.View(func(tx *bolt.Tx) error {
+		var err error
+		value, err = boltReader{tx: tx}.Get(key)
+		return err
+	})
+	return value, err
+}
+
+func (s *Bolt) Scan(prefix, start string, fn ScanFunc) error {
+	return s.db.View(func(tx *bolt.Tx) error {
+		return boltReader{tx: tx}.Scan(prefix, start, fn)
+	})
+}
+
+func (s *Bolt) ScanReverse(prefix, start string, fn ScanFunc) error {
+	return s.db.View(func(tx *bolt.Tx) error {
+		return boltReader{tx: tx}.ScanReverse(prefix, start, fn)
+	})
+}
+
+func (s *Bolt) Put(key string, value []byte) error {
+	return s.Update(func(w Writer) error {
+		return w.Put(key, value)
+	})
+}
+
+func (s *Bolt) PutWithTTL(key string, value []byte, ttl time.Duration) error {
+	return s.Update(func(w Writer) error {
+		return w.PutWithTTL(key, value, ttl)
+	})
+}
+
+func (s *Bolt) Delete(key string) error {
+	return s.Update(func(w Writer) error {
+		return w.Delete(key)
+	})
+}
+
+func (s *Bolt) Update(fn func(w Writer) error) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return fn(boltWriter{bucket: tx.Bucket(boltBucket)})
+	})
+}
+
+// Snapshot holds a read transaction, writes which grow the file wait until it is released
+func (s *Bolt) Snapshot() (Snapshot, error) {
+	tx, err := s.db.Begin(false)
+	if err != nil {
+		return nil, err
+	}
+	return boltReader{tx: tx}, nil
+}
+
+func (s *Bolt) DropAll() error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		if err := tx.DeleteBucket(boltBucket); err != nil {
+			return err
+		}
+		_, err := tx.CreateBucket(boltBucket)
+		return err
+	})
+}
+
+// CollectGarbage deletes expired keys, bolt reuses their pages on its own
+func (s *Bolt) CollectGarbage(_ float64) (int, error) {
+	deleted := 0
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		var expired [][]byte
+		now := time.Now()
+
+		err := tx.Bucket(boltBucket).ForEach(func(k, v []byte) error {
+			if _, ok := unwrapBolt(v, now); !ok {
+				expired = append(expired, append([]byte(nil), k...))
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, k := range expired {
+			if err := tx.Bucket(boltBucket).Delete(k); err != nil {
+				return err
+			}
+		}
+		deleted = len(expired)
+		return nil
+	})
+	return deleted, err
+}
+
+func (s *Bolt) Close() error {
+	return s.db.Close()
+}
+
+// boltReader reads keys in a bolt transaction
+type boltReader struct {
+	tx *bolt.Tx
+}
+
+func (r boltReader) Get(key string) ([]byte, error) {
+	raw := r.tx.Bucket(boltBucket).Get([]byte(key))
+	if raw == nil {
+		return nil, ErrNotFound
+	}
+
+	value, ok := unwrapBolt(raw, time.Now())
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return append([]byte(nil), value...), nil
+}
+
+func (r boltReader) Scan(prefix, start string, fn ScanFunc) error {
+	c := r.tx.Bucket(boltBucket).Cursor()
+	now := time.Now()
+
+	for k, v := c.Seek([]byte(scanStart(prefix, start, false))); k != nil && bytes.HasPrefix(k, []byte(prefix)); k, v = c.Next() {
+		value, ok := unwrapBolt(v, now)
+		if !ok {
+			continue
+		}
+		if err := fn(string(k), value); err != nil {
+			return stopped(err)
+		}
+	}
+	return nil
+}
+
+func (r boltReader) ScanReverse(prefix, start string, fn ScanFunc) error {
+	c := r.tx.Bucket(boltBucket).Cursor()
+	now := time.Now()
+	from := []byte(scanStart(prefix, start, true))
+
+	// seek finds the first key not before the start, the scan begins at the one before it
+	k, v := c.Seek(from)
+	switch {
+	case k == nil:
+		k, v = c.Last()
+	case !bytes.Equal(k, from):
+		k, v = c.Prev()
+	}
+
+	for ; k != nil && bytes.HasPrefix(k, []byte(prefix)); k, v = c.Prev() {
+		value, ok := unwrapBolt(v, now)
+		if !ok {
+			continue
+		}
+		if err := fn(string(k), value); err != nil {
+			return stopped(err)
+		}
+	}
+	return nil
+}
+
+func (r boltReader) Entries(fn EntryFunc) error {
+	now := time.Now()
+	return stopped(r.tx.Bucket(boltBucket).ForEach(func(k, v []byte) error {
+		value, ok := unwrapBolt(v, now)
+		if !ok {
+			return nil
+		}
+
+		var expiresAt time.Time
+		if nanos := binary.BigEndian.Uint64(v); nanos != 0 {
+			expiresAt = time.Unix(0, int64(nanos))
+		}
+		return fn(string(k), value, expiresAt)
+	}))
+}
+
+// Release rolls back the read transaction
+func (r boltReader) Release() {
+	_ = r.tx.Rollback()
+}
+
+// boltWriter writes keys in a bolt transaction
+type boltWriter struct {
+	bucket *bolt.Bucket
+}
+
+func (w boltWriter) Put(key string, value []byte) error {
+	return w.bucket.Put([]byte(key), wrapBolt(value, time.Time{}))
+}
+
+func (w boltWriter) PutWithTTL(key string, value []byte, ttl time.Duration) error {
+	return w.bucket.Put([]byte(key), wrapBolt(value, time.Now().Add(ttl)))
+}
+
+func (w boltWriter) Delete(key string) error {
+	return w.bucket.Delete([]byte(key))
+}
+
+// wrapBolt prefixes the value with its expiration in unix nanoseconds, zero never expires
+func wrapBolt(value []byte, expiresAt time.Time) []byte {
+	raw := make([]byte, 8+len(value))
+	if !expiresAt.IsZero() {
+		binary.BigEndian.PutUint64(raw, uint64(expiresAt.UnixNano()))
+	}
+	copy(raw[8:], value)
+	return raw
+}
+
+// unwrapBolt returns the value which is not expired at the time
+func unwrapBolt(raw []byte, now time.Time) ([]byte, bool) {
+	if len(raw) < 8 {
+		return nil, false
+	}
+	expiresAt := binary.BigEndian.Uint64(raw)
+	if expiresAt != 0 && int64(expiresAt) <= now.UnixNano() {
+		return nil, false
+	}
+	return raw[8:], true
+}
@@ -0,0 +1,203 @@
+package storage
+
+import (
+	"bytes"
+	iradix "github.com/hashicorp/go-immutable-radix"
+	"sync"
+	"time"
+)
+
+// Memory store kept in memory, for fast clusters in tests. The state is lost
+// when the process stops. The tree is immutable, so a snapshot is just its root.
+type Memory struct {
+	// mu serializes updates, readers take the current tree
+	mu   sync.Mutex
+	tree *iradix.Tree
+}
+
+// memoryEntry value with its expiration, zero never expires
+type memoryEntry struct {
+	value     []byte
+	expiresAt time.Time
+}
+
+func (e *memoryEntry) expired(now time.Time) bool {
+	return !e.expiresAt.IsZero() && !e.expiresAt.After(now)
+}
+
+func NewMemory() *Memory {
+	return &Memory{tree: iradix.New()}
+}
+
+// root current tree
+func (s *Memory) root() *iradix.Tree {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.tree
+}
+
+func (s *Memory) Get(key string) ([]byte, error) {
+	return memoryReader{tree: s.root()}.Get(key)
+}
+
+func (s *Memory) Scan(prefix, start string, fn ScanFunc) error {
+	return memoryReader{tree: s.root()}.Scan(prefix, start, fn)
+}
+
+func (s *Memory) ScanReverse(prefix, start string, fn ScanFunc) error {
+	return memoryReader{tree: s.root()}.ScanReverse(prefix, start, fn)
+}
+
+func (s *Memory) Put(key string, value []byte) error {
+	return s.Update(func(w Writer) error {
+		return w.Put(key, value)
+	})
+}
+
+func (s *Memory) PutWithTTL(key string, value []byte, ttl time.Duration) error {
+	return s.Update(func(w Writer) error {
+		return w.PutWithTTL(key, value, ttl)
+	})
+}
+
+func (s *Memory) Delete(key string) error {
+	return s.Update(func(w Writer) error {
+		return w.Delete(key)
+	})
+}
+
+func (s *Memory) Update(fn func(w Writer) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	txn := s.tree.Txn()
+	if err := fn(memoryWriter{txn: txn}); err != nil {
+		return err
+	}
+	s.tree = txn.Commit()
+	return nil
+}
+
+func (s *Memory) Snapshot() (Snapshot, error) {
+	return memoryReader{tree: s.root()}, nil
+}
+
+func (s *Memory) DropAll() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.tree = iradix.New()
+	return nil
+}
+
+// CollectGarbage deletes expired keys
+func (s *Memory) CollectGarbage(_ float64) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	txn := s.tree.Txn()
+	deleted := 0
+	s.tree.Root().Walk(func(k []byte, v interface{}) bool {
+		if v.(*memoryEntry).expired(now) {
+			txn.Delete(k)
+			deleted++
+		}
+		return false
+	})
+	s.tree = txn.Commit()
+	return deleted, nil
+}
+
+func (s *Memory) Close() error {
+	return nil
+}
+
+// memoryReader reads keys of a tree
+type memoryReader struct {
+	tree *iradix.Tree
+}
+
+func (r memoryReader) Get(key string) ([]byte, error) {
+	v, ok := r.tree.Get([]byte(key))
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	entry := v.(*memoryEntry)
+	if entry.expired(time.Now()) {
+		return nil, ErrNotFound
+	}
+	return append([]byte(nil), entry.value...), nil
+}
+
+func (r memoryReader) Scan(prefix, start string, fn ScanFunc) error {
+	it := r.tree.Root().Iterator()
+	it.SeekLowerBound([]byte(scanStart(prefix, start, false)))
+	now := time.Now()
+
+	for k, v, ok := it.Next(); ok && bytes.HasPrefix(k, []byte(prefix)); k, v, ok = it.Next() {
+		entry := v.(*memoryEntry)
+		if entry.expired(now) {
+			continue
+		}
+		if err := fn(string(k), entry.value); err != nil {
+			return stopped(err)
+		}
+	}
+	return nil
+}
+
+func (r memoryReader) ScanReverse(prefix, start string, fn ScanFunc) error {
+	it := r.tree.Root().ReverseIterator()
+	it.SeekReverseLowerBound([]byte(scanStart(prefix, start, true)))
+	now := time.Now()
+
+	for k, v, ok := it.Previous(); ok && bytes.HasPrefix(k, []byte(prefix)); k, v, ok = it.Previous() {
+		entry := v.(*memoryEntry)
+		if entry.expired(now) {
+			continue
+		}
+		if err := fn(string(k), entry.value); err != nil {
+			return stopped(err)
+		}
+	}
+	return nil
+}
+
+func (r memoryReader) Entries(fn EntryFunc) error {
+	now := time.Now()
+	var err error
+	r.tree.Root().Walk(func(k []byte, v interface{}) bool {
+		entry := v.(*memoryEntry)
+		if entry.expired(now) {
+			return false
+		}
+		err = fn(string(k), entry.value, entry.expiresAt)
+		return err != nil
+	})
+	return stopped(err)
+}
+
+// Release nothing to release, the tree is garbage collected
+func (r memoryReader) Release() {}
+
+// memoryWriter writes keys into a transaction of the tree
+type memoryWriter struct {
+	txn *iradix.Txn
+}
+
+func (w memoryWriter) Put(key string, value []byte) error {
+	w.txn.Insert([]byte(key), &memoryEntry{value: append([]byte(nil), value...)})
+	return nil
+}
+
+func (w memoryWriter) PutWithTTL(key string, value []byte, ttl time.Duration) error {
+	w.txn.Insert([]byte(key), &memoryEntry{value: append([]byte(nil), value...), expiresAt: time.Now().Add(ttl)})
+	return nil
+}
+
+func (w memoryWriter) Delete(key string) error {
+	w.txn.Delete([]byte(key))
+	return nil
+}
@@ -0,0 +1,132 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrNotFound the key does not exist or has expired
+var ErrNotFound = errors.New("key not found")
+
+// ErrStop returned by ScanFunc ends the scan without an error
+var ErrStop = errors.New("stop scan")
+
+// ScanFunc is called for every key of a scan.
+// The value is valid only until the function returns.
+type ScanFunc func(key string, value []byte) error
+
+// EntryFunc is called for every key of the store with its expiration,
+// zero never expires. The value is valid only until the function returns.
+type EntryFunc func(key string, value []byte, expiresAt time.Time) error
+
+// Reader reads keys of the store
+type Reader interface {
+	// Get returns the value of the key, ErrNotFound when there is no such key
+	Get(key string) ([]byte, error)
+	// Scan calls fn for the keys with the prefix in key order, starting at the
+	// first key not before start. Empty start scans the whole prefix.
+	Scan(prefix, start string, fn ScanFunc) error
+	// ScanReverse calls fn for the keys with the prefix in reverse key order, starting
+	// at the last key not after start. Empty start scans the whole prefix.
+	ScanReverse(prefix, start string, fn ScanFunc) error
+}
+
+// Writer writes keys of the store
+type Writer interface {
+	Put(key string, value []byte) error
+	// PutWithTTL writes the key which is gone once the ttl is over
+	PutWithTTL(key string, value []byte, ttl time.Duration) error
+	Delete(key string) error
+}
+
+// Snapshot consistent view of the store at the moment it was taken.
+// It must be released once it is read.
+type Snapshot interface {
+	Reader
+	// Entries calls fn for every key of the snapshot which is not expired
+	Entries(fn EntryFunc) error
+	Release()
+}
+
+// Store key value storage of the FSM
+type Store interface {
+	Reader
+	Writer
+
+	// Update commits the writes of fn atomically, nothing is written when fn fails
+	Update(fn func(w Writer) error) error
+	// Snapshot takes a consistent view of the store
+	Snapshot() (Snapshot, error)
+	// DropAll deletes every key of the store
+	DropAll() error
+	Close() error
+}
+
+// Collector store which reclaims space taken by overwritten, deleted and expired keys
+type Collector interface {
+	// CollectGarbage reclaims the space and returns how many files or keys were reclaimed.
+	// Engines which rewrite files rewrite the ones with at least discardRatio of stale data.
+	CollectGarbage(discardRatio float64) (int, error)
+}
+
+// Size space taken by the store on disk
+type Size struct {
+	LSM      int64 `json:"lsm_bytes"`
+	ValueLog int64 `json:"vlog_bytes"`
+}
+
+// Sizer store which reports its size
+type Sizer interface {
+	Size() Size
+}
+
+// Engine names in the configuration
+const (
+	BadgerEngine = "badger"
+	BoltEngine   = "bolt"
+	MemoryEngine = "memory"
+)
+
+// Open opens the store of the configured engine in the directory
+func Open(engine, dir string) (Store, error) {
+	switch engine {
+	case BadgerEngine:
+		return OpenBadger(dir)
+	case BoltEngine:
+		return OpenBolt(dir)
+	case MemoryEngine:
+		return NewMemory(), nil
+	}
+	return nil, fmt.Errorf("unknown storage engine %q", engine)
+}
+
+// View reads a snapshot of the store
+func View(s Store, fn func(r Reader) error) error {
+	snapshot, err := s.Snapshot()
+	if err != nil {
+		return err
+	}
+	defer snapshot.Release()
+
+	return fn(snapshot)
+}
+
+// scanStart key the scan starts at
+func scanStart(prefix, start string, reverse bool) string {
+	if start != "" {
+		return start
+	}
+	if reverse {
+		return prefix + "\xff"
+	}
+	return prefix
+}
+
+// stopped drops ErrStop of the scan function
+func stopped(err error) error {
+	if errors.Is(err, ErrStop) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,271 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// engines opens a store of every engine in its own temporary directory
+func engines() map[string]func(t *testing.T) Store {
+	return map[string]func(t *testing.T) Store{
+		BadgerEngine: func(t *testing.T) Store {
+			s, err := OpenBadger(t.TempDir())
+			if err != nil {
+				t.Fatal(err)
+			}
+			return s
+		},
+		BoltEngine: func(t *testing.T) Store {
+			s, err := OpenBolt(t.TempDir())
+			if err != nil {
+				t.Fatal(err)
+			}
+			return s
+		},
+		MemoryEngine: func(t *testing.T) Store {
+			return NewMemory()
+		},
+	}
+}
+
+func openStore(t *testing.T, open func(t *testing.T) Store, keys ...string) Store {
+	t.Helper()
+
+	s := open(t)
+	t.Cleanup(func() { _ = s.Close() })
+
+	for _, key := range keys {
+		if err := s.Put(key, []byte("v:"+key)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return s
+}
+
+// collect scans the keys, stopping after limit keys when it is positive
+func collect(scan func(prefix, start string, fn ScanFunc) error, prefix, start string, limit int) ([]string, error) {
+	var keys []string
+	err := scan(prefix, start, func(key string, value []byte) error {
+		if string(value) != "v:"+key {
+			return fmt.Errorf("value of %s is %q", key, value)
+		}
+		keys = append(keys, key)
+		if limit > 0 && len(keys) == limit {
+			return ErrStop
+		}
+		return nil
+	})
+	return keys, err
+}
+
+func TestScan(t *testing.T) {
+	keys := []string{"a/1", "a/2", "a/3", "a/30", "ab/1", "b/1", "a"}
+
+	tests := []struct {
+		name    string
+		prefix  string
+		start   string
+		limit   int
+		reverse bool
+		want    []string
+	}{
+		{name: "prefix", prefix: "a/", want: []string{"a/1", "a/2", "a/3", "a/30"}},
+		{name: "from start", prefix: "a/", start: "a/2", want: []string{"a/2", "a/3", "a/30"}},
+		{name: "from start between keys", prefix: "a/", start: "a/25", want: []string{"a/3", "a/30"}},
+		{name: "stopped", prefix: "a/", limit: 2, want: []string{"a/1", "a/2"}},
+		{name: "no keys", prefix: "c/"},
+		{name: "whole store", want: []string{"a", "a/1", "a/2", "a/3", "a/30", "ab/1", "b/1"}},
+		{name: "reverse prefix", prefix: "a/", reverse: true, want: []string{"a/30", "a/3", "a/2", "a/1"}},
+		{name: "reverse from start", prefix: "a/", start: "a/3", reverse: true, want: []string{"a/3", "a/2", "a/1"}},
+		{name: "reverse from start between keys", prefix: "a/", start: "a/25", reverse: true, want: []string{"a/2", "a/1"}},
+		{name: "reverse stopped", prefix: "a/", limit: 1, reverse: true, want: []string{"a/30"}},
+		{name: "reverse no keys", prefix: "c/", reverse: true},
+	}
+
+	for engine, open := range engines() {
+		t.Run(engine, func(t *testing.T) {
+			s := openStore(t, open, keys...)
+
+			for _, tt := range tests {
+				t.Run(tt.name, func(t *testing.T) {
+					scan := s.Scan
+					if tt.reverse {
+						scan = s.ScanReverse
+					}
+
+					got, err := collect(scan, tt.prefix, tt.start, tt.limit)
+					if err != nil {
+						t.Fatalf("scan error = %v", err)
+					}
+					if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+						t.Errorf("scan = %v, want %v", got, tt.want)
+					}
+				})
+			}
+		})
+	}
+}
+
+func TestScanError(t *testing.T) {
+	failure := errors.New("failure")
+
+	for engine, open := range engines() {
+		t.Run(engine, func(t *testing.T) {
+			s := openStore(t, open, "a/1", "a/2")
+
+			for name, scan := range map[string]func(prefix, start string, fn ScanFunc) error{
+				"scan":    s.Scan,
+				"reverse": s.ScanReverse,
+			} {
+				err := scan("a/", "", func(string, []byte) error { return failure })
+				if !errors.Is(err, failure) {
+					t.Errorf("%s error = %v, want %v", name, err, failure)
+				}
+			}
+		})
+	}
+}
+
+func TestGetDeleteUpdate(t *testing.T) {
+	for engine, open := range engines() {
+		t.Run(engine, func(t *testing.T) {
+			s := openStore(t, open, "a", "b")
+
+			if _, err := s.Get("missing"); !errors.Is(err, ErrNotFound) {
+				t.Errorf("Get(missing) error = %v, want %v", err, ErrNotFound)
+			}
+			if err := s.Delete("a"); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := s.Get("a"); !errors.Is(err, ErrNotFound) {
+				t.Errorf("Get(deleted) error = %v, want %v", err, ErrNotFound)
+			}
+
+			failure := errors.New("failure")
+			err := s.Update(func(w Writer) error {
+				if err := w.Put("c", []byte("v:c")); err != nil {
+					return err
+				}
+				if err := w.Delete("b"); err != nil {
+					return err
+				}
+				return failure
+			})
+			if !errors.Is(err, failure) {
+				t.Fatalf("Update() error = %v, want %v", err, failure)
+			}
+			if _, err := s.Get("c"); !errors.Is(err, ErrNotFound) {
+				t.Errorf("write of failed update is visible: %v", err)
+			}
+			if _, err := s.Get("b"); err != nil {
+				t.Errorf("delete of failed update is visible: %v", err)
+			}
+
+			err = s.Update(func(w Writer) error {
+				if err := w.Put("c", []byte("v:c")); err != nil {
+					return err
+				}
+				return w.Delete("b")
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := collect(s.Scan, "", "", 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if fmt.Sprint(got) != "[c]" {
+				t.Errorf("keys after update = %v, want [c]", got)
+			}
+		})
+	}
+}
+
+func TestTTL(t *testing.T) {
+	for engine, open := range engines() {
+		engine, open := engine, open
+		t.Run(engine, func(t *testing.T) {
+			t.Parallel()
+
+			s := openStore(t, open, "k/kept")
+			if err := s.PutWithTTL("k/short", []byte("v:k/short"), time.Second); err != nil {
+				t.Fatal(err)
+			}
+			if err := s.PutWithTTL("k/long", []byte("v:k/long"), time.Hour); err != nil {
+				t.Fatal(err)
+			}
+
+			if _, err := s.Get("k/short"); err != nil {
+				t.Fatalf("Get() before the ttl error = %v", err)
+			}
+
+			// badger keeps the expiration in whole seconds
+			time.Sleep(2100 * time.Millisecond)
+
+			if _, err := s.Get("k/short"); !errors.Is(err, ErrNotFound) {
+				t.Errorf("Get() after the ttl error = %v, want %v", err, ErrNotFound)
+			}
+			for name, scan := range map[string]func(prefix, start string, fn ScanFunc) error{
+				"scan":    s.Scan,
+				"reverse": s.ScanReverse,
+			} {
+				got, err := collect(scan, "k/", "", 0)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(got) != 2 || got[0] == "k/short" || got[1] == "k/short" {
+					t.Errorf("%s after the ttl = %v, want k/kept and k/long", name, got)
+				}
+			}
+
+			snapshot, err := s.Snapshot()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer snapshot.Release()
+
+			expirations := map[string]time.Time{}
+			err = snapshot.Entries(func(key string, value []byte, expiresAt time.Time) error {
+				expirations[key] = expiresAt
+				return nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, ok := expirations["k/short"]; ok {
+				t.Errorf("expired key is in the snapshot entries")
+			}
+			if !expirations["k/kept"].IsZero() {
+				t.Errorf("key without ttl expires at %s", expirations["k/kept"])
+			}
+			if until := time.Until(expirations["k/long"]); until < 58*time.Minute || until > time.Hour {
+				t.Errorf("key with ttl of an hour expires in %s", until)
+			}
+		})
+	}
+}
+
+func TestDropAll(t *testing.T) {
+	for engine, open := range engines() {
+		t.Run(engine, func(t *testing.T) {
+			s := openStore(t, open, "a", "b")
+
+			if err := s.DropAll(); err != nil {
+				t.Fatal(err)
+			}
+			got, err := collect(s.Scan, "", "", 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != 0 {
+				t.Errorf("keys after DropAll = %v", got)
+			}
+
+			if err := s.Put("c", []byte("v:c")); err != nil {
+				t.Fatalf("Put() after DropAll error = %v", err)
+			}
+		})
+	}
+}